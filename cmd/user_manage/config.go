@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = `Usage: user_manage config validate|print [flags]
+
+  validate  check the config loaded from --config or the flags
+  print     print the effective config as yaml, secrets are masked
+`
+
+func runConfig(args []string) int {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	confFlags := addConfigFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, configUsage)
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	action := flags.Arg(0)
+	if flags.NArg() != 1 || (action != "validate" && action != "print") {
+		flags.Usage()
+		return 2
+	}
+
+	conf, err := confFlags.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch action {
+	case "validate":
+		fmt.Println("config is valid")
+	case "print":
+		masked := *conf
+		if masked.DBPassword != "" {
+			masked.DBPassword = "******"
+		}
+		if masked.DSN != "" {
+			masked.DSN = "******"
+		}
+		data, err := yaml.Marshal(&masked)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal config failed: %v\n", err)
+			return 1
+		}
+		os.Stdout.Write(data)
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/api"
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/pkg/client"
+)
+
+// newLocalClient returns a client served by an in process api.Service on db,
+// so the changes made without a server are validated, audited and published
+// as user events the same as on the server.
+func newLocalClient(db *gorm.DB, conf *config.Config, actor string) client.Client {
+	svc := api.NewService(store.NewStore(db), conf, serviceOptions(conf)...)
+	httpClient := &http.Client{Transport: handlerTransport{handler: svc}}
+	return client.New("http://user_manage", client.WithHTTPClient(httpClient), client.WithActor(actor))
+}
+
+// handlerTransport serves requests by calling the handler in process. The
+// response body is streamed while the handler writes it, and a handler
+// aborting the response with http.ErrAbortHandler breaks the body like a
+// closed connection.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{header: make(http.Header), body: pw, ready: make(chan struct{})}
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				err := io.ErrUnexpectedEOF
+				if p != http.ErrAbortHandler {
+					err = fmt.Errorf("handler panic: %v", p)
+				}
+				w.once.Do(func() {
+					w.err = err
+					close(w.ready)
+				})
+				pw.CloseWithError(err)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		t.handler.ServeHTTP(w, req)
+	}()
+
+	<-w.ready
+	if w.err != nil {
+		return nil, w.err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          pr,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// pipeResponseWriter writes the response body to a pipe, ready is closed when
+// the header is sent or the handler failed before sending it.
+type pipeResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+
+	once   sync.Once
+	ready  chan struct{}
+	status int
+	sent   http.Header
+	err    error
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush does nothing, the writes of the pipe are not buffered.
+func (w *pipeResponseWriter) Flush() {}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/internal/store/storetest"
+	"go-unittest-best-practice/pkg/client"
+)
+
+func TestLocalClient(t *testing.T) {
+	db := storetest.OpenSQLite(t)
+	c := newLocalClient(db, &config.Config{}, "tester")
+	st := store.NewStore(db)
+	ctx := context.Background()
+
+	created, err := c.UserCreateContext(ctx, client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	_, err = c.UserCreateContext(ctx, client.User{Name: "liuliu"})
+	assert.EqualError(t, err, "param email not set")
+
+	require.NoError(t, c.UserUpdateContext(ctx, client.User{ID: created.ID, Age: 20}))
+	got, err := c.UserGetContext(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, got.Age)
+
+	// the changes are audited by the actor and published as user events
+	logs, total, err := st.Audits().List(store.AuditFilter{}, 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.Equal(t, "tester", logs[0].Actor)
+	lastID, err := st.Outbox().LastID()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, lastID)
+
+	report, err := c.UserImport(ctx, strings.NewReader("name,email\nzhangsan,cc@dd.com\n"), client.ImportFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	var buf bytes.Buffer
+	require.NoError(t, c.UserExport(ctx, &buf, client.ExportOptions{Fields: []string{"name"}, UserFilter: client.UserFilter{Name: "zhang"}}))
+	assert.Equal(t, "name\nzhangsan\n", buf.String())
+
+	require.NoError(t, c.UserDeleteContext(ctx, created.ID))
+	_, total, err = c.UserListContext(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+}
@@ -1,62 +1,85 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"net/http"
-	_ "net/http/pprof"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
 
 	"github.com/spf13/pflag"
-	"golang.org/x/exp/slog"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
 
-	"go-unittest-best-practice/internal/api"
 	"go-unittest-best-practice/internal/config"
-	"go-unittest-best-practice/internal/store"
 )
 
+type command struct {
+	name  string
+	short string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{name: "serve", short: "Run the HTTP server, the default command.", run: runServe},
+	{name: "migrate", short: "Apply, revert or show database schema migrations.", run: runMigrate},
+	{name: "user", short: "Create, get, list, update, delete, import or export users.", run: runUser},
+	{name: "relay", short: "Publish the user change events to the outbox sinks and webhooks.", run: runRelay},
+	{name: "config", short: "Validate or print the config.", run: runConfig},
+}
+
 func main() {
-	flags := pflag.NewFlagSet("test-service", pflag.ExitOnError)
-	var conf config.Config
-	conf.AddFlags(flags)
-	flags.Parse(os.Args)
-
-	slog.Info("load config", "config", conf)
-
-	db, err := gorm.Open(mysql.Open(fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=true",
-		conf.DBUser, conf.DBPassword, conf.DBHost, conf.DBPort, conf.DBName)), &gorm.Config{})
-	if err != nil {
-		slog.Error("open database failed", "error", err)
-		os.Exit(1)
+	args := os.Args[1:]
+	// serve without the subcommand keeps "user_manage --listen-port 8000"
+	// working as before subcommands were added
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		os.Exit(runServe(args))
 	}
-
-	svc := api.NewService(store.NewUserRepository(db), &conf)
-	apiServer := http.Server{
-		Handler: svc,
-		Addr:    fmt.Sprintf(":%d", conf.ListenPort),
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			os.Exit(cmd.run(args[1:]))
+		}
 	}
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	}
+	usage()
+	os.Exit(2)
+}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: user_manage <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `Run "user_manage <command> --help" for the flags of a command.`)
+}
 
-	go func() {
-		slog.Info("pprof server listening", "pprofAddr", conf.PprofAddr)
-		if err := http.ListenAndServe(conf.PprofAddr, nil); err != nil {
-			slog.Error("pprof server listen failed", err)
-		}
-	}()
+// configFlags loads the config from the --config file when it is set,
+// otherwise from the config flags.
+type configFlags struct {
+	conf config.Config
+	file string
+}
 
-	go func() {
-		<-sigChan
-		apiServer.Shutdown(context.Background())
-	}()
+func addConfigFlags(flags *pflag.FlagSet) *configFlags {
+	c := &configFlags{}
+	c.conf.AddFlags(flags)
+	flags.StringVar(&c.file, "config", "", "The config file, the config flags are ignored when it is set.")
+	return c
+}
 
-	slog.Info("server listening", "port", conf.ListenPort)
-	if err := apiServer.ListenAndServe(); err != nil {
-		slog.Error("server listen failed")
+// load returns the validated config.
+func (c *configFlags) load() (*config.Config, error) {
+	conf := c.conf
+	if c.file != "" {
+		fileConf, err := config.LoadConfig(c.file)
+		if err != nil {
+			return nil, fmt.Errorf("load config file failed: %v", err)
+		}
+		conf = *fileConf
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config failed: %v", err)
 	}
+	return &conf, nil
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+const migrateUsage = `Usage: user_manage migrate up|down|status [flags]
+
+  up      apply all pending migrations
+  down    revert the latest applied migrations, see --steps
+  status  show all migrations and whether they are applied
+`
+
+func runMigrate(args []string) int {
+	flags := pflag.NewFlagSet("migrate", pflag.ContinueOnError)
+	confFlags := addConfigFlags(flags)
+	steps := flags.Int("steps", 1, "The number of migrations to revert by down.")
+	lockTimeout := flags.Duration("lock-timeout", time.Minute, "How long to wait for the migration lock.")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	action := flags.Arg(0)
+	if flags.NArg() != 1 || (action != "up" && action != "down" && action != "status") {
+		flags.Usage()
+		return 2
+	}
+
+	conf, err := confFlags.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	db, err := store.Open(conf, &gorm.Config{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open database failed: %v\n", err)
+		return 1
+	}
+	migrator, err := store.NewMigrator(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create migrator failed: %v\n", err)
+		return 1
+	}
+	migrator.LockTimeout = *lockTimeout
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate up failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := migrator.Down(*steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate down failed: %v\n", err)
+			return 1
+		}
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status failed: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"go-unittest-best-practice/pkg/client"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validOutput(format string) error {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q, must be one of table, json, yaml", format)
+	}
+}
+
+// printObject prints v as json or yaml.
+func printObject(w io.Writer, format string, v interface{}) error {
+	if format == outputYAML {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printUsers prints users as a table, or v as json or yaml.
+func printUsers(w io.Writer, format string, v interface{}, users []client.User) error {
+	switch format {
+	case outputJSON, outputYAML:
+		return printObject(w, format, v)
+	default:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tAGE\tCREATED AT\tUPDATED AT")
+		for _, u := range users {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", u.ID, u.Name, u.Email, u.Age,
+				u.CreatedAt.Format(time.RFC3339), u.UpdatedAt.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	}
+}
+
+// printImportReport prints the rows which are not created and a summary as a
+// table, or the full report as json or yaml.
+func printImportReport(w io.Writer, format string, report *client.ImportReport) error {
+	if format != outputTable {
+		return printObject(w, format, report)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tSTATUS\tEMAIL\tREASON")
+	for _, row := range report.Rows {
+		if row.Status == client.ImportStatusCreated {
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", row.Line, row.Status, row.Email, row.Reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	summary := "created"
+	if report.DryRun {
+		summary = "to create (dry run)"
+	}
+	_, err := fmt.Fprintf(w, "\n%d %s, %d skipped, %d failed\n", report.Created, summary, report.Skipped, report.Failed)
+	return err
+}
+
+// printBatchResult prints the result of the batch-delete or batch-update
+// command.
+func printBatchResult(w io.Writer, format, command string, result client.BatchResult) error {
+	if format != outputTable {
+		return printObject(w, format, result)
+	}
+	action := "deleted"
+	if command == "batch-update" {
+		action = "updated"
+	}
+	if result.DryRun {
+		action = "to be " + action + " (dry run)"
+	}
+	_, err := fmt.Fprintf(w, "%d users %s\n", result.Affected, action)
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/pkg/client"
+)
+
+func TestPrintUsers(t *testing.T) {
+	users := []client.User{{
+		ID:        "0198271f-bc9d-74ac-a63b-41cf2c6c2f82",
+		Name:      "liuliu",
+		Email:     "aa@bb.com",
+		Age:       18,
+		CreatedAt: time.Date(2025, 7, 20, 8, 13, 21, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 7, 20, 8, 13, 21, 0, time.UTC),
+	}}
+
+	cases := []struct {
+		name     string
+		format   string
+		expected string
+	}{
+		{
+			name:   "table",
+			format: outputTable,
+			expected: `ID                                    NAME    EMAIL      AGE  CREATED AT            UPDATED AT
+0198271f-bc9d-74ac-a63b-41cf2c6c2f82  liuliu  aa@bb.com  18   2025-07-20T08:13:21Z  2025-07-20T08:13:21Z
+`,
+		},
+		{
+			name:   "json",
+			format: outputJSON,
+			expected: `[
+  {
+    "id": "0198271f-bc9d-74ac-a63b-41cf2c6c2f82",
+    "name": "liuliu",
+    "email": "aa@bb.com",
+    "age": 18,
+    "createdAt": "2025-07-20T08:13:21Z",
+    "updatedAt": "2025-07-20T08:13:21Z"
+  }
+]
+`,
+		},
+		{
+			name:   "yaml",
+			format: outputYAML,
+			expected: `- id: 0198271f-bc9d-74ac-a63b-41cf2c6c2f82
+  name: liuliu
+  email: aa@bb.com
+  age: 18
+  createdAt: 2025-07-20T08:13:21Z
+  updatedAt: 2025-07-20T08:13:21Z
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, printUsers(&buf, tc.format, users, users))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	assert.Error(t, validOutput("xml"))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go-unittest-best-practice/internal/outbox"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/internal/webhook"
+)
+
+// runRelay publishes the user change events of the outbox to the configured
+// sinks and the webhooks until it is interrupted.
+func runRelay(args []string) int {
+	flags := pflag.NewFlagSet("relay", pflag.ContinueOnError)
+	confFlags := addConfigFlags(flags)
+	once := flags.Bool("once", false, "Publish the due events once and exit.")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	conf, err := confFlags.load()
+	if err != nil {
+		slog.Error("load config failed", "error", err)
+		return 1
+	}
+	level, _ := conf.SlogLevel()
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	var sinks []outbox.Sink
+	for _, spec := range conf.OutboxSinks {
+		sink, err := outbox.ParseSink(spec)
+		if err != nil {
+			slog.Error("create sink failed", "sink", spec, "error", err)
+			return 1
+		}
+		if c, ok := sink.(io.Closer); ok {
+			defer c.Close()
+		}
+		sinks = append(sinks, sink)
+	}
+
+	db, err := store.Open(conf, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		slog.Error("open database failed", "error", err)
+		return 1
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	webhooks := store.NewWebhookRepository(db)
+	sinks = append(sinks, webhook.NewDispatcher(webhooks))
+	deliverer := webhook.NewDeliverer(webhooks, &http.Client{Timeout: 10 * time.Second})
+	relay := outbox.NewRelay(store.NewOutboxRepository(db), sinks...)
+	if conf.OutboxInterval > 0 {
+		relay.Interval = conf.OutboxInterval
+	}
+	relay.Retention = conf.OutboxRetention
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *once {
+		n, err := relay.RunOnce(ctx)
+		if err != nil {
+			slog.Error("relay outbox events failed", "error", err)
+			return 1
+		}
+		slog.Info("relayed outbox events", "count", n)
+		n, err = deliverer.RunOnce(ctx)
+		if err != nil {
+			slog.Error("deliver webhooks failed", "error", err)
+			return 1
+		}
+		slog.Info("delivered webhooks", "count", n)
+		return 0
+	}
+	slog.Info("relay started", "sinks", conf.OutboxSinks)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deliverer.Run(ctx)
+	}()
+	relay.Run(ctx)
+	<-done
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slog"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/api"
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/idgen"
+	"go-unittest-best-practice/internal/store"
+)
+
+// serviceOptions returns the options of the service set by conf.
+func serviceOptions(conf *config.Config) []api.Option {
+	if conf.IDVersion == "v7" {
+		return []api.Option{api.WithIDGenerator(idgen.UUIDv7)}
+	}
+	return nil
+}
+
+func runServe(args []string) int {
+	flags := pflag.NewFlagSet("serve", pflag.ContinueOnError)
+	confFlags := addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	conf, err := confFlags.load()
+	if err != nil {
+		slog.Error("load config failed", "error", err)
+		return 1
+	}
+	logLevel := &slog.LevelVar{}
+	level, _ := conf.SlogLevel()
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+
+	slog.Info("load config", "config", conf)
+
+	db, err := store.Open(conf, &gorm.Config{})
+	if err != nil {
+		slog.Error("open database failed", "error", err)
+		return 1
+	}
+
+	svc := api.NewService(store.NewStore(db), conf, serviceOptions(conf)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if confFlags.file != "" {
+		reloader := config.NewReloader(confFlags.file, conf)
+		reloader.Subscribe(func(newConf *config.Config) {
+			level, _ := newConf.SlogLevel()
+			logLevel.Set(level)
+			if err := store.ApplyPool(db, newConf); err != nil {
+				slog.Error("apply database pool config failed", "error", err)
+			}
+			svc.SetConfig(newConf)
+		})
+		go reloader.Run(ctx)
+	}
+
+	apiServer := http.Server{
+		Handler: svc,
+		Addr:    fmt.Sprintf(":%d", conf.ListenPort),
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		slog.Info("pprof server listening", "pprofAddr", conf.PprofAddr)
+		if err := http.ListenAndServe(conf.PprofAddr, nil); err != nil {
+			slog.Error("pprof server listen failed", err)
+		}
+	}()
+
+	var grpcServer *grpc.Server
+	if conf.GRPCPort != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", conf.GRPCPort))
+		if err != nil {
+			slog.Error("grpc server listen failed", "error", err)
+			return 1
+		}
+		grpcServer = api.NewGRPCServer(svc)
+		go func() {
+			slog.Info("grpc server listening", "port", conf.GRPCPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				slog.Error("grpc server serve failed", "error", err)
+			}
+		}()
+	}
+
+	go func() {
+		<-sigChan
+		cancel()
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		apiServer.Shutdown(context.Background())
+	}()
+
+	slog.Info("server listening", "port", conf.ListenPort)
+	if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("server listen failed", "error", err)
+		return 1
+	}
+	return 0
+}
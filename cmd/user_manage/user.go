@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/pkg/client"
+	"go-unittest-best-practice/pkg/client/grpcclient"
+)
+
+const userUsage = `Usage: user_manage user <command> [flags]
+
+Commands:
+  create --name NAME --email EMAIL [--age AGE]
+  get ID [ID...]
+  list
+  update ID [--name NAME] [--email EMAIL] [--age AGE]
+  delete ID
+  import FILE [--format csv|ndjson] [--dry-run]
+  export [--format csv|ndjson|json] [--fields id,name,...] [FILTER] [--gzip]
+         [--file FILE]
+  batch-delete FILTER [--dry-run]
+  batch-update FILTER [--set-name NAME] [--set-age AGE] [--dry-run]
+
+FILTER selects users by [--ids ID,...] [--name NAME] [--email EMAIL]
+[--created-after TIME] [--created-before TIME], times are in RFC 3339 format.
+
+The user commands talk to the server set by --server, or to the gRPC server
+set by --grpc-server, which has only the create, get, update, delete and list
+commands, or to the database directly when neither is set.
+`
+
+// userFlags are the flags shared by all user commands.
+type userFlags struct {
+	confFlags  *configFlags
+	server     string
+	grpcServer string
+	actor      string
+	output     string
+	timeout    time.Duration
+}
+
+func addUserFlags(flags *pflag.FlagSet) *userFlags {
+	f := &userFlags{}
+	flags.StringVar(&f.server, "server", "", "The user_manage server address, e.g. http://127.0.0.1:8000.")
+	flags.StringVar(&f.grpcServer, "grpc-server", "", "The user_manage gRPC server address, e.g. 127.0.0.1:9000.")
+	flags.StringVar(&f.actor, "actor", os.Getenv("USER"), "The actor recorded in the audit log of the changes.")
+	flags.StringVarP(&f.output, "output", "o", outputTable, "The output format, one of table, json, yaml.")
+	flags.DurationVar(&f.timeout, "timeout", 30*time.Second, "The timeout of the command.")
+	f.confFlags = addConfigFlags(flags)
+	return f
+}
+
+// client returns a client of the remote HTTP or gRPC server, or a client served
+// by an in process api.Service on the database, so all go through the same API.
+func (f *userFlags) client() (client.Client, func(), error) {
+	if f.server != "" {
+		return client.New(f.server, client.WithActor(f.actor)), func() {}, nil
+	}
+	if f.grpcServer != "" {
+		conn, err := grpc.NewClient(f.grpcServer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("connect grpc server failed: %v", err)
+		}
+		return grpcclient.New(conn, grpcclient.WithActor(f.actor)), func() { conn.Close() }, nil
+	}
+
+	conf, err := f.confFlags.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := store.Open(conf, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("open database failed: %v", err)
+	}
+	closeDB := func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	return newLocalClient(db, conf, f.actor), closeDB, nil
+}
+
+func runUser(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "--help" || args[0] == "-h" {
+		fmt.Fprint(os.Stderr, userUsage)
+		return 2
+	}
+	sub := args[0]
+
+	flags := pflag.NewFlagSet("user "+sub, pflag.ContinueOnError)
+	var name, email string
+	var age int
+	if sub == "create" || sub == "update" {
+		flags.StringVar(&name, "name", "", "The user name.")
+		flags.StringVar(&email, "email", "", "The user email.")
+		flags.IntVar(&age, "age", 0, "The user age.")
+	}
+	var format string
+	var dryRun bool
+	if sub == "import" {
+		flags.StringVar(&format, "format", "", "The input format, csv or ndjson, detected by the file extension if not set.")
+		flags.BoolVar(&dryRun, "dry-run", false, "Validate the rows without creating users.")
+	}
+	var exportOpts client.ExportOptions
+	var fields, file string
+	if sub == "export" {
+		flags.StringVar(&exportOpts.Format, "format", client.ExportFormatCSV, "The export format, one of csv, ndjson, json.")
+		flags.StringVar(&fields, "fields", "", "The comma separated exported fields, all fields if not set.")
+		flags.BoolVar(&exportOpts.Gzip, "gzip", false, "Gzip the export.")
+		flags.StringVar(&file, "file", "-", "The file to write the export to, - means stdout.")
+	}
+	var filter *filterFlags
+	if sub == "export" || sub == "batch-delete" || sub == "batch-update" {
+		filter = addFilterFlags(flags)
+	}
+	var setName string
+	var setAge int
+	if sub == "batch-update" {
+		flags.StringVar(&setName, "set-name", "", "The name to set.")
+		flags.IntVar(&setAge, "set-age", 0, "The age to set.")
+	}
+	if sub == "batch-delete" || sub == "batch-update" {
+		flags.BoolVar(&dryRun, "dry-run", false, "Count the matched users without changing them.")
+	}
+	f := addUserFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, userUsage)
+		fmt.Fprintln(os.Stderr)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := validOutput(f.output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var id string
+	switch sub {
+	case "get":
+		if flags.NArg() == 0 {
+			flags.Usage()
+			return 2
+		}
+		id = flags.Arg(0)
+	case "update", "delete", "import":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			return 2
+		}
+		id = flags.Arg(0)
+	case "create", "list", "export", "batch-delete", "batch-update":
+		if flags.NArg() != 0 {
+			flags.Usage()
+			return 2
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown user command %q\n\n", sub)
+		flags.Usage()
+		return 2
+	}
+
+	if sub == "import" && format == "" {
+		switch filepath.Ext(id) {
+		case ".csv":
+			format = client.ImportFormatCSV
+		case ".ndjson", ".jsonl":
+			format = client.ImportFormatNDJSON
+		default:
+			fmt.Fprintln(os.Stderr, "can not detect the input format, please set --format")
+			return 2
+		}
+	}
+
+	if filter != nil {
+		userFilter, err := filter.filter()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		exportOpts.UserFilter = userFilter
+	}
+	if fields != "" {
+		exportOpts.Fields = strings.Split(fields, ",")
+	}
+	var userFields client.UserFields
+	if flags.Changed("set-name") {
+		userFields.Name = &setName
+	}
+	if flags.Changed("set-age") {
+		userFields.Age = &setAge
+	}
+
+	c, closeFn, err := f.client()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeFn()
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	switch sub {
+	case "create":
+		var user *client.User
+		user, err = c.UserCreateContext(ctx, client.User{Name: name, Email: email, Age: age})
+		if err == nil {
+			err = printUsers(os.Stdout, f.output, user, []client.User{*user})
+		}
+	case "get":
+		if flags.NArg() > 1 {
+			return getUsers(ctx, c, flags.Args(), f.output)
+		}
+		var user *client.User
+		user, err = c.UserGetContext(ctx, id)
+		if err == nil {
+			err = printUsers(os.Stdout, f.output, user, []client.User{*user})
+		}
+	case "list":
+		var users []client.User
+		var total int64
+		users, total, err = c.UserListContext(ctx)
+		if err == nil {
+			err = printUsers(os.Stdout, f.output, client.ListResponseData{Total: total, Users: users}, users)
+		}
+	case "update":
+		err = c.UserUpdateContext(ctx, client.User{ID: id, Name: name, Email: email, Age: age})
+		if err == nil {
+			var user *client.User
+			user, err = c.UserGetContext(ctx, id)
+			if err == nil {
+				err = printUsers(os.Stdout, f.output, user, []client.User{*user})
+			}
+		}
+	case "delete":
+		err = c.UserDeleteContext(ctx, id)
+		if err == nil {
+			fmt.Printf("user %s deleted\n", id)
+		}
+	case "import":
+		err = importUsers(ctx, c, id, format, dryRun, f.output)
+	case "export":
+		err = exportUsers(ctx, c, file, exportOpts)
+	case "batch-delete", "batch-update":
+		var affected int64
+		if sub == "batch-delete" {
+			affected, err = c.UserBatchDelete(ctx, exportOpts.UserFilter, dryRun)
+		} else {
+			affected, err = c.UserBatchUpdate(ctx, exportOpts.UserFilter, userFields, dryRun)
+		}
+		if err == nil {
+			err = printBatchResult(os.Stdout, f.output, sub, client.BatchResult{DryRun: dryRun, Affected: affected})
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "user %s failed: %v\n", sub, err)
+		return 1
+	}
+	return 0
+}
+
+// filterFlags are the flags selecting users.
+type filterFlags struct {
+	ids           []string
+	name          string
+	email         string
+	createdAfter  string
+	createdBefore string
+}
+
+func addFilterFlags(flags *pflag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	flags.StringSliceVar(&f.ids, "ids", nil, "Select the users with the comma separated ids.")
+	flags.StringVar(&f.name, "name", "", "Select the users whose name contains the value.")
+	flags.StringVar(&f.email, "email", "", "Select the user with the email.")
+	flags.StringVar(&f.createdAfter, "created-after", "", "Select the users created at or after the RFC 3339 time.")
+	flags.StringVar(&f.createdBefore, "created-before", "", "Select the users created before the RFC 3339 time.")
+	return f
+}
+
+func (f *filterFlags) filter() (client.UserFilter, error) {
+	filter := client.UserFilter{IDs: f.ids, Name: f.name, Email: f.email}
+	for _, t := range []struct {
+		value string
+		t     *time.Time
+	}{
+		{value: f.createdAfter, t: &filter.CreatedAfter},
+		{value: f.createdBefore, t: &filter.CreatedBefore},
+	} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid time %q, must be in RFC 3339 format", t.value)
+		}
+		*t.t = parsed
+	}
+	return filter, nil
+}
+
+// getUsers gets the users in one request, the missing ids are reported on
+// stderr and fail the command.
+func getUsers(ctx context.Context, c client.Client, ids []string, output string) int {
+	users, missing, err := c.UserBatchGet(ctx, ids, nil)
+	if err == nil {
+		err = printUsers(os.Stdout, output, client.BatchGetResponseData{Users: users, Missing: missing}, users)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "user get failed: %v\n", err)
+		return 1
+	}
+	for _, id := range missing {
+		fmt.Fprintf(os.Stderr, "user %s not found\n", id)
+	}
+	if len(missing) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// importUsers imports users from file, "-" means stdin.
+func importUsers(ctx context.Context, c client.Client, file, format string, dryRun bool, output string) error {
+	in := os.Stdin
+	if file != "-" {
+		var err error
+		in, err = os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	report, err := c.UserImport(ctx, in, format, dryRun)
+	if err != nil {
+		return err
+	}
+	return printImportReport(os.Stdout, output, report)
+}
+
+// exportUsers writes the export to file, "-" means stdout. A partially written
+// file is removed on failure.
+func exportUsers(ctx context.Context, c client.Client, file string, opts client.ExportOptions) error {
+	if file == "-" {
+		return c.UserExport(ctx, os.Stdout, opts)
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = c.UserExport(ctx, out, opts)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+const (
+	// HeaderRequestID is the request id header, it is generated if the client
+	// does not send one and is sent back in the response.
+	HeaderRequestID = "X-Request-ID"
+	// HeaderActor is the header naming who sends the request.
+	HeaderActor = "X-Actor"
+
+	anonymousActor     = "anonymous"
+	maxRequestIDLength = 64
+	maxAuditPageSize   = 1000
+)
+
+type requestInfo struct {
+	actor     string
+	requestID string
+}
+
+type requestInfoKey struct{}
+
+// withRequestInfo adds the actor and request id of r to its context.
+func (s *Service) withRequestInfo(w http.ResponseWriter, r *http.Request) *http.Request {
+	requestID := r.Header.Get(HeaderRequestID)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = s.ids.NewID()
+	}
+	w.Header().Set(HeaderRequestID, requestID)
+	actor := r.Header.Get(HeaderActor)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	ctx := context.WithValue(r.Context(), requestInfoKey{}, requestInfo{actor: actor, requestID: requestID})
+	return r.WithContext(ctx)
+}
+
+func requestInfoFrom(ctx context.Context) requestInfo {
+	info, ok := ctx.Value(requestInfoKey{}).(requestInfo)
+	if !ok {
+		return requestInfo{actor: anonymousActor}
+	}
+	return info
+}
+
+// changeSet collects the user changes made in a transaction, they are audited
+// and written to the outbox in the same transaction.
+type changeSet struct {
+	info   requestInfo
+	logs   []store.AuditLog
+	events []store.OutboxEvent
+}
+
+// add records a change of a user, before is nil for a create and after is nil
+// for a delete.
+func (c *changeSet) add(before, after *store.User) {
+	c.logs = append(c.logs, store.NewUserAuditLog(c.info.actor, c.info.requestID, before, after))
+	c.events = append(c.events, store.NewUserOutboxEvent(c.info.requestID, before, after))
+}
+
+// transact runs fn in a transaction of st and writes the changes recorded by
+// fn before committing.
+func transact(st store.Store, info requestInfo, fn func(tx store.Store, changes *changeSet) error) error {
+	return st.Transaction(func(tx store.Store) error {
+		changes := &changeSet{info: info}
+		if err := fn(tx, changes); err != nil {
+			return err
+		}
+		if err := tx.Audits().CreateBatch(changes.logs); err != nil {
+			return err
+		}
+		return tx.Outbox().CreateBatch(changes.events)
+	})
+}
+
+// mutate is transact for the request r.
+func (s *Service) mutate(r *http.Request, fn func(tx store.Store, changes *changeSet) error) error {
+	return transact(s.store, requestInfoFrom(r.Context()), fn)
+}
+
+type AuditLog struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	TargetID  string          `json:"targetId"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"requestId"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func convertModelAuditLog(log *store.AuditLog) *AuditLog {
+	auditLog := &AuditLog{
+		ID:        log.ID,
+		Actor:     log.Actor,
+		Action:    log.Action,
+		TargetID:  log.TargetID,
+		RequestID: log.RequestID,
+		CreatedAt: log.CreatedAt,
+	}
+	if log.Before != "" {
+		auditLog.Before = json.RawMessage(log.Before)
+	}
+	if log.After != "" {
+		auditLog.After = json.RawMessage(log.After)
+	}
+	return auditLog
+}
+
+// listAudit lists the audit logs filtered by the user_id, actor, action,
+// created_after and created_before params, the newest first.
+func (s *Service) listAudit(w http.ResponseWriter, r *http.Request) {
+	filter := store.AuditFilter{
+		TargetID: r.FormValue("user_id"),
+		Actor:    r.FormValue("actor"),
+		Action:   r.FormValue("action"),
+	}
+	for _, param := range []struct {
+		key string
+		t   *time.Time
+	}{
+		{key: "created_after", t: &filter.CreatedAfter},
+		{key: "created_before", t: &filter.CreatedBefore},
+	} {
+		value := r.FormValue(param.key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, fmt.Errorf("param %s invalid: %s", param.key, value))
+			return
+		}
+		*param.t = t
+	}
+	page, err := formInt(r, "page")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return
+	}
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, err := formInt(r, "page_size")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return
+	}
+	if pageSize < 0 || pageSize > maxAuditPageSize {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param page_size must be at most %d", maxAuditPageSize))
+		return
+	}
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	logs, total, err := s.store.Audits().List(filter, page, pageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, err)
+		return
+	}
+	dataLogs := make([]AuditLog, 0, len(logs))
+	for i := range logs {
+		dataLogs = append(dataLogs, *convertModelAuditLog(&logs[i]))
+	}
+	s.data(w, map[string]interface{}{
+		"total": total,
+		"logs":  dataLogs,
+	})
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+func (s *ServiceTestSuite) TestAuditRequestInfo() {
+	id := "0198271f-bc9d-74ac-a63b-41cf2c6c2f82"
+	s.Run("from headers", func() {
+		s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{ID: id, Name: "liuliu", Email: "aa@bb.com"}, nil).Times(1)
+		s.mockUserRepo.EXPECT().DeleteByID(id).Return(nil).Times(1)
+		s.mockAuditRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(logs []store.AuditLog) error {
+			s.Require().Len(logs, 1)
+			s.Equal("admin", logs[0].Actor)
+			s.Equal("req-1", logs[0].RequestID)
+			s.Equal(store.AuditActionDelete, logs[0].Action)
+			s.Equal(id, logs[0].TargetID)
+			return nil
+		}).Times(1)
+		s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(events []store.OutboxEvent) error {
+			s.Require().Len(events, 1)
+			s.Equal(store.EventUserDeleted, events[0].Type)
+			s.Equal(id, events[0].UserID)
+			s.Equal("req-1", events[0].RequestID)
+			s.Contains(events[0].Payload, `"email":"aa@bb.com"`)
+			return nil
+		}).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/delete?id="+id, nil)
+		req.Header.Set(HeaderActor, "admin")
+		req.Header.Set(HeaderRequestID, "req-1")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.Equal("req-1", w.Header().Get(HeaderRequestID))
+	})
+	s.Run("generated", func() {
+		var requestID string
+		s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{ID: id}, nil).Times(1)
+		s.mockUserRepo.EXPECT().DeleteByID(id).Return(nil).Times(1)
+		s.mockAuditRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(logs []store.AuditLog) error {
+			s.Equal(anonymousActor, logs[0].Actor)
+			requestID = logs[0].RequestID
+			return nil
+		}).Times(1)
+		s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Len(1)).Return(nil).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/delete?id="+id, nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.NotEmpty(requestID)
+		s.Equal(requestID, w.Header().Get(HeaderRequestID))
+	})
+	s.Run("audit failed", func() {
+		s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{ID: id}, nil).Times(1)
+		s.mockUserRepo.EXPECT().DeleteByID(id).Return(nil).Times(1)
+		s.mockAuditRepo.EXPECT().CreateBatch(gomock.Any()).Return(gorm.ErrInvalidDB).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/delete?id="+id, nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+		s.EqualValues(`{"error":"invalid db"}`, w.Body.String())
+	})
+}
+
+func (s *ServiceTestSuite) TestListAudit() {
+	s.Run("invalid time", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/audit?created_before=now", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param created_before invalid: now"}`, w.Body.String())
+	})
+	s.Run("invalid page size", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/audit?page_size=5000", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param page_size must be at most 1000"}`, w.Body.String())
+	})
+	s.Run("success", func() {
+		created := time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC)
+		filter := store.AuditFilter{TargetID: "id1", CreatedAfter: created}
+		s.mockAuditRepo.EXPECT().List(filter, 2, 10).Return([]store.AuditLog{{
+			ID:        3,
+			Actor:     "admin",
+			Action:    store.AuditActionUpdate,
+			TargetID:  "id1",
+			Before:    `{"age":10}`,
+			After:     `{"age":20}`,
+			RequestID: "req-1",
+			CreatedAt: created,
+		}, {
+			ID:        2,
+			Actor:     "admin",
+			Action:    store.AuditActionCreate,
+			TargetID:  "id1",
+			After:     `{"age":10,"email":"aa@bb.com","name":"liuliu"}`,
+			RequestID: "req-0",
+			CreatedAt: created,
+		}}, int64(12), nil).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/audit?user_id=id1&created_after=2025-07-20T16:13:21Z&page=2&page_size=10", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"logs":[`+
+			`{"id":3,"actor":"admin","action":"update","targetId":"id1","before":{"age":10},"after":{"age":20},"requestId":"req-1","createdAt":"2025-07-20T16:13:21Z"},`+
+			`{"id":2,"actor":"admin","action":"create","targetId":"id1","before":null,"after":{"age":10,"email":"aa@bb.com","name":"liuliu"},"requestId":"req-0","createdAt":"2025-07-20T16:13:21Z"}],`+
+			`"total":12}}`, w.Body.String())
+	})
+}
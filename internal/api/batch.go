@@ -0,0 +1,216 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+type BatchGetResponseData struct {
+	Users []User `json:"users"`
+	// Missing are the requested ids or emails without a user.
+	Missing []string `json:"missing"`
+}
+
+// batchGetUsers gets the users by the ids or the emails param in one query.
+// The params are comma separated lists and may be repeated, the users are in
+// the order of the first occurrence of their key.
+func (s *Service) batchGetUsers(w http.ResponseWriter, r *http.Request) {
+	ids := formList(r, "ids")
+	emails := formList(r, "emails")
+	if len(ids) == 0 && len(emails) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param ids or emails not set"))
+		return
+	}
+	if len(ids) > 0 && len(emails) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param ids and emails can not be set together"))
+		return
+	}
+
+	keys, get, key := ids, s.userRepo.GetByIDs, func(u *store.User) string { return u.ID }
+	if len(emails) > 0 {
+		keys, get, key = emails, s.userRepo.GetByEmails, func(u *store.User) string { return u.Email }
+	}
+	if limit := s.Config().BatchLimit(); len(keys) > limit {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("too many keys, at most %d are allowed", limit))
+		return
+	}
+
+	users, err := get(keys)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, err)
+		return
+	}
+	found := make(map[string]*store.User, len(users))
+	for i := range users {
+		found[key(&users[i])] = &users[i]
+	}
+	data := BatchGetResponseData{Users: make([]User, 0, len(users)), Missing: []string{}}
+	for _, k := range keys {
+		if u, ok := found[k]; ok {
+			data.Users = append(data.Users, *convertModelUser(u))
+		} else {
+			data.Missing = append(data.Missing, k)
+		}
+	}
+	s.data(w, data)
+}
+
+// BatchResult is the result of a batch delete or update, with dry run Affected
+// is the number of users which would be affected.
+type BatchResult struct {
+	DryRun   bool  `json:"dryRun"`
+	Affected int64 `json:"affected"`
+}
+
+// batchDeleteUsers deletes the users selected by the ids param or the filter
+// params of export, either all or none of them are deleted.
+func (s *Service) batchDeleteUsers(w http.ResponseWriter, r *http.Request) {
+	filter, dryRun, ok := s.parseBatchRequest(w, r)
+	if !ok {
+		return
+	}
+	s.runBatch(w, r, filter, dryRun, func(tx store.Store, changes *changeSet, limit int) (int, error) {
+		users, err := tx.Users().DeleteBatch(filter, limit)
+		for i := range users {
+			changes.add(&users[i], nil)
+		}
+		return len(users), err
+	})
+}
+
+// batchUpdateUsers sets the set_name and set_age params on the users selected
+// the same way as batchDeleteUsers.
+func (s *Service) batchUpdateUsers(w http.ResponseWriter, r *http.Request) {
+	filter, dryRun, ok := s.parseBatchRequest(w, r)
+	if !ok {
+		return
+	}
+	var fields store.UserFields
+	if name := r.FormValue("set_name"); name != "" {
+		fields.Name = &name
+	}
+	if r.FormValue("set_age") != "" {
+		age, err := formInt(r, "set_age")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, err)
+			return
+		}
+		fields.Age = &age
+	}
+	if fields.Name == nil && fields.Age == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param set_name or set_age not set"))
+		return
+	}
+	s.runBatch(w, r, filter, dryRun, func(tx store.Store, changes *changeSet, limit int) (int, error) {
+		users, err := tx.Users().UpdateBatch(filter, fields, limit)
+		if err != nil || len(users) == 0 {
+			return len(users), err
+		}
+		// the updated rows are read back for the timestamps set by the store
+		ids := make([]string, 0, len(users))
+		for i := range users {
+			ids = append(ids, users[i].ID)
+		}
+		updated, err := tx.Users().GetByIDs(ids)
+		if err != nil {
+			return 0, err
+		}
+		after := make(map[string]*store.User, len(updated))
+		for i := range updated {
+			after[updated[i].ID] = &updated[i]
+		}
+		for i := range users {
+			u, ok := after[users[i].ID]
+			if !ok {
+				return 0, fmt.Errorf("user %s not found after update", users[i].ID)
+			}
+			changes.add(&users[i], u)
+		}
+		return len(users), nil
+	})
+}
+
+func (s *Service) parseBatchRequest(w http.ResponseWriter, r *http.Request) (store.UserFilter, bool, bool) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.error(w, fmt.Errorf("method %s not allowed", r.Method))
+		return store.UserFilter{}, false, false
+	}
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return filter, false, false
+	}
+	if filter.IsZero() {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param ids, name, email, created_after or created_before not set"))
+		return filter, false, false
+	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dry_run"))
+	return filter, dryRun, true
+}
+
+// runBatch runs op in a transaction with the batch limit of the config, with
+// dry run only the matched users are counted.
+func (s *Service) runBatch(w http.ResponseWriter, r *http.Request, filter store.UserFilter, dryRun bool, op func(tx store.Store, changes *changeSet, limit int) (int, error)) {
+	limit := s.Config().BatchLimit()
+	var affected int64
+	var err error
+	if dryRun {
+		affected, err = s.userRepo.Count(filter)
+		if err == nil && affected > int64(limit) {
+			err = &store.BatchLimitError{Matched: affected, Limit: limit}
+		}
+	} else {
+		err = s.mutate(r, func(tx store.Store, changes *changeSet) error {
+			n, err := op(tx, changes, limit)
+			affected = int64(n)
+			return err
+		})
+	}
+	if err != nil {
+		var limitErr *store.BatchLimitError
+		if errors.As(err, &limitErr) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		s.error(w, err)
+		return
+	}
+	s.data(w, BatchResult{DryRun: dryRun, Affected: affected})
+}
+
+// formList returns the values of the comma separated form value key, it may be
+// repeated. Empty and duplicated values are dropped.
+func formList(r *http.Request, key string) []string {
+	r.ParseForm()
+	var list []string
+	seen := make(map[string]struct{})
+	for _, value := range r.Form[key] {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v == "" {
+				continue
+			}
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			list = append(list, v)
+		}
+	}
+	return list
+}
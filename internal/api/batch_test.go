@@ -0,0 +1,233 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/clock"
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/store"
+)
+
+func (s *ServiceTestSuite) TestBatchGetUsers() {
+	created := time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC)
+	s.Run("keys not set", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_get", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param ids or emails not set"}`, w.Body.String())
+	})
+	s.Run("ids and emails", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_get?ids=id1&emails=aa@bb.com", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param ids and emails can not be set together"}`, w.Body.String())
+	})
+	s.Run("too many keys", func() {
+		ids := make([]string, config.DefaultMaxBatchSize+1)
+		for i := range ids {
+			ids[i] = strconv.Itoa(i)
+		}
+		form := url.Values{"ids": {strings.Join(ids, ",")}}
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_get", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"too many keys, at most 1000 are allowed"}`, w.Body.String())
+	})
+	s.Run("ids", func() {
+		s.mockUserRepo.EXPECT().GetByIDs([]string{"id2", "id1", "id3"}).Return([]store.User{
+			{ID: "id1", Name: "liuliu", Email: "aa@bb.com", CreatedAt: created, UpdatedAt: created},
+			{ID: "id2", Name: "liuhong", Email: "cc@dd.com", CreatedAt: created, UpdatedAt: created},
+		}, nil).Times(1)
+
+		form := url.Values{"ids": {"id2,id1", "id3,id2"}}
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_get", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"users":[`+
+			`{"id":"id2","name":"liuhong","email":"cc@dd.com","age":0,"createdAt":"2025-07-20T16:13:21Z","updatedAt":"2025-07-20T16:13:21Z"},`+
+			`{"id":"id1","name":"liuliu","email":"aa@bb.com","age":0,"createdAt":"2025-07-20T16:13:21Z","updatedAt":"2025-07-20T16:13:21Z"}],`+
+			`"missing":["id3"]}}`, w.Body.String())
+	})
+	s.Run("emails none found", func() {
+		s.mockUserRepo.EXPECT().GetByEmails([]string{"aa@bb.com"}).Return(nil, nil).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/batch_get?emails=aa@bb.com", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"users":[],"missing":["aa@bb.com"]}}`, w.Body.String())
+	})
+	s.Run("get failed", func() {
+		s.mockUserRepo.EXPECT().GetByIDs([]string{"id1"}).Return(nil, gorm.ErrInvalidDB).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/batch_get?ids=id1", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+		s.EqualValues(`{"error":"invalid db"}`, w.Body.String())
+	})
+}
+
+func (s *ServiceTestSuite) TestBatchDeleteUsers() {
+	s.Run("method not allowed", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/batch_delete?ids=id1", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusMethodNotAllowed, w.Code)
+		s.EqualValues(`{"error":"method GET not allowed"}`, w.Body.String())
+	})
+	s.Run("filter not set", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_delete?dry_run=true", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param ids, name, email, created_after or created_before not set"}`, w.Body.String())
+	})
+	s.Run("dry run", func() {
+		s.mockUserRepo.EXPECT().Count(store.UserFilter{Name: "liu"}).Return(int64(3), nil).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_delete?name=liu&dry_run=true", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"dryRun":true,"affected":3}}`, w.Body.String())
+	})
+	s.Run("dry run over limit", func() {
+		s.svc.SetConfig(&config.Config{MaxBatchSize: 2})
+		defer s.svc.SetConfig(s.conf)
+		s.mockUserRepo.EXPECT().Count(store.UserFilter{Name: "liu"}).Return(int64(3), nil).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_delete?name=liu&dry_run=true", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"3 users matched, at most 2 are allowed"}`, w.Body.String())
+	})
+	s.Run("success", func() {
+		s.mockUserRepo.EXPECT().DeleteBatch(store.UserFilter{IDs: []string{"id1", "id2"}}, config.DefaultMaxBatchSize).
+			Return([]store.User{{ID: "id1"}, {ID: "id2"}}, nil).Times(1)
+		s.expectChanges(2)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_delete?ids=id1,id2", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"dryRun":false,"affected":2}}`, w.Body.String())
+	})
+	s.Run("over limit", func() {
+		s.mockUserRepo.EXPECT().DeleteBatch(store.UserFilter{Email: "aa@bb.com"}, config.DefaultMaxBatchSize).
+			Return(nil, &store.BatchLimitError{Matched: 1001, Limit: 1000}).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_delete?email=aa@bb.com", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"1001 users matched, at most 1000 are allowed"}`, w.Body.String())
+	})
+}
+
+func (s *ServiceTestSuite) TestBatchUpdateUsers() {
+	s.Run("fields not set", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_update?ids=id1", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param set_name or set_age not set"}`, w.Body.String())
+	})
+	s.Run("param set_age invalid", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_update?ids=id1&set_age=ten", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param set_age invalid: ten"}`, w.Body.String())
+	})
+	s.Run("success", func() {
+		s.mockUserRepo.EXPECT().UpdateBatch(store.UserFilter{Name: "liu"}, gomock.Any(), config.DefaultMaxBatchSize).
+			DoAndReturn(func(filter store.UserFilter, fields store.UserFields, limit int) ([]store.User, error) {
+				s.Nil(fields.Name)
+				s.Require().NotNil(fields.Age)
+				s.Equal(0, *fields.Age)
+				return []store.User{{ID: "id1", Name: "liuliu", Age: 10}, {ID: "id2", Name: "liuhong"}}, nil
+			}).Times(1)
+		updatedAt := time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC)
+		s.mockUserRepo.EXPECT().GetByIDs([]string{"id1", "id2"}).Return([]store.User{
+			{ID: "id2", Name: "liuhong", UpdatedAt: updatedAt},
+			{ID: "id1", Name: "liuliu", UpdatedAt: updatedAt},
+		}, nil).Times(1)
+		s.mockAuditRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(logs []store.AuditLog) error {
+			s.Require().Len(logs, 2)
+			s.Equal("id1", logs[0].TargetID)
+			s.Equal(`{"age":10}`, logs[0].Before)
+			s.Equal(`{"age":0}`, logs[0].After)
+			// nothing changed
+			s.Equal(`{}`, logs[1].Before)
+			return nil
+		}).Times(1)
+		s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(events []store.OutboxEvent) error {
+			s.Require().Len(events, 2)
+			s.Contains(events[0].Payload, `"updatedAt":"2025-07-20T16:13:21Z"`)
+			return nil
+		}).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_update?name=liu&set_age=0", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"dryRun":false,"affected":2}}`, w.Body.String())
+	})
+	s.Run("read back failed", func() {
+		s.mockUserRepo.EXPECT().UpdateBatch(gomock.Any(), gomock.Any(), gomock.Any()).Return([]store.User{{ID: "id1"}}, nil).Times(1)
+		s.mockUserRepo.EXPECT().GetByIDs([]string{"id1"}).Return(nil, gorm.ErrInvalidDB).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_update?name=liu&set_name=liuliu", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+		s.EqualValues(`{"error":"invalid db"}`, w.Body.String())
+	})
+	s.Run("update failed", func() {
+		s.mockUserRepo.EXPECT().UpdateBatch(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, gorm.ErrInvalidDB).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_update?name=liu&set_name=liuliu", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+		s.EqualValues(`{"error":"invalid db"}`, w.Body.String())
+	})
+}
+
+func (s *ServiceTestSuite) TestBatchUpdateUsersStoreClock() {
+	// the store clock differs from the service clock, the changes record the
+	// time the store set
+	c := clock.NewFake(time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC))
+	repo := s.useMemoryUsers(store.WithClock(c))
+	s.Require().NoError(repo.Create(&store.User{ID: "id1", Name: "liuliu", Email: "aa@bb.com"}))
+	c.Advance(time.Hour)
+
+	s.mockAuditRepo.EXPECT().CreateBatch(gomock.Len(1)).Return(nil).Times(1)
+	s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(events []store.OutboxEvent) error {
+		s.Require().Len(events, 1)
+		s.Contains(events[0].Payload, `"age":20,"createdAt":"2025-07-20T16:13:21Z","updatedAt":"2025-07-20T17:13:21Z"`)
+		return nil
+	}).Times(1)
+
+	req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/batch_update?name=liuliu&set_age=20", nil)
+	w := httptest.NewRecorder()
+	s.svc.ServeHTTP(w, req)
+	s.EqualValues(http.StatusOK, w.Code)
+	s.EqualValues(`{"data":{"dryRun":false,"affected":1}}`, w.Body.String())
+}
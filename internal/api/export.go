@@ -0,0 +1,275 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatJSON   = "json"
+
+	exportBatchSize = 500
+)
+
+type exportField struct {
+	name  string
+	value func(u *store.User) interface{}
+}
+
+// exportFields are the fields of an exported user in the default order, the
+// names are the same as the json names of User.
+var exportFields = []exportField{
+	{name: "id", value: func(u *store.User) interface{} { return u.ID }},
+	{name: "name", value: func(u *store.User) interface{} { return u.Name }},
+	{name: "email", value: func(u *store.User) interface{} { return u.Email }},
+	{name: "age", value: func(u *store.User) interface{} { return u.Age }},
+	{name: "createdAt", value: func(u *store.User) interface{} { return u.CreatedAt }},
+	{name: "updatedAt", value: func(u *store.User) interface{} { return u.UpdatedAt }},
+}
+
+// exportUsers streams the users matching the filter params as csv, ndjson or
+// a json array. The format is read from the format param or the Accept header,
+// the fields param selects the exported fields, and the body is gzipped if the
+// client accepts gzip encoding.
+func (s *Service) exportUsers(w http.ResponseWriter, r *http.Request) {
+	format := r.FormValue("format")
+	if format == "" {
+		format = exportFormatFromAccept(r.Header.Get("Accept"))
+	}
+	var contentType string
+	switch format {
+	case ExportFormatCSV:
+		contentType = "text/csv; charset=utf-8"
+	case ExportFormatNDJSON:
+		contentType = "application/x-ndjson"
+	case ExportFormatJSON:
+		contentType = "application/json"
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param format must be csv, ndjson or json"))
+		return
+	}
+	fields, err := parseExportFields(r.FormValue("fields"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return
+	}
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	var out io.Writer = w
+	var gw *gzip.Writer
+	if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gw = gzip.NewWriter(w)
+		out = gw
+	}
+	bw := bufio.NewWriter(out)
+
+	enc := newExportEncoder(bw, format, fields)
+	err = enc.begin()
+	if err == nil {
+		err = s.userRepo.Iterate(filter, exportBatchSize, func(users []store.User) error {
+			for i := range users {
+				if err := enc.encode(&users[i]); err != nil {
+					return err
+				}
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		// the status code is sent already, abort the response without the
+		// trailing flush so the client sees a broken body instead of a
+		// complete looking one
+		slog.Error("export users failed", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	enc.end()
+	bw.Flush()
+	if gw != nil {
+		gw.Close()
+	}
+}
+
+func exportFormatFromAccept(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(part))
+		switch mediaType {
+		case "text/csv":
+			return ExportFormatCSV
+		case "application/x-ndjson", "application/ndjson":
+			return ExportFormatNDJSON
+		case "application/json":
+			return ExportFormatJSON
+		}
+	}
+	return ""
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+func parseExportFields(value string) ([]exportField, error) {
+	if value == "" {
+		return exportFields, nil
+	}
+	var fields []exportField
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, f := range exportFields {
+			if f.name == name {
+				fields = append(fields, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("param fields invalid: unknown field %s", name)
+		}
+	}
+	return fields, nil
+}
+
+// parseUserFilter parses the ids, name, email, created_after and
+// created_before params, the times are in RFC 3339 format.
+func parseUserFilter(r *http.Request) (store.UserFilter, error) {
+	filter := store.UserFilter{
+		IDs:   formList(r, "ids"),
+		Name:  r.FormValue("name"),
+		Email: r.FormValue("email"),
+	}
+	for _, param := range []struct {
+		key string
+		t   *time.Time
+	}{
+		{key: "created_after", t: &filter.CreatedAfter},
+		{key: "created_before", t: &filter.CreatedBefore},
+	} {
+		value := r.FormValue(param.key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("param %s invalid: %s", param.key, value)
+		}
+		*param.t = t
+	}
+	return filter, nil
+}
+
+type exportEncoder struct {
+	w      *bufio.Writer
+	csv    *csv.Writer
+	format string
+	fields []exportField
+	count  int
+}
+
+func newExportEncoder(w *bufio.Writer, format string, fields []exportField) *exportEncoder {
+	enc := &exportEncoder{w: w, format: format, fields: fields}
+	if format == ExportFormatCSV {
+		enc.csv = csv.NewWriter(w)
+	}
+	return enc
+}
+
+func (e *exportEncoder) begin() error {
+	switch e.format {
+	case ExportFormatCSV:
+		header := make([]string, 0, len(e.fields))
+		for _, f := range e.fields {
+			header = append(header, f.name)
+		}
+		return e.csv.Write(header)
+	case ExportFormatJSON:
+		return e.w.WriteByte('[')
+	}
+	return nil
+}
+
+func (e *exportEncoder) encode(u *store.User) error {
+	e.count++
+	if e.format == ExportFormatCSV {
+		record := make([]string, 0, len(e.fields))
+		for _, f := range e.fields {
+			switch v := f.value(u).(type) {
+			case time.Time:
+				record = append(record, v.Format(time.RFC3339Nano))
+			case int:
+				record = append(record, strconv.Itoa(v))
+			default:
+				record = append(record, fmt.Sprint(v))
+			}
+		}
+		if err := e.csv.Write(record); err != nil {
+			return err
+		}
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	if e.format == ExportFormatJSON && e.count > 1 {
+		e.w.WriteByte(',')
+	}
+	// write the object by hand to keep the order of the selected fields
+	e.w.WriteByte('{')
+	for i, f := range e.fields {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		value, err := json.Marshal(f.value(u))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.w, "%q:", f.name)
+		e.w.Write(value)
+	}
+	e.w.WriteByte('}')
+	if e.format == ExportFormatNDJSON {
+		return e.w.WriteByte('\n')
+	}
+	return nil
+}
+
+func (e *exportEncoder) end() {
+	if e.format == ExportFormatJSON {
+		e.w.WriteByte(']')
+	}
+}
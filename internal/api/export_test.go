@@ -0,0 +1,114 @@
+package api
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"go.uber.org/mock/gomock"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+func (s *ServiceTestSuite) TestExportUsers() {
+	created := time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC)
+	users := []store.User{
+		{ID: "id1", Name: "liuliu", Email: "aa@bb.com", Age: 18, CreatedAt: created, UpdatedAt: created},
+		{ID: "id2", Name: "liu,hong", Email: "cc@dd.com", CreatedAt: created, UpdatedAt: created},
+	}
+	iterate := func(filter store.UserFilter, batchSize int, fn func(users []store.User) error) error {
+		s.Equal(exportBatchSize, batchSize)
+		if err := fn(users[:1]); err != nil {
+			return err
+		}
+		return fn(users[1:])
+	}
+
+	s.Run("invalid format", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=xml", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param format must be csv, ndjson or json"}`, w.Body.String())
+	})
+	s.Run("invalid fields", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=csv&fields=id,password", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param fields invalid: unknown field password"}`, w.Body.String())
+	})
+	s.Run("invalid created_after", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=csv&created_after=yesterday", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param created_after invalid: yesterday"}`, w.Body.String())
+	})
+	s.Run("csv", func() {
+		s.mockUserRepo.EXPECT().Iterate(store.UserFilter{Name: "liu", CreatedAfter: created}, exportBatchSize, gomock.Any()).DoAndReturn(iterate).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=csv&name=liu&created_after=2025-07-20T16:13:21Z", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues("text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		s.EqualValues("id,name,email,age,createdAt,updatedAt\n"+
+			"id1,liuliu,aa@bb.com,18,2025-07-20T16:13:21Z,2025-07-20T16:13:21Z\n"+
+			"id2,\"liu,hong\",cc@dd.com,0,2025-07-20T16:13:21Z,2025-07-20T16:13:21Z\n", w.Body.String())
+	})
+	s.Run("ndjson fields", func() {
+		s.mockUserRepo.EXPECT().Iterate(store.UserFilter{}, exportBatchSize, gomock.Any()).DoAndReturn(iterate).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?fields=email,id", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"email":"aa@bb.com","id":"id1"}`+"\n"+`{"email":"cc@dd.com","id":"id2"}`+"\n", w.Body.String())
+	})
+	s.Run("json gzip", func() {
+		s.mockUserRepo.EXPECT().Iterate(store.UserFilter{}, exportBatchSize, gomock.Any()).DoAndReturn(iterate).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=json&fields=id,age", nil)
+		req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.8")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues("gzip", w.Header().Get("Content-Encoding"))
+		gr, err := gzip.NewReader(w.Body)
+		s.Require().NoError(err)
+		data, err := io.ReadAll(gr)
+		s.Require().NoError(err)
+		s.EqualValues(`[{"id":"id1","age":18},{"id":"id2","age":0}]`, string(data))
+	})
+	s.Run("json empty", func() {
+		s.mockUserRepo.EXPECT().Iterate(store.UserFilter{Email: "ee@ff.com"}, exportBatchSize, gomock.Any()).Return(nil).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=json&email=ee@ff.com", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`[]`, w.Body.String())
+	})
+	s.Run("iterate error", func() {
+		s.mockUserRepo.EXPECT().Iterate(store.UserFilter{}, exportBatchSize, gomock.Any()).DoAndReturn(
+			func(filter store.UserFilter, batchSize int, fn func(users []store.User) error) error {
+				if err := fn(users[:1]); err != nil {
+					return err
+				}
+				return errors.New("connection reset")
+			}).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/export?format=json", nil)
+		w := httptest.NewRecorder()
+		s.PanicsWithValue(http.ErrAbortHandler, func() {
+			s.svc.ServeHTTP(w, req)
+		})
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`[{"id":"id1","name":"liuliu","email":"aa@bb.com","age":18,"createdAt":"2025-07-20T16:13:21Z","updatedAt":"2025-07-20T16:13:21Z"}`, w.Body.String())
+	})
+}
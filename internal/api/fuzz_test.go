@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/internal/store/storetest"
+)
+
+// fuzzService returns a func creating a service for every fuzz input, the
+// users are kept in memory so every input starts without users, the rest is
+// kept in one SQLite database of the fuzz test.
+func fuzzService(f *testing.F) func() *Service {
+	st := store.NewStore(storetest.OpenSQLite(f))
+	return func() *Service {
+		return NewService(&fuzzStore{Store: st, users: store.NewMemoryUserRepository()}, &config.Config{})
+	}
+}
+
+// fuzzStore is a Store keeping the users in memory and the rest in Store.
+type fuzzStore struct {
+	store.Store
+	users store.UserRepository
+}
+
+func (s *fuzzStore) Users() store.UserRepository {
+	return s.users
+}
+
+func (s *fuzzStore) Transaction(fn func(tx store.Store) error) error {
+	return s.Store.Transaction(func(tx store.Store) error {
+		return fn(&fuzzStore{Store: tx, users: s.users})
+	})
+}
+
+// checkResponse fails the test if the service failed on bad input rather than
+// rejecting it: a 4xx must be a json error, and a 500 is only allowed for a
+// user or webhook which is not found, which is how the API reports them.
+func checkResponse(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	switch {
+	case w.Code == http.StatusInternalServerError:
+		if w.Body.String() != `{"error":"record not found"}` {
+			t.Fatalf("internal server error: %s", w.Body.String())
+		}
+	case w.Code >= 400:
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+			t.Fatalf("status %d without error: %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func serveForm(svc *Service, method, path, query string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://127.0.0.1:8888"+path, strings.NewReader(form.Encode()))
+	req.URL.RawQuery = query
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	svc.ServeHTTP(w, req)
+	return w
+}
+
+func FuzzCreateUser(f *testing.F) {
+	f.Add("liuliu", "aa@bb.com", "18")
+	f.Add("", "aa@bb.com", "")
+	f.Add("liuliu", "", "-1")
+	f.Add("刘六", "aa@bb.com", "ten")
+	f.Add("liuliu", "aa@bb.com", "99999999999999999999")
+	newService := fuzzService(f)
+	f.Fuzz(func(t *testing.T, name, email, age string) {
+		svc := newService()
+		w := serveForm(svc, http.MethodPost, "/user/create", "", url.Values{"name": {name}, "email": {email}, "age": {age}})
+		checkResponse(t, w)
+		if w.Code != http.StatusOK {
+			return
+		}
+
+		var created struct {
+			Data User `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("parse response failed: %v", err)
+		}
+		w = serveForm(svc, http.MethodGet, "/user/get", url.Values{"id": {created.Data.ID}}.Encode(), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("get created user failed: %d %s", w.Code, w.Body.String())
+		}
+		var got struct {
+			Data User `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("parse response failed: %v", err)
+		}
+		if got.Data.Name != name || got.Data.Email != email {
+			t.Errorf("user %q %q created as %q %q", name, email, got.Data.Name, got.Data.Email)
+		}
+	})
+}
+
+func FuzzImportUsers(f *testing.F) {
+	f.Add("csv", []byte("name,email,age\nliuliu,aa@bb.com,18\nzhangsan,cc@dd.com,\n"))
+	f.Add("csv", []byte("email,name\naa@bb.com,liuliu\naa@bb.com,liuliu\n"))
+	f.Add("csv", []byte("name,email\n\"unterminated,aa@bb.com\n"))
+	f.Add("ndjson", []byte(`{"name":"liuliu","email":"aa@bb.com","age":18}`+"\n"+`{"name":"zhangsan"}`+"\n"))
+	f.Add("ndjson", []byte(`{"name":1}`+"\n[]\n"))
+	f.Add("xml", []byte("<users/>"))
+	newService := fuzzService(f)
+	f.Fuzz(func(t *testing.T, format string, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:8888/user/import", strings.NewReader(string(body)))
+		req.URL.RawQuery = url.Values{"format": {format}}.Encode()
+		w := httptest.NewRecorder()
+		newService().ServeHTTP(w, req)
+		checkResponse(t, w)
+	})
+}
+
+// FuzzHandlers sends arbitrary queries and forms to every route but the user
+// event stream, which does not end.
+func FuzzHandlers(f *testing.F) {
+	f.Add(uint8(0), true, "name=liuliu&email=aa@bb.com&age=18", "")
+	f.Add(uint8(1), false, "id=0198271f-bc9d-74ac-a63b-41cf2c6c2f82", "")
+	f.Add(uint8(4), false, "page=0&page_size=-1", "")
+	f.Add(uint8(5), true, "", "ids=a,b,c&emails=")
+	f.Add(uint8(6), true, "name=liu&dry_run=maybe", "")
+	f.Add(uint8(7), true, "created_after=yesterday", "set_age=old")
+	f.Add(uint8(9), false, "format=json&fields=name,password&gzip=1", "")
+	f.Add(uint8(11), false, "created_before=2025-07-20T16:13:21%2B08:00&page=x", "")
+	f.Add(uint8(12), true, "", "url=ftp://example.com&events=user.created,user.exploded")
+	f.Add(uint8(15), true, "id=-1", "")
+	f.Add(uint8(16), false, "%zz&;=", "")
+	newService := fuzzService(f)
+	f.Fuzz(func(t *testing.T, route uint8, post bool, query, form string) {
+		svc := newService()
+		var routes []string
+		for _, r := range svc.routes {
+			if r.pattern != "/user/watch" {
+				routes = append(routes, r.pattern)
+			}
+		}
+		method := http.MethodGet
+		if post {
+			method = http.MethodPost
+		}
+		req := httptest.NewRequest(method, "http://127.0.0.1:8888"+routes[int(route)%len(routes)], strings.NewReader(form))
+		req.URL.RawQuery = query
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		svc.ServeHTTP(w, req)
+		checkResponse(t, w)
+	})
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the api responses")
+
+var (
+	uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	timePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+)
+
+// goldenHeaders are the response headers kept in the golden files.
+var goldenHeaders = []string{"Content-Type", "Content-Disposition", "Allow", HeaderRequestID}
+
+// goldenResponse is the content of a golden file, Body is the decoded json
+// body or the text of other bodies.
+type goldenResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   interface{}       `json:"body"`
+}
+
+// normalizer replaces the values which change on every run: uuids become
+// <uuid-N> numbered in the order they are seen, so equal ids stay equal,
+// timestamps become <time> and request ids <request-id>.
+type normalizer struct {
+	uuids map[string]string
+}
+
+func (n *normalizer) text(s string) string {
+	s = uuidPattern.ReplaceAllStringFunc(s, func(id string) string {
+		if _, ok := n.uuids[id]; !ok {
+			n.uuids[id] = fmt.Sprintf("<uuid-%d>", len(n.uuids)+1)
+		}
+		return n.uuids[id]
+	})
+	return timePattern.ReplaceAllString(s, "<time>")
+}
+
+// requestIDs replaces the values of the requestId fields of the decoded json
+// v.
+func requestIDs(key string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		if key == "requestId" {
+			return "<request-id>"
+		}
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = requestIDs(k, value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = requestIDs(key, value)
+		}
+	}
+	return v
+}
+
+// normalizeResponse returns the golden content of the response w.
+func normalizeResponse(w *httptest.ResponseRecorder) goldenResponse {
+	n := &normalizer{uuids: make(map[string]string)}
+	resp := goldenResponse{Status: w.Code}
+	// the uuids are numbered in the order of the body, not of the decoded maps
+	text := n.text(w.Body.String())
+	var body interface{}
+	if err := json.Unmarshal([]byte(text), &body); err == nil {
+		resp.Body = requestIDs("", body)
+	} else {
+		resp.Body = text
+	}
+	for _, key := range goldenHeaders {
+		value := w.Header().Get(key)
+		if value == "" {
+			continue
+		}
+		if resp.Header == nil {
+			resp.Header = make(map[string]string)
+		}
+		if key == HeaderRequestID {
+			value = "<request-id>"
+		}
+		resp.Header[key] = value
+	}
+	return resp
+}
+
+// assertGolden compares the response w with testdata/golden/<name>.json, with
+// -update the golden file is rewritten instead.
+func assertGolden(t *testing.T, name string, w *httptest.ResponseRecorder) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".json")
+	got := normalizeResponse(w)
+	data, err := marshalGolden(got)
+	if err != nil {
+		t.Fatalf("marshal response failed: %v", err)
+	}
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("create golden dir failed: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("write golden file failed: %v", err)
+		}
+		return
+	}
+
+	wantData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file failed: %v, create it with -update", err)
+	}
+	var want, gotValue interface{}
+	if err := json.Unmarshal(wantData, &want); err != nil {
+		t.Fatalf("parse golden file %s failed: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &gotValue); err != nil {
+		t.Fatalf("parse response failed: %v", err)
+	}
+	if diff := jsonDiff("$", want, gotValue); len(diff) > 0 {
+		t.Errorf("response differs from %s, run with -update if the change is expected:\n%s", path, strings.Join(diff, "\n"))
+	}
+}
+
+func marshalGolden(resp goldenResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(resp); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonDiff returns the differences of the decoded json values, one line for
+// each path which differs.
+func jsonDiff(path string, want, got interface{}) []string {
+	switch want := want.(type) {
+	case map[string]interface{}:
+		got, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(want)+len(got))
+		for k := range want {
+			keys = append(keys, k)
+		}
+		for k := range got {
+			if _, ok := want[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var diff []string
+		for _, k := range keys {
+			wantValue, inWant := want[k]
+			gotValue, inGot := got[k]
+			switch {
+			case !inGot:
+				diff = append(diff, fmt.Sprintf("%s.%s: missing, want %s", path, k, jsonString(wantValue)))
+			case !inWant:
+				diff = append(diff, fmt.Sprintf("%s.%s: unexpected %s", path, k, jsonString(gotValue)))
+			default:
+				diff = append(diff, jsonDiff(path+"."+k, wantValue, gotValue)...)
+			}
+		}
+		return diff
+	case []interface{}:
+		got, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		var diff []string
+		for i := 0; i < len(want) || i < len(got); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(got):
+				diff = append(diff, fmt.Sprintf("%s: missing, want %s", elemPath, jsonString(want[i])))
+			case i >= len(want):
+				diff = append(diff, fmt.Sprintf("%s: unexpected %s", elemPath, jsonString(got[i])))
+			default:
+				diff = append(diff, jsonDiff(elemPath, want[i], got[i])...)
+			}
+		}
+		return diff
+	default:
+		if want == got {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("%s: want %s, got %s", path, jsonString(want), jsonString(got))}
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func TestJSONDiff(t *testing.T) {
+	parse := func(s string) interface{} {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatalf("parse %s failed: %v", s, err)
+		}
+		return v
+	}
+	for _, tc := range []struct {
+		name      string
+		want, got string
+		diff      []string
+	}{
+		{name: "equal", want: `{"a":[1,{"b":"c"}]}`, got: `{"a":[1,{"b":"c"}]}`},
+		{name: "value", want: `{"a":{"b":"c"}}`, got: `{"a":{"b":"d"}}`, diff: []string{`$.a.b: want "c", got "d"`}},
+		{name: "keys", want: `{"a":1,"b":2}`, got: `{"b":2,"c":3}`, diff: []string{`$.a: missing, want 1`, `$.c: unexpected 3`}},
+		{name: "array", want: `[1,2]`, got: `[1,3,4]`, diff: []string{`$[1]: want 2, got 3`, `$[2]: unexpected 4`}},
+		{name: "type", want: `{"a":[1]}`, got: `{"a":{"b":1}}`, diff: []string{`$.a: want [1], got {"b":1}`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := jsonDiff("$", parse(tc.want), parse(tc.got))
+			if strings.Join(diff, "\n") != strings.Join(tc.diff, "\n") {
+				t.Errorf("diff = %q, want %q", diff, tc.diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeResponse(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(HeaderRequestID, "0198271f-bc9d-74ac-a63b-41cf2c6c2f80")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+	w.WriteString(`{"data":{"users":[` +
+		`{"id":"0198271f-bc9d-74ac-a63b-41cf2c6c2f82","createdAt":"2025-07-20T16:13:21.123+08:00"},` +
+		`{"id":"0198271f-bc9d-74ac-a63b-41cf2c6c2f83","createdAt":"2025-07-20T08:13:21Z"}],` +
+		`"logs":[{"targetId":"0198271f-bc9d-74ac-a63b-41cf2c6c2f82","requestId":"req-1"}]}}`)
+	data, err := marshalGolden(normalizeResponse(w))
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var want interface{}
+	json.Unmarshal([]byte(`{
+		"status": 201,
+		"header": {"Content-Type": "application/json", "X-Request-ID": "<request-id>"},
+		"body": {"data": {
+			"users": [{"id": "<uuid-1>", "createdAt": "<time>"}, {"id": "<uuid-2>", "createdAt": "<time>"}],
+			"logs": [{"targetId": "<uuid-1>", "requestId": "<request-id>"}]
+		}}
+	}`), &want)
+	if diff := jsonDiff("$", want, got); len(diff) > 0 {
+		t.Errorf("normalized response differs:\n%s", strings.Join(diff, "\n"))
+	}
+
+	w = httptest.NewRecorder()
+	w.WriteString("id,createdAt\n0198271f-bc9d-74ac-a63b-41cf2c6c2f82,2025-07-20T16:13:21+08:00\n")
+	if body := normalizeResponse(w).Body; body != "id,createdAt\n<uuid-1>,<time>\n" {
+		t.Errorf("normalized body = %q", body)
+	}
+}
+
+func (s *ServiceTestSuite) TestResponsesGolden() {
+	s.useMemoryUsers()
+	s.expectChanges(1)
+	s.expectChanges(1)
+	do := func(name, method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://127.0.0.1:8888"+target, nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		assertGolden(s.T(), name, w)
+		return w
+	}
+
+	w := do("create", "POST", "/user/create?name=liuliu&email=aa@bb.com&age=18")
+	var created struct {
+		Data User `json:"data"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	do("create_second", "POST", "/user/create?name=zhangsan&email=cc@dd.com")
+	do("create_exists", "POST", "/user/create?name=lisi&email=aa@bb.com")
+	do("create_invalid", "POST", "/user/create?name=lisi")
+	do("get", "GET", "/user/get?id="+created.Data.ID)
+	do("list", "GET", "/user/list")
+	do("batch_get", "POST", "/user/batch_get?emails=aa@bb.com,ee@ff.com")
+	do("export_csv", "GET", "/user/export?format=csv&name=liu")
+	do("method_not_allowed", "GET", "/user/batch_delete?name=liuliu")
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/pkg/userpb"
+)
+
+const (
+	// MetadataActor and MetadataRequestID are the gRPC metadata of the
+	// HeaderActor and HeaderRequestID headers.
+	MetadataActor     = "x-actor"
+	MetadataRequestID = "x-request-id"
+)
+
+// NewGRPCServer returns a gRPC server of the users, with the same operations
+// and validation as the HTTP API of svc.
+func NewGRPCServer(svc *Service, opts ...grpc.ServerOption) *grpc.Server {
+	gs := grpc.NewServer(append(opts, grpc.ChainUnaryInterceptor(svc.requestInfoInterceptor))...)
+	userpb.RegisterUserServiceServer(gs, &grpcService{svc: svc})
+	return gs
+}
+
+type grpcService struct {
+	userpb.UnimplementedUserServiceServer
+	svc *Service
+}
+
+// requestInfoInterceptor adds the actor and request id of the metadata to the
+// context, the request id is generated if the client does not send one and is
+// sent back in the header.
+func (s *Service) requestInfoInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	requestID := first(MetadataRequestID)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = s.ids.NewID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, requestID))
+	actor := first(MetadataActor)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	ctx = context.WithValue(ctx, requestInfoKey{}, requestInfo{actor: actor, requestID: requestID})
+	return handler(ctx, req)
+}
+
+func (s *grpcService) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.User, error) {
+	user, err := s.svc.CreateUser(ctx, req.GetName(), req.GetEmail(), int(req.GetAge()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return convertModelUserPB(user), nil
+}
+
+func (s *grpcService) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	user, err := s.svc.GetUser(req.GetId(), req.GetEmail())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return convertModelUserPB(user), nil
+}
+
+func (s *grpcService) UpdateUser(ctx context.Context, req *userpb.UpdateUserRequest) (*userpb.User, error) {
+	var age *int
+	if req.Age != nil {
+		v := int(req.GetAge())
+		age = &v
+	}
+	user, err := s.svc.UpdateUser(ctx, req.GetId(), req.GetName(), req.GetEmail(), age)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return convertModelUserPB(user), nil
+}
+
+func (s *grpcService) DeleteUser(ctx context.Context, req *userpb.DeleteUserRequest) (*userpb.DeleteUserResponse, error) {
+	if err := s.svc.DeleteUser(ctx, req.GetId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &userpb.DeleteUserResponse{}, nil
+}
+
+func (s *grpcService) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	users, total, err := s.svc.ListUsers(int(req.GetPage()), int(req.GetPageSize()))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &userpb.ListUsersResponse{Total: total, Users: make([]*userpb.User, 0, len(users))}
+	for i := range users {
+		resp.Users = append(resp.Users, convertModelUserPB(&users[i]))
+	}
+	return resp, nil
+}
+
+// grpcError returns the status error of an error of the user operations, the
+// message is the same as the error of the HTTP API.
+func grpcError(err error) error {
+	switch {
+	case isParamError(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func convertModelUserPB(u *store.User) *userpb.User {
+	return &userpb.User{
+		Id:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Age:       int32(u.Age),
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/pkg/client"
+	"go-unittest-best-practice/pkg/client/grpcclient"
+)
+
+// grpcClient returns a client of the gRPC server of the service, served on an
+// in memory listener.
+func (s *ServiceTestSuite) grpcClient(opts ...grpcclient.Option) client.Client {
+	lis := bufconn.Listen(1024 * 1024)
+	server := NewGRPCServer(s.svc)
+	go server.Serve(lis)
+	s.T().Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+	return grpcclient.New(conn, opts...)
+}
+
+func (s *ServiceTestSuite) TestGRPC() {
+	c := s.grpcClient(grpcclient.WithActor("admin"))
+	ctx := context.Background()
+	id := "0198271f-bc9d-74ac-a63b-41cf2c6c2f82"
+	createdAt := time.Unix(1752999201, 0)
+
+	s.Run("create", func() {
+		s.mockUserRepo.EXPECT().Create(gomock.Any()).Return(nil).Times(1)
+		s.mockAuditRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(logs []store.AuditLog) error {
+			s.Equal("admin", logs[0].Actor)
+			s.NotEmpty(logs[0].RequestID)
+			return nil
+		}).Times(1)
+		s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Len(1)).Return(nil).Times(1)
+		s.mockUserRepo.EXPECT().GetByEmail("aa@bb.com").Return(&store.User{
+			ID: id, Name: "liuliu", Email: "aa@bb.com", Age: 18, CreatedAt: createdAt, UpdatedAt: createdAt,
+		}, nil).Times(1)
+
+		user, err := c.UserCreateContext(ctx, client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18})
+		s.Require().NoError(err)
+		s.Equal(&client.User{ID: id, Name: "liuliu", Email: "aa@bb.com", Age: 18, CreatedAt: createdAt, UpdatedAt: createdAt}, user)
+	})
+	s.Run("create invalid", func() {
+		_, err := c.UserCreateContext(ctx, client.User{Email: "aa@bb.com"})
+		s.EqualError(err, "param name not set")
+		s.Equal(codes.InvalidArgument, status.Code(err))
+	})
+	s.Run("get not found", func() {
+		s.mockUserRepo.EXPECT().GetByID(id).Return(nil, gorm.ErrRecordNotFound).Times(1)
+		_, err := c.UserGetContext(ctx, id)
+		s.EqualError(err, "record not found")
+		s.Equal(codes.NotFound, status.Code(err))
+	})
+	s.Run("update", func() {
+		s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{ID: id, Name: "liuliu", Age: 18}, nil).Times(1)
+		s.mockUserRepo.EXPECT().Update(gomock.Any()).DoAndReturn(func(u *store.User) error {
+			s.Equal("liuliu", u.Name)
+			s.Equal(20, u.Age)
+			return nil
+		}).Times(1)
+		s.expectChanges(1)
+		s.Require().NoError(c.UserUpdateContext(ctx, client.User{ID: id, Age: 20}))
+	})
+	s.Run("delete", func() {
+		s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{ID: id}, nil).Times(1)
+		s.mockUserRepo.EXPECT().DeleteByID(id).Return(nil).Times(1)
+		s.expectChanges(1)
+		s.Require().NoError(c.UserDeleteContext(ctx, id))
+	})
+	s.Run("list", func() {
+		s.mockUserRepo.EXPECT().List(1, 100).Return([]store.User{{ID: id, Name: "liuliu", CreatedAt: createdAt, UpdatedAt: createdAt}}, int64(1), nil).Times(1)
+		users, total, err := c.UserListContext(ctx)
+		s.Require().NoError(err)
+		s.EqualValues(1, total)
+		s.Equal([]client.User{{ID: id, Name: "liuliu", CreatedAt: createdAt, UpdatedAt: createdAt}}, users)
+	})
+	s.Run("unsupported", func() {
+		_, _, err := c.UserBatchGet(ctx, []string{id}, nil)
+		s.ErrorIs(err, grpcclient.ErrUnsupported)
+		_, err = c.UserBatchDelete(ctx, client.UserFilter{IDs: []string{id}}, false)
+		s.ErrorIs(err, grpcclient.ErrUnsupported)
+		_, err = c.UserBatchUpdate(ctx, client.UserFilter{IDs: []string{id}}, client.UserFields{}, false)
+		s.ErrorIs(err, grpcclient.ErrUnsupported)
+		_, err = c.UserImport(ctx, strings.NewReader(""), client.ImportFormatCSV, false)
+		s.ErrorIs(err, grpcclient.ErrUnsupported)
+		s.ErrorIs(c.UserExport(ctx, io.Discard, client.ExportOptions{}), grpcclient.ErrUnsupported)
+		_, err = c.WatchUsers(ctx)
+		s.ErrorIs(err, grpcclient.ErrUnsupported)
+	})
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-unittest-best-practice/internal/idgen"
+	"go-unittest-best-practice/internal/store"
+)
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+
+	importBatchSize = 500
+)
+
+type ImportReport struct {
+	DryRun  bool        `json:"dryRun"`
+	Created int         `json:"created"`
+	Skipped int         `json:"skipped"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+// ImportRow is the result of one input row, Line is the line number of the row
+// in the input.
+type ImportRow struct {
+	Line   int    `json:"line"`
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type importRecord struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+// importUsers imports users from a csv body with a header row or a ndjson
+// body. The format is read from the format param or the Content-Type header,
+// with dry_run=true rows are validated but not created.
+func (s *Service) importUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.error(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = ImportFormatCSV
+		case "application/x-ndjson", "application/ndjson":
+			format = ImportFormatNDJSON
+		}
+	}
+	if format != ImportFormatCSV && format != ImportFormatNDJSON {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param format must be csv or ndjson"))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	importer := &userImporter{
+		store:  s.store,
+		ids:    s.ids,
+		info:   requestInfoFrom(r.Context()),
+		report: &ImportReport{DryRun: dryRun, Rows: []ImportRow{}},
+		emails: make(map[string]struct{}),
+	}
+	var err error
+	if format == ImportFormatCSV {
+		err = importer.readCSV(r.Body)
+	} else {
+		err = importer.readNDJSON(r.Body)
+	}
+	if err == nil {
+		err = importer.flush()
+	}
+	if err != nil {
+		var inputErr inputError
+		if errors.As(err, &inputErr) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		s.error(w, err)
+		return
+	}
+	sort.SliceStable(importer.report.Rows, func(i, j int) bool {
+		return importer.report.Rows[i].Line < importer.report.Rows[j].Line
+	})
+	s.data(w, importer.report)
+}
+
+type pendingRow struct {
+	line int
+	user store.User
+}
+
+// inputError is an error of the import input, rather than of the server.
+type inputError struct {
+	error
+}
+
+// userImporter validates rows and creates them in batches.
+type userImporter struct {
+	store  store.Store
+	ids    idgen.IDGenerator
+	info   requestInfo
+	report *ImportReport
+	// emails are the emails seen in the input, a later row with the same email
+	// is skipped.
+	emails  map[string]struct{}
+	pending []pendingRow
+}
+
+func (im *userImporter) readCSV(body io.Reader) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return inputError{fmt.Errorf("read csv header failed: %v", err)}
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return inputError{fmt.Errorf("csv header must contain name and email")}
+	}
+	if _, ok := columns["email"]; !ok {
+		return inputError{fmt.Errorf("csv header must contain name and email")}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return inputError{fmt.Errorf("read csv failed: %v", err)}
+			}
+			im.fail(parseErr.Line, "", fmt.Sprintf("invalid csv: %v", parseErr.Err))
+			continue
+		}
+		// the position is only known for a record which is read
+		line, _ := reader.FieldPos(0)
+
+		rec := importRecord{
+			Name:  field(record, "name"),
+			Email: field(record, "email"),
+		}
+		if age := field(record, "age"); age != "" {
+			rec.Age, err = strconv.Atoi(age)
+			if err != nil {
+				im.fail(line, rec.Email, fmt.Sprintf("age invalid: %s", age))
+				continue
+			}
+		}
+		if err := im.add(line, rec); err != nil {
+			return err
+		}
+	}
+}
+
+func (im *userImporter) readNDJSON(body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+		var rec importRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			im.fail(line, "", fmt.Sprintf("invalid json: %v", err))
+			continue
+		}
+		if err := im.add(line, rec); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return inputError{fmt.Errorf("read ndjson failed: %v", err)}
+	}
+	return nil
+}
+
+// add validates rec and queues it for creation, the queue is flushed when a
+// batch is full.
+func (im *userImporter) add(line int, rec importRecord) error {
+	switch {
+	case rec.Name == "":
+		im.fail(line, rec.Email, "name not set")
+		return nil
+	case rec.Email == "":
+		im.fail(line, rec.Email, "email not set")
+		return nil
+	case rec.Age < 0:
+		im.fail(line, rec.Email, fmt.Sprintf("age invalid: %d", rec.Age))
+		return nil
+	case !utf8.ValidString(rec.Name) || !utf8.ValidString(rec.Email):
+		im.fail(line, strings.ToValidUTF8(rec.Email, "\uFFFD"), "name or email not utf-8")
+		return nil
+	}
+	if _, ok := im.emails[rec.Email]; ok {
+		im.skip(line, rec.Email, "duplicate email in input")
+		return nil
+	}
+	im.emails[rec.Email] = struct{}{}
+
+	im.pending = append(im.pending, pendingRow{
+		line: line,
+		user: store.User{
+			ID:    im.ids.NewID(),
+			Name:  rec.Name,
+			Email: rec.Email,
+			Age:   rec.Age,
+		},
+	})
+	if len(im.pending) >= importBatchSize {
+		return im.flush()
+	}
+	return nil
+}
+
+// flush creates the queued rows whose email does not exist yet. If the batch
+// insert fails, the rows are created one by one to find the failed rows.
+func (im *userImporter) flush() error {
+	pending := im.pending
+	im.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+
+	emails := make([]string, 0, len(pending))
+	for _, p := range pending {
+		emails = append(emails, p.user.Email)
+	}
+	existing, err := im.store.Users().GetByEmails(emails)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]struct{}, len(existing))
+	for _, u := range existing {
+		exists[u.Email] = struct{}{}
+	}
+
+	var rows []pendingRow
+	for _, p := range pending {
+		if _, ok := exists[p.user.Email]; ok {
+			im.skip(p.line, p.user.Email, "email already exists")
+			continue
+		}
+		rows = append(rows, p)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	if !im.report.DryRun {
+		users := make([]store.User, 0, len(rows))
+		for _, p := range rows {
+			users = append(users, p.user)
+		}
+		err := transact(im.store, im.info, func(tx store.Store, changes *changeSet) error {
+			if err := tx.Users().CreateBatch(users); err != nil {
+				return err
+			}
+			for i := range users {
+				changes.add(nil, &users[i])
+			}
+			return nil
+		})
+		if err != nil {
+			for _, p := range rows {
+				user := p.user
+				err := transact(im.store, im.info, func(tx store.Store, changes *changeSet) error {
+					if err := tx.Users().Create(&user); err != nil {
+						return err
+					}
+					changes.add(nil, &user)
+					return nil
+				})
+				if err != nil {
+					im.fail(p.line, p.user.Email, err.Error())
+					continue
+				}
+				im.created(p)
+			}
+			return nil
+		}
+	}
+	for _, p := range rows {
+		im.created(p)
+	}
+	return nil
+}
+
+func (im *userImporter) created(p pendingRow) {
+	im.report.Created++
+	row := ImportRow{Line: p.line, Email: p.user.Email, Status: ImportStatusCreated}
+	if !im.report.DryRun {
+		row.ID = p.user.ID
+	}
+	im.report.Rows = append(im.report.Rows, row)
+}
+
+func (im *userImporter) skip(line int, email, reason string) {
+	im.report.Skipped++
+	im.report.Rows = append(im.report.Rows, ImportRow{Line: line, Email: email, Status: ImportStatusSkipped, Reason: reason})
+}
+
+func (im *userImporter) fail(line int, email, reason string) {
+	im.report.Failed++
+	im.report.Rows = append(im.report.Rows, ImportRow{Line: line, Email: email, Status: ImportStatusFailed, Reason: reason})
+}
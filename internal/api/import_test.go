@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+func (s *ServiceTestSuite) TestImportUsers() {
+	s.Run("format not set", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import", strings.NewReader("name,email\n"))
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param format must be csv or ndjson"}`, w.Body.String())
+	})
+	s.Run("invalid csv header", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import?format=csv", strings.NewReader("name,age\nliuliu,10\n"))
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"csv header must contain name and email"}`, w.Body.String())
+	})
+	s.Run("csv", func() {
+		s.mockUserRepo.EXPECT().GetByEmails([]string{"aa@bb.com", "cc@dd.com"}).Return([]store.User{{ID: "exists", Email: "cc@dd.com"}}, nil).Times(1)
+		s.mockUserRepo.EXPECT().CreateBatch(gomock.Len(1)).DoAndReturn(func(users []store.User) error {
+			s.Equal("liuliu", users[0].Name)
+			s.Equal("aa@bb.com", users[0].Email)
+			s.Equal(18, users[0].Age)
+			s.NotEmpty(users[0].ID)
+			return nil
+		}).Times(1)
+		s.expectChanges(1)
+
+		body := "name,email,age\nliuliu,aa@bb.com,18\n,ee@ff.com,1\nliuliu2,aa@bb.com,\nliuliu3,cc@dd.com,20\n"
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), `"dryRun":false,"created":1,"skipped":2,"failed":1`)
+		s.Contains(w.Body.String(), `{"line":3,"email":"ee@ff.com","status":"failed","reason":"name not set"}`)
+		s.Contains(w.Body.String(), `{"line":4,"email":"aa@bb.com","status":"skipped","reason":"duplicate email in input"}`)
+		s.Contains(w.Body.String(), `{"line":5,"email":"cc@dd.com","status":"skipped","reason":"email already exists"}`)
+	})
+	s.Run("invalid csv row", func() {
+		body := "name,email\n\"liuliu,aa@bb.com\n"
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import?format=csv", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"dryRun":false,"created":0,"skipped":0,"failed":1,"rows":[{"line":2,"email":"","status":"failed","reason":"invalid csv: extraneous or missing \" in quoted-field"}]}}`, w.Body.String())
+	})
+	s.Run("not utf-8", func() {
+		body := "name,email\n\xfe,aa@bb.com\nliuliu,\xff@bb.com\n"
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import?format=csv", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"dryRun":false,"created":0,"skipped":0,"failed":2,"rows":[{"line":2,"email":"aa@bb.com","status":"failed","reason":"name or email not utf-8"},{"line":3,"email":"`+"\uFFFD"+`@bb.com","status":"failed","reason":"name or email not utf-8"}]}}`, w.Body.String())
+	})
+	s.Run("ndjson dry run", func() {
+		s.mockUserRepo.EXPECT().GetByEmails([]string{"aa@bb.com"}).Return(nil, nil).Times(1)
+
+		body := `{"name":"liuliu","email":"aa@bb.com"}` + "\n" + `{"name":` + "\n"
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import?format=ndjson&dry_run=true", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"dryRun":true,"created":1,"skipped":0,"failed":1,"rows":[{"line":1,"email":"aa@bb.com","status":"created"},{"line":2,"email":"","status":"failed","reason":"invalid json: unexpected end of JSON input"}]}}`, w.Body.String())
+	})
+	s.Run("batch insert failed", func() {
+		s.mockUserRepo.EXPECT().GetByEmails(gomock.Len(2)).Return(nil, nil).Times(1)
+		s.mockUserRepo.EXPECT().CreateBatch(gomock.Len(2)).Return(gorm.ErrDuplicatedKey).Times(1)
+		s.mockUserRepo.EXPECT().Create(gomock.Any()).Return(nil).Times(1)
+		s.mockUserRepo.EXPECT().Create(gomock.Any()).Return(gorm.ErrDuplicatedKey).Times(1)
+		s.expectChanges(1)
+
+		body := "name,email\nliuliu,aa@bb.com\nliuliu2,cc@dd.com\n"
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/import?format=csv", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.Contains(w.Body.String(), `"created":1,"skipped":0,"failed":1`)
+		s.Contains(w.Body.String(), `{"line":3,"email":"cc@dd.com","status":"failed","reason":"duplicated key not allowed"}`)
+	})
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 document of the HTTP API, openapi_test.go
+// checks it against the routes and the params registered by NewService.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the OpenAPI document of the HTTP API.
+func OpenAPISpec() []byte {
+	return append([]byte(nil), openAPISpec...)
+}
+
+// openAPI serves the OpenAPI document, it is not wrapped in a DataResponse.
+func (s *Service) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/mock/gomock"
+
+	"go-unittest-best-practice/internal/outbox"
+)
+
+type openAPIDoc struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Parameters []struct {
+		Name string `json:"name"`
+		In   string `json:"in"`
+	} `json:"parameters"`
+	RequestBody struct {
+		Content map[string]struct {
+			Schema struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+// openAPISchemaTypes are the types of the documented schemas, the schemas of
+// nil types are data of map literals.
+var openAPISchemaTypes = map[string]interface{}{
+	"ErrorResponse":        ErrorResponse{},
+	"User":                 User{},
+	"UserList":             nil,
+	"BatchGetResponseData": BatchGetResponseData{},
+	"BatchResult":          BatchResult{},
+	"ImportRecord":         importRecord{},
+	"ImportReport":         ImportReport{},
+	"ImportRow":            ImportRow{},
+	"AuditLog":             AuditLog{},
+	"AuditLogList":         nil,
+	"Webhook":              Webhook{},
+	"WebhookDelivery":      WebhookDelivery{},
+	"DeliveryList":         nil,
+	"Event":                outbox.Event{},
+}
+
+func (s *ServiceTestSuite) loadOpenAPI() *openAPIDoc {
+	var doc openAPIDoc
+	s.Require().NoError(json.Unmarshal(OpenAPISpec(), &doc))
+	return &doc
+}
+
+func (s *ServiceTestSuite) TestOpenAPI() {
+	doc := s.loadOpenAPI()
+
+	s.Run("serve", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/openapi.json", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.Equal("application/json", w.Header().Get("Content-Type"))
+		s.JSONEq(string(openAPISpec), w.Body.String())
+		s.Equal("3.0.3", doc.OpenAPI)
+	})
+	s.Run("routes", func() {
+		paths := make([]string, 0, len(doc.Paths))
+		for path := range doc.Paths {
+			paths = append(paths, path)
+		}
+		patterns := make([]string, 0, len(s.svc.routes))
+		for _, r := range s.svc.routes {
+			patterns = append(patterns, r.pattern)
+		}
+		s.ElementsMatch(patterns, paths)
+	})
+	s.Run("refs", func() {
+		var raw map[string]interface{}
+		s.Require().NoError(json.Unmarshal(openAPISpec, &raw))
+		for _, m := range regexp.MustCompile(`"\$ref": "#/([^"]+)"`).FindAllStringSubmatch(string(openAPISpec), -1) {
+			var node interface{} = raw
+			for _, key := range strings.Split(m[1], "/") {
+				obj, _ := node.(map[string]interface{})
+				node = obj[key]
+			}
+			s.NotNil(node, "ref %s", m[1])
+		}
+	})
+	s.Run("schemas", func() {
+		for name, schema := range doc.Components.Schemas {
+			v, ok := openAPISchemaTypes[name]
+			s.True(ok, "schema %s has no type", name)
+			if v == nil {
+				continue
+			}
+			props := make([]string, 0, len(schema.Properties))
+			for prop := range schema.Properties {
+				props = append(props, prop)
+			}
+			s.ElementsMatch(jsonFields(reflect.TypeOf(v)), props, "schema %s", name)
+		}
+	})
+	s.Run("params", func() {
+		for _, r := range s.svc.routes {
+			ops := doc.Paths[r.pattern]
+			documented := make(map[string]struct{})
+			for _, op := range ops {
+				for _, p := range op.Parameters {
+					if p.In == "query" {
+						documented[p.Name] = struct{}{}
+					}
+				}
+				for _, content := range op.RequestBody.Content {
+					for name := range content.Schema.Properties {
+						documented[name] = struct{}{}
+					}
+				}
+			}
+			s.ElementsMatch(r.params, sortedKeys(documented), "path %s", r.pattern)
+		}
+	})
+	s.Run("methods", func() {
+		dbErr := errors.New("db error")
+		s.mockUserRepo.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, int64(0), dbErr).AnyTimes()
+		s.mockAuditRepo.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), dbErr).AnyTimes()
+		s.mockWebhooks.EXPECT().List().Return(nil, dbErr).AnyTimes()
+		s.mockWebhooks.EXPECT().ListDeliveries(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, int64(0), dbErr).AnyTimes()
+		s.mockOutboxRepo.EXPECT().LastID().Return(int64(0), dbErr).AnyTimes()
+
+		// a route documenting 405 rejects any method but POST, the others
+		// accept a GET, and the status of a GET without params is documented
+		for path, ops := range doc.Paths {
+			postOnly := false
+			for _, op := range ops {
+				_, ok := op.Responses["405"]
+				postOnly = postOnly || ok
+			}
+			req := httptest.NewRequest("GET", "http://127.0.0.1:8888"+path, nil)
+			w := httptest.NewRecorder()
+			s.svc.ServeHTTP(w, req)
+			s.Equal(postOnly, w.Code == http.StatusMethodNotAllowed, "path %s", path)
+			documented := false
+			for _, op := range ops {
+				_, ok := op.Responses[strconv.Itoa(w.Code)]
+				documented = documented || ok
+			}
+			s.True(documented, "path %s status %d", path, w.Code)
+		}
+	})
+}
+
+// jsonFields returns the json names of the fields of struct type t.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -4,68 +4,132 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"go-unittest-best-practice/internal/clock"
 	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/idgen"
 	"go-unittest-best-practice/internal/store"
-
-	"github.com/google/uuid"
 )
 
 type Service struct {
 	mux  *http.ServeMux
-	conf *config.Config
+	conf atomic.Pointer[config.Config]
+	// routes are the registered routes of mux, all described by the OpenAPI
+	// document.
+	routes []route
 
+	store    store.Store
 	userRepo store.UserRepository
+	// clock and ids give the times and ids set by the service, the timestamps
+	// of the records are set by the clock of the store.
+	clock clock.Clock
+	ids   idgen.IDGenerator
+
+	// the intervals of the user event streams
+	watchPollInterval time.Duration
+	heartbeatInterval time.Duration
+}
+
+// route is a registered pattern with the query and form params its handler
+// reads, the OpenAPI document describes the same params.
+type route struct {
+	pattern string
+	params  []string
+}
+
+type Option func(s *Service)
+
+// WithClock sets the clock of the service, clock.System by default. Pass the
+// same clock to the store so the timestamps of the records agree.
+func WithClock(c clock.Clock) Option {
+	return func(s *Service) {
+		s.clock = c
+	}
 }
 
-func NewService(userRepo store.UserRepository, conf *config.Config) *Service {
+// WithIDGenerator sets the generator of the ids of the created users and
+// webhooks and of the request ids, idgen.UUIDv4 by default.
+func WithIDGenerator(ids idgen.IDGenerator) Option {
+	return func(s *Service) {
+		s.ids = ids
+	}
+}
+
+func NewService(st store.Store, conf *config.Config, opts ...Option) *Service {
 	mux := http.NewServeMux()
 	service := &Service{
-		mux:      mux,
-		conf:     conf,
-		userRepo: userRepo,
-	}
-	mux.HandleFunc("/user/create", service.createUser)
-	mux.HandleFunc("/user/get", service.getUser)
-	mux.HandleFunc("/user/update", service.updateUser)
-	mux.HandleFunc("/user/delete", service.deleteUser)
-	mux.HandleFunc("/user/list", service.listUser)
+		mux:               mux,
+		store:             st,
+		userRepo:          st.Users(),
+		clock:             clock.System,
+		ids:               idgen.UUIDv4,
+		watchPollInterval: time.Second,
+		heartbeatInterval: 15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(service)
+	}
+	service.conf.Store(conf)
+	service.handle("/user/create", service.createUser, "name", "email", "age")
+	service.handle("/user/get", service.getUser, "id", "email")
+	service.handle("/user/update", service.updateUser, "id", "name", "email", "age")
+	service.handle("/user/delete", service.deleteUser, "id")
+	service.handle("/user/list", service.listUser, "page", "page_size")
+	service.handle("/user/batch_get", service.batchGetUsers, "ids", "emails")
+	service.handle("/user/batch_delete", service.batchDeleteUsers,
+		"ids", "name", "email", "created_after", "created_before", "dry_run")
+	service.handle("/user/batch_update", service.batchUpdateUsers,
+		"ids", "name", "email", "created_after", "created_before", "set_name", "set_age", "dry_run")
+	service.handle("/user/import", service.importUsers, "format", "dry_run")
+	service.handle("/user/export", service.exportUsers,
+		"ids", "name", "email", "created_after", "created_before", "format", "fields")
+	service.handle("/user/watch", service.watchUsers, "types", "last_event_id")
+	service.handle("/audit", service.listAudit,
+		"user_id", "actor", "action", "created_after", "created_before", "page", "page_size")
+	service.handle("/webhook/create", service.createWebhook, "url", "events", "secret")
+	service.handle("/webhook/list", service.listWebhooks)
+	service.handle("/webhook/delete", service.deleteWebhook, "id")
+	service.handle("/webhook/deliveries", service.listDeliveries, "webhook_id", "event_id", "status", "page", "page_size")
+	service.handle("/webhook/redeliver", service.redeliver, "id")
+	service.handle("/openapi.json", service.openAPI)
 	return service
 }
 
+// handle registers the handler of pattern, params are the query and form
+// params the handler reads.
+func (s *Service) handle(pattern string, handler http.HandlerFunc, params ...string) {
+	s.mux.HandleFunc(pattern, handler)
+	s.routes = append(s.routes, route{pattern: pattern, params: params})
+}
+
+// SetConfig publishes a new config snapshot to the service, it is safe to call
+// while the service is serving requests.
+func (s *Service) SetConfig(conf *config.Config) {
+	s.conf.Store(conf)
+}
+
+// Config returns the current config snapshot.
+func (s *Service) Config() *config.Config {
+	return s.conf.Load()
+}
+
 func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.mux.ServeHTTP(w, s.withRequestInfo(w, r))
 }
 
 func (s *Service) createUser(w http.ResponseWriter, r *http.Request) {
-	name := r.FormValue("name")
-	if name == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.error(w, fmt.Errorf("param name not set"))
-		return
-	}
-	email := r.FormValue("email")
-	if email == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.error(w, fmt.Errorf("param email not set"))
-		return
-	}
-
-	err := s.userRepo.Create(&store.User{
-		ID:    uuid.NewString(),
-		Name:  name,
-		Email: email,
-	})
+	age, err := formInt(r, "age")
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		s.error(w, err)
 		return
 	}
-
-	user, err := s.userRepo.GetByEmail(email)
+	user, err := s.CreateUser(r.Context(), r.FormValue("name"), r.FormValue("email"), age)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(httpStatus(err))
 		s.error(w, err)
 		return
 	}
@@ -73,22 +137,9 @@ func (s *Service) createUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Service) getUser(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
-	email := r.FormValue("email")
-	if id == "" && email == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.error(w, fmt.Errorf("param id or email not set"))
-		return
-	}
-	var user *store.User
-	var err error
-	if id != "" {
-		user, err = s.userRepo.GetByID(id)
-	} else {
-		user, err = s.userRepo.GetByEmail(email)
-	}
+	user, err := s.GetUser(r.FormValue("id"), r.FormValue("email"))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(httpStatus(err))
 		s.error(w, err)
 		return
 	}
@@ -96,55 +147,50 @@ func (s *Service) getUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Service) updateUser(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
-	if id == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.error(w, fmt.Errorf("param id not set"))
-		return
-	}
-	name := r.FormValue("name")
-	if name == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		s.error(w, fmt.Errorf("param name not set"))
-		return
+	var age *int
+	if r.FormValue("age") != "" {
+		v, err := formInt(r, "age")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, err)
+			return
+		}
+		age = &v
 	}
-
-	user, err := s.userRepo.GetByID(id)
+	user, err := s.UpdateUser(r.Context(), r.FormValue("id"), r.FormValue("name"), r.FormValue("email"), age)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(httpStatus(err))
 		s.error(w, err)
 		return
 	}
+	s.data(w, convertModelUser(user))
+}
 
-	user.Name = name
-	err = s.userRepo.Update(user)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+func (s *Service) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.DeleteUser(r.Context(), r.FormValue("id")); err != nil {
+		w.WriteHeader(httpStatus(err))
 		s.error(w, err)
-		return
 	}
-
-	s.data(w, convertModelUser(user))
 }
 
-func (s *Service) deleteUser(w http.ResponseWriter, r *http.Request) {
-	id := r.FormValue("id")
-	if id == "" {
+// listUser lists the users of the page and page_size params, the first 100
+// users by default.
+func (s *Service) listUser(w http.ResponseWriter, r *http.Request) {
+	page, err := formInt(r, "page")
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		s.error(w, fmt.Errorf("param id not set"))
+		s.error(w, err)
 		return
 	}
-	err := s.userRepo.DeleteByID(id)
+	pageSize, err := formInt(r, "page_size")
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		s.error(w, err)
+		return
 	}
-}
-
-func (s *Service) listUser(w http.ResponseWriter, r *http.Request) {
-	users, total, err := s.userRepo.List(1, 100)
+	users, total, err := s.ListUsers(page, pageSize)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(httpStatus(err))
 		s.error(w, err)
 		return
 	}
@@ -158,6 +204,20 @@ func (s *Service) listUser(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// formInt parses the optional integer form value key, 0 is returned if it is
+// not set.
+func formInt(r *http.Request, key string) (int, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("param %s invalid: %s", key, value)
+	}
+	return i, nil
+}
+
 func (s *Service) data(w http.ResponseWriter, body interface{}) {
 	data, _ := json.Marshal(&DataResponse{Data: body})
 	w.Write(data)
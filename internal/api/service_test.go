@@ -1,13 +1,17 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"time"
 
 	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
 
+	"go-unittest-best-practice/internal/clock"
+	"go-unittest-best-practice/internal/idgen"
 	"go-unittest-best-practice/internal/store"
 )
 
@@ -19,10 +23,25 @@ func (s *ServiceTestSuite) TestCreateUser() {
 		s.EqualValues(http.StatusBadRequest, w.Code)
 		s.EqualValues(`{"error":"param name not set"}`, w.Body.String())
 	})
+	s.Run("param age invalid", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/create?name=liuliu&email=aa@bb.com&age=ten", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param age invalid: ten"}`, w.Body.String())
+	})
+	s.Run("param name not utf-8", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/create?name=%FF&email=aa@bb.com", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param name invalid: not utf-8"}`, w.Body.String())
+	})
 	s.Run("success", func() {
 		t := time.Unix(1752999201, 0)
 		id := "0198271f-bc9d-74ac-a63b-41cf2c6c2f82"
 		s.mockUserRepo.EXPECT().Create(gomock.Any()).Return(nil).Times(1)
+		s.expectChanges(1)
 		s.mockUserRepo.EXPECT().GetByEmail(gomock.Any()).Return(&store.User{
 			ID:        id,
 			Name:      "liuliu",
@@ -78,6 +97,7 @@ func (s *ServiceTestSuite) TestUpdateUser() {
 		UpdatedAt: t,
 	}, nil).Times(1)
 	s.mockUserRepo.EXPECT().Update(gomock.Any()).Return(nil).Times(1)
+	s.expectChanges(1)
 
 	req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/update?id=0198271f-bc9d-74ac-a63b-41cf2c6c2f82&name=liuliu2", nil)
 	w := httptest.NewRecorder()
@@ -86,14 +106,67 @@ func (s *ServiceTestSuite) TestUpdateUser() {
 	s.EqualValues(`{"data":{"id":"0198271f-bc9d-74ac-a63b-41cf2c6c2f82","name":"liuliu2","email":"aa@bb.com","age":0,"createdAt":"2025-07-20T16:13:21+08:00","updatedAt":"2025-07-20T16:13:21+08:00"}}`, w.Body.String())
 }
 
+func (s *ServiceTestSuite) TestUpdateUserFields() {
+	s.Run("no field set", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/update?id=0198271f-bc9d-74ac-a63b-41cf2c6c2f82", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param name, email or age not set"}`, w.Body.String())
+	})
+	s.Run("email and age", func() {
+		t := time.Unix(1752999201, 0)
+		id := "0198271f-bc9d-74ac-a63b-41cf2c6c2f82"
+		s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{
+			ID:        id,
+			Name:      "liuliu",
+			Email:     "aa@bb.com",
+			CreatedAt: t,
+			UpdatedAt: t,
+		}, nil).Times(1)
+		s.mockUserRepo.EXPECT().Update(gomock.Any()).DoAndReturn(func(u *store.User) error {
+			s.Equal("liuliu", u.Name)
+			s.Equal("cc@dd.com", u.Email)
+			s.Equal(20, u.Age)
+			return nil
+		}).Times(1)
+		s.mockAuditRepo.EXPECT().CreateBatch(gomock.Any()).DoAndReturn(func(logs []store.AuditLog) error {
+			s.Require().Len(logs, 1)
+			s.Equal(store.AuditActionUpdate, logs[0].Action)
+			s.Equal(id, logs[0].TargetID)
+			s.JSONEq(`{"email":"aa@bb.com","age":0}`, logs[0].Before)
+			s.JSONEq(`{"email":"cc@dd.com","age":20}`, logs[0].After)
+			return nil
+		}).Times(1)
+		s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Len(1)).Return(nil).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/update?id=0198271f-bc9d-74ac-a63b-41cf2c6c2f82&email=cc@dd.com&age=20", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+	})
+}
+
 func (s *ServiceTestSuite) TestDeleteUser() {
 	id := "0198271f-bc9d-74ac-a63b-41cf2c6c2f82"
+	s.mockUserRepo.EXPECT().GetByID(id).Return(&store.User{ID: id, Name: "liuliu", Email: "aa@bb.com"}, nil).Times(1)
 	s.mockUserRepo.EXPECT().DeleteByID(id).Return(nil).Times(1)
+	s.expectChanges(1)
 
 	req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/delete?id=0198271f-bc9d-74ac-a63b-41cf2c6c2f82", nil)
 	w := httptest.NewRecorder()
 	s.svc.ServeHTTP(w, req)
 	s.EqualValues(http.StatusOK, w.Code)
+
+	s.Run("user not found", func() {
+		s.mockUserRepo.EXPECT().GetByID(id).Return(nil, gorm.ErrRecordNotFound).Times(1)
+		s.expectChanges(0)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/delete?id=0198271f-bc9d-74ac-a63b-41cf2c6c2f82", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+	})
 }
 
 func (s *ServiceTestSuite) TestListUser() {
@@ -112,4 +185,91 @@ func (s *ServiceTestSuite) TestListUser() {
 	s.svc.ServeHTTP(w, req)
 	s.EqualValues(http.StatusOK, w.Code)
 	s.EqualValues(`{"data":{"total":1,"users":[{"id":"0198271f-bc9d-74ac-a63b-41cf2c6c2f82","name":"liuliu","email":"aa@bb.com","age":0,"createdAt":"2025-07-20T16:13:21+08:00","updatedAt":"2025-07-20T16:13:21+08:00"}]}}`, w.Body.String())
-}
\ No newline at end of file
+}
+
+func (s *ServiceTestSuite) TestListUserPage() {
+	s.Run("page", func() {
+		s.mockUserRepo.EXPECT().List(3, 20).Return([]store.User{}, int64(41), nil).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/list?page=3&page_size=20", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		s.EqualValues(`{"data":{"total":41,"users":[]}}`, w.Body.String())
+	})
+	for _, tc := range []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{name: "page invalid", query: "page=one", err: "param page invalid: one"},
+		{name: "page negative", query: "page=-1", err: "param page invalid: -1"},
+		{name: "page size invalid", query: "page_size=1.5", err: "param page_size invalid: 1.5"},
+		{name: "page size negative", query: "page_size=-1", err: "param page_size must be at most 1000"},
+		{name: "page size too large", query: "page_size=1001", err: "param page_size must be at most 1000"},
+	} {
+		s.Run(tc.name, func() {
+			req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/list?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			s.svc.ServeHTTP(w, req)
+			s.EqualValues(http.StatusBadRequest, w.Code)
+			s.EqualValues(`{"error":"`+tc.err+`"}`, w.Body.String())
+		})
+	}
+}
+
+func (s *ServiceTestSuite) TestUserLifecycle() {
+	repo := s.useMemoryUsers()
+	s.expectChanges(1)
+	s.expectChanges(1)
+	s.expectChanges(1)
+
+	do := func(method, target string) map[string]any {
+		req := httptest.NewRequest(method, "http://127.0.0.1:8888"+target, nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.Require().EqualValues(http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Data map[string]any `json:"data"`
+		}
+		if w.Body.Len() > 0 {
+			s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return resp.Data
+	}
+
+	created := do("POST", "/user/create?name=liuliu&email=aa@bb.com&age=18")
+	id := created["id"].(string)
+	s.Equal("liuliu", do("GET", "/user/get?id="+id)["name"])
+
+	do("POST", "/user/update?id="+id+"&name=liuliu2")
+	user, err := repo.GetByID(id)
+	s.Require().NoError(err)
+	s.Equal("liuliu2", user.Name)
+	s.Equal(18, user.Age)
+
+	list := do("GET", "/user/list")
+	s.EqualValues(1, list["total"])
+
+	do("POST", "/user/delete?id="+id)
+	_, err = repo.GetByID(id)
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.EqualValues(0, do("GET", "/user/list")["total"])
+}
+
+func (s *ServiceTestSuite) TestClockAndIDGenerator() {
+	// the time is in a fixed zone, so the body does not depend on the local
+	// time zone of the test
+	c := clock.NewFake(time.Unix(1752999201, 0).In(time.FixedZone("CST", 8*3600)))
+	s.useMemoryUsers(store.WithClock(c))
+	s.svc = NewService(s.svc.store, s.conf, WithClock(c), WithIDGenerator(idgen.NewSequence("id")))
+	s.expectChanges(1)
+
+	req := httptest.NewRequest("POST", "http://127.0.0.1:8888/user/create?name=liuliu&email=aa@bb.com&age=18", nil)
+	w := httptest.NewRecorder()
+	s.svc.ServeHTTP(w, req)
+	s.EqualValues(http.StatusOK, w.Code)
+	// the request id is generated first
+	s.Equal("id-1", w.Header().Get(HeaderRequestID))
+	s.EqualValues(`{"data":{"id":"id-2","name":"liuliu","email":"aa@bb.com","age":18,"createdAt":"2025-07-20T16:13:21+08:00","updatedAt":"2025-07-20T16:13:21+08:00"}}`, w.Body.String())
+}
@@ -12,10 +12,14 @@ import (
 type ServiceTestSuite struct {
 	suite.Suite
 
-	ctrl         *gomock.Controller
-	conf         *config.Config
-	mockUserRepo *store.MockUserRepository
-	svc          *Service
+	ctrl           *gomock.Controller
+	conf           *config.Config
+	mockStore      *store.MockStore
+	mockUserRepo   *store.MockUserRepository
+	mockAuditRepo  *store.MockAuditRepository
+	mockOutboxRepo *store.MockOutboxRepository
+	mockWebhooks   *store.MockWebhookRepository
+	svc            *Service
 }
 
 func (s *ServiceTestSuite) SetupSuite() {
@@ -28,7 +32,42 @@ func (s *ServiceTestSuite) TearDownSuite() {
 func (s *ServiceTestSuite) SetupTest() {
 	s.ctrl = gomock.NewController(s.T())
 	s.mockUserRepo = store.NewMockUserRepository(s.ctrl)
-	s.svc = NewService(s.mockUserRepo, s.conf)
+	s.mockAuditRepo = store.NewMockAuditRepository(s.ctrl)
+	s.mockOutboxRepo = store.NewMockOutboxRepository(s.ctrl)
+	s.mockWebhooks = store.NewMockWebhookRepository(s.ctrl)
+	s.mockStore = store.NewMockStore(s.ctrl)
+	s.mockStore.EXPECT().Users().Return(s.mockUserRepo).AnyTimes()
+	s.mockStore.EXPECT().Audits().Return(s.mockAuditRepo).AnyTimes()
+	s.mockStore.EXPECT().Outbox().Return(s.mockOutboxRepo).AnyTimes()
+	s.mockStore.EXPECT().Webhooks().Return(s.mockWebhooks).AnyTimes()
+	s.mockStore.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(tx store.Store) error) error {
+		return fn(s.mockStore)
+	}).AnyTimes()
+	s.svc = NewService(s.mockStore, s.conf)
+}
+
+// expectChanges expects n user changes to be audited and written to the
+// outbox.
+func (s *ServiceTestSuite) expectChanges(n int) {
+	s.mockAuditRepo.EXPECT().CreateBatch(gomock.Len(n)).Return(nil).Times(1)
+	s.mockOutboxRepo.EXPECT().CreateBatch(gomock.Len(n)).Return(nil).Times(1)
+}
+
+// useMemoryUsers replaces the service with one keeping the users in memory
+// instead of the mock repository, the changes still go to the mock audit and
+// outbox repositories.
+func (s *ServiceTestSuite) useMemoryUsers(opts ...store.Option) store.UserRepository {
+	repo := store.NewMemoryUserRepository(opts...)
+	st := store.NewMockStore(s.ctrl)
+	st.EXPECT().Users().Return(repo).AnyTimes()
+	st.EXPECT().Audits().Return(s.mockAuditRepo).AnyTimes()
+	st.EXPECT().Outbox().Return(s.mockOutboxRepo).AnyTimes()
+	st.EXPECT().Webhooks().Return(s.mockWebhooks).AnyTimes()
+	st.EXPECT().Transaction(gomock.Any()).DoAndReturn(func(fn func(tx store.Store) error) error {
+		return fn(st)
+	}).AnyTimes()
+	s.svc = NewService(st, s.conf)
+	return repo
 }
 
 func (s *ServiceTestSuite) TearDownTest() {
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+const (
+	defaultUserPageSize = 100
+	maxUserPageSize     = 1000
+)
+
+// paramError is an error of invalid request params, it is a bad request of
+// the HTTP API and an invalid argument of the gRPC API.
+type paramError struct {
+	msg string
+}
+
+func (e *paramError) Error() string {
+	return e.msg
+}
+
+func paramErrorf(format string, args ...interface{}) error {
+	return &paramError{msg: fmt.Sprintf(format, args...)}
+}
+
+func isParamError(err error) bool {
+	var pe *paramError
+	return errors.As(err, &pe)
+}
+
+// httpStatus returns the status code of an error of the user operations.
+func httpStatus(err error) int {
+	if isParamError(err) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// The user operations below are shared by the HTTP and the gRPC API, the
+// changes are made by the actor of the request info of ctx.
+
+func (s *Service) CreateUser(ctx context.Context, name, email string, age int) (*store.User, error) {
+	if name == "" {
+		return nil, paramErrorf("param name not set")
+	}
+	if email == "" {
+		return nil, paramErrorf("param email not set")
+	}
+	if err := checkUTF8(name, email); err != nil {
+		return nil, err
+	}
+
+	err := transact(s.store, requestInfoFrom(ctx), func(tx store.Store, changes *changeSet) error {
+		user := &store.User{
+			ID:    s.ids.NewID(),
+			Name:  name,
+			Email: email,
+			Age:   age,
+		}
+		if err := tx.Users().Create(user); err != nil {
+			return err
+		}
+		changes.add(nil, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetByEmail(email)
+}
+
+// checkUTF8 rejects a name or email which is not utf-8, it could be stored
+// but the json responses would return it changed.
+func checkUTF8(name, email string) error {
+	if !utf8.ValidString(name) {
+		return paramErrorf("param name invalid: not utf-8")
+	}
+	if !utf8.ValidString(email) {
+		return paramErrorf("param email invalid: not utf-8")
+	}
+	return nil
+}
+
+// GetUser gets the user by id, or by email if id is empty.
+func (s *Service) GetUser(id, email string) (*store.User, error) {
+	if id == "" && email == "" {
+		return nil, paramErrorf("param id or email not set")
+	}
+	if id != "" {
+		return s.userRepo.GetByID(id)
+	}
+	return s.userRepo.GetByEmail(email)
+}
+
+// UpdateUser updates the user id, empty name and email and nil age are left
+// unchanged.
+func (s *Service) UpdateUser(ctx context.Context, id, name, email string, age *int) (*store.User, error) {
+	if id == "" {
+		return nil, paramErrorf("param id not set")
+	}
+	if name == "" && email == "" && age == nil {
+		return nil, paramErrorf("param name, email or age not set")
+	}
+	if err := checkUTF8(name, email); err != nil {
+		return nil, err
+	}
+
+	var user *store.User
+	err := transact(s.store, requestInfoFrom(ctx), func(tx store.Store, changes *changeSet) error {
+		before, err := tx.Users().GetByID(id)
+		if err != nil {
+			return err
+		}
+		updated := *before
+		if name != "" {
+			updated.Name = name
+		}
+		if email != "" {
+			updated.Email = email
+		}
+		if age != nil {
+			updated.Age = *age
+		}
+		if err := tx.Users().Update(&updated); err != nil {
+			return err
+		}
+		changes.add(before, &updated)
+		user = &updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteUser deletes the user id, deleting a missing user is a no-op.
+func (s *Service) DeleteUser(ctx context.Context, id string) error {
+	if id == "" {
+		return paramErrorf("param id not set")
+	}
+	return transact(s.store, requestInfoFrom(ctx), func(tx store.Store, changes *changeSet) error {
+		user, err := tx.Users().GetByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Users().DeleteByID(id); err != nil {
+			return err
+		}
+		changes.add(user, nil)
+		return nil
+	})
+}
+
+// ListUsers lists a page of the users, page 0 is the first page and page size
+// 0 is the default page size.
+func (s *Service) ListUsers(page, pageSize int) ([]store.User, int64, error) {
+	if page < 0 {
+		return nil, 0, paramErrorf("param page invalid: %d", page)
+	}
+	if page == 0 {
+		page = 1
+	}
+	if pageSize < 0 || pageSize > maxUserPageSize {
+		return nil, 0, paramErrorf("param page_size must be at most %d", maxUserPageSize)
+	}
+	if pageSize == 0 {
+		pageSize = defaultUserPageSize
+	}
+	return s.userRepo.List(page, pageSize)
+}
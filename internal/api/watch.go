@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"go-unittest-best-practice/internal/outbox"
+)
+
+const (
+	// HeaderLastEventID is sent by a reconnecting event stream client.
+	HeaderLastEventID = "Last-Event-ID"
+	// EventReset is sent to a stream resumed after events which are deleted
+	// already, the events between the last event id and the id of the reset
+	// event are lost.
+	EventReset = "reset"
+
+	watchBatchSize = 100
+	// watchRetry is the reconnect delay suggested to the clients.
+	watchRetry = 3 * time.Second
+	// watchGapTimeout is how long a stream waits for a missing event id. The
+	// ids are taken when the events are inserted, so the event of a running
+	// transaction commits after the events with greater ids, or never if the
+	// transaction rolls back.
+	watchGapTimeout = 10 * time.Second
+)
+
+// watchUsers streams the user events as server-sent events, of the event
+// types in the types param or of all types. The stream resumes after the
+// Last-Event-ID header or last_event_id param, otherwise it starts with the
+// next event. A stream resumed after events which are pruned from the outbox
+// gets a reset event first. The id sent with the events is the position to
+// resume from, it stays behind a missing event id until the event is sent or
+// watchGapTimeout passes, so the events after the gap may be sent again after
+// a reconnect.
+func (s *Service) watchUsers(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, fmt.Errorf("streaming not supported"))
+		return
+	}
+	types := formList(r, "types")
+	for _, t := range types {
+		if !validEventType(t) {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, fmt.Errorf("param types invalid: unknown event %s", t))
+			return
+		}
+	}
+	var lastID int64
+	reset := false
+	value := r.Header.Get(HeaderLastEventID)
+	if value == "" {
+		value = r.FormValue("last_event_id")
+	}
+	if value != "" {
+		var err error
+		lastID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, fmt.Errorf("last event id invalid: %s", value))
+			return
+		}
+		// the pruned events can't be told apart from the ids never used once
+		// all events are pruned, so an empty outbox resumes without a reset
+		firstID, err := s.store.Outbox().FirstID()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.error(w, err)
+			return
+		}
+		if firstID > lastID+1 {
+			lastID = firstID - 1
+			reset = true
+		}
+	} else {
+		var err error
+		lastID, err = s.store.Outbox().LastID()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.error(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keep proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// the id without data sets the position a client reconnects from, even if
+	// it gets no event before it is disconnected
+	fmt.Fprintf(w, "retry: %d\nid: %d\n\n", watchRetry.Milliseconds(), lastID)
+	if reset {
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {\"id\":%d,\"type\":%q}\n\n", lastID, EventReset, lastID, EventReset)
+	}
+	flusher.Flush()
+
+	cursor := newWatchCursor(lastID)
+	poll := time.NewTicker(s.watchPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(s.heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		// the events after a gap are read again until the gap is closed, the
+		// types are filtered here to tell the gaps from the other types
+		afterID := cursor.id
+		for {
+			events, err := s.store.Outbox().After(afterID, nil, watchBatchSize)
+			if err != nil {
+				// the client reconnects from the last event it got
+				slog.Error("load user events failed", "error", err)
+				return
+			}
+			for i := range events {
+				afterID = events[i].ID
+				if !cursor.add(events[i].ID, s.clock.Now()) || !watchedType(types, events[i].Type) {
+					continue
+				}
+				data, _ := json.Marshal(outbox.EventFromOutbox(&events[i]))
+				_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", cursor.id, events[i].Type, data)
+				if err != nil {
+					return
+				}
+				lastID = cursor.id
+			}
+			if len(events) < watchBatchSize {
+				break
+			}
+		}
+		cursor.skipGaps(s.clock.Now())
+		if cursor.id != lastID {
+			if _, err := fmt.Fprintf(w, "id: %d\n\n", cursor.id); err != nil {
+				return
+			}
+			lastID = cursor.id
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-poll.C:
+		}
+	}
+}
+
+func watchedType(types []string, t string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// watchCursor is the position of an event stream, the events up to id are
+// sent and the events after a missing id are kept in seen until the gap is
+// closed or skipped.
+type watchCursor struct {
+	id int64
+	// seen are the times the events after id were read first
+	seen map[int64]time.Time
+}
+
+func newWatchCursor(id int64) *watchCursor {
+	return &watchCursor{id: id, seen: map[int64]time.Time{}}
+}
+
+// add records the event id read at now, it returns false if the event was
+// read before.
+func (c *watchCursor) add(id int64, now time.Time) bool {
+	if _, ok := c.seen[id]; ok || id <= c.id {
+		return false
+	}
+	c.seen[id] = now
+	c.advance()
+	return true
+}
+
+// skipGaps moves the cursor past the gaps whose next event was read
+// watchGapTimeout before now.
+func (c *watchCursor) skipGaps(now time.Time) {
+	for len(c.seen) > 0 {
+		next := int64(0)
+		for id := range c.seen {
+			if next == 0 || id < next {
+				next = id
+			}
+		}
+		if now.Sub(c.seen[next]) < watchGapTimeout {
+			return
+		}
+		c.id = next - 1
+		c.advance()
+	}
+}
+
+func (c *watchCursor) advance() {
+	for {
+		if _, ok := c.seen[c.id+1]; !ok {
+			return
+		}
+		delete(c.seen, c.id+1)
+		c.id++
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/clock"
+	"go-unittest-best-practice/internal/store"
+)
+
+func (s *ServiceTestSuite) TestWatchUsers() {
+	s.Run("invalid types", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/watch?types=user.renamed", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param types invalid: unknown event user.renamed"}`, w.Body.String())
+	})
+	s.Run("invalid last event id", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/watch", nil)
+		req.Header.Set(HeaderLastEventID, "abc")
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"last event id invalid: abc"}`, w.Body.String())
+	})
+	// stream reads the stream resumed after lastEventID up to the first
+	// heartbeat
+	stream := func(target, lastEventID string) []string {
+		s.svc.watchPollInterval = 5 * time.Millisecond
+		s.svc.heartbeatInterval = 20 * time.Millisecond
+		server := httptest.NewServer(s.svc)
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", server.URL+target, nil)
+		s.Require().NoError(err)
+		req.Header.Set(HeaderLastEventID, lastEventID)
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		defer resp.Body.Close()
+		s.Equal(http.StatusOK, resp.StatusCode)
+		s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+			if scanner.Text() == ": heartbeat" {
+				break
+			}
+		}
+		return lines
+	}
+	event := func(id int64, t string) store.OutboxEvent {
+		return store.OutboxEvent{ID: id, Type: t, UserID: "u1", Payload: `{"id":"u1"}`, RequestID: fmt.Sprintf("req%d", id)}
+	}
+	data := func(id int64, t string) string {
+		return fmt.Sprintf(`data: {"id":%d,"type":"%s","userId":"u1","requestId":"req%d","occurredAt":"0001-01-01T00:00:00Z","data":{"id":"u1"}}`, id, t, id)
+	}
+
+	s.Run("stream", func() {
+		s.mockOutboxRepo.EXPECT().FirstID().Return(int64(1), nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(5), nil, watchBatchSize).Return([]store.OutboxEvent{
+			event(6, store.EventUserCreated),
+			event(7, store.EventUserUpdated),
+			event(8, store.EventUserDeleted),
+		}, nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(8), nil, watchBatchSize).Return(nil, nil).AnyTimes()
+
+		s.Equal([]string{
+			"retry: 3000", "id: 5", "",
+			"id: 6", "event: user.created", data(6, store.EventUserCreated), "",
+			"id: 8", "event: user.deleted", data(8, store.EventUserDeleted), "",
+			": heartbeat",
+		}, stream("/user/watch?types=user.created,user.deleted", "5"))
+	})
+	s.Run("pruned", func() {
+		s.mockOutboxRepo.EXPECT().FirstID().Return(int64(20), nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(19), nil, watchBatchSize).Return([]store.OutboxEvent{
+			event(20, store.EventUserCreated),
+		}, nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(20), nil, watchBatchSize).Return(nil, nil).AnyTimes()
+
+		s.Equal([]string{
+			"retry: 3000", "id: 19", "",
+			"id: 19", "event: reset", `data: {"id":19,"type":"reset"}`, "",
+			"id: 20", "event: user.created", data(20, store.EventUserCreated), "",
+			": heartbeat",
+		}, stream("/user/watch", "15"))
+	})
+	s.Run("late event", func() {
+		s.mockOutboxRepo.EXPECT().FirstID().Return(int64(1), nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(5), nil, watchBatchSize).Return([]store.OutboxEvent{
+			event(6, store.EventUserCreated),
+			event(8, store.EventUserDeleted),
+		}, nil).Times(1)
+		// 7 commits after 8
+		s.mockOutboxRepo.EXPECT().After(int64(6), nil, watchBatchSize).Return([]store.OutboxEvent{
+			event(8, store.EventUserDeleted),
+		}, nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(6), nil, watchBatchSize).Return([]store.OutboxEvent{
+			event(7, store.EventUserUpdated),
+			event(8, store.EventUserDeleted),
+		}, nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(8), nil, watchBatchSize).Return(nil, nil).AnyTimes()
+
+		s.Equal([]string{
+			"retry: 3000", "id: 5", "",
+			"id: 6", "event: user.created", data(6, store.EventUserCreated), "",
+			"id: 6", "event: user.deleted", data(8, store.EventUserDeleted), "",
+			"id: 8", "event: user.updated", data(7, store.EventUserUpdated), "",
+			": heartbeat",
+		}, stream("/user/watch", "5"))
+	})
+	s.Run("gap timeout", func() {
+		fake := clock.NewFake(time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC))
+		s.svc.clock = fake
+		defer func() { s.svc.clock = clock.System }()
+
+		s.mockOutboxRepo.EXPECT().FirstID().Return(int64(1), nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(5), nil, watchBatchSize).Return([]store.OutboxEvent{
+			event(6, store.EventUserCreated),
+			event(8, store.EventUserDeleted),
+		}, nil).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(6), nil, watchBatchSize).DoAndReturn(
+			func(afterID int64, types []string, limit int) ([]store.OutboxEvent, error) {
+				fake.Advance(watchGapTimeout)
+				return []store.OutboxEvent{event(8, store.EventUserDeleted)}, nil
+			}).Times(1)
+		s.mockOutboxRepo.EXPECT().After(int64(8), nil, watchBatchSize).Return(nil, nil).AnyTimes()
+
+		s.Equal([]string{
+			"retry: 3000", "id: 5", "",
+			"id: 6", "event: user.created", data(6, store.EventUserCreated), "",
+			"id: 6", "event: user.deleted", data(8, store.EventUserDeleted), "",
+			"id: 8", "",
+			": heartbeat",
+		}, stream("/user/watch", "5"))
+	})
+	s.Run("first id failed", func() {
+		s.mockOutboxRepo.EXPECT().FirstID().Return(int64(0), gorm.ErrInvalidDB).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/watch?last_event_id=5", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+		s.EqualValues(`{"error":"invalid db"}`, w.Body.String())
+	})
+	s.Run("last id failed", func() {
+		s.mockOutboxRepo.EXPECT().LastID().Return(int64(0), gorm.ErrInvalidDB).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/user/watch", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+		s.EqualValues(`{"error":"invalid db"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+const maxDeliveryPageSize = 1000
+
+// eventTypes are the event types a webhook can subscribe to.
+var eventTypes = []string{store.EventUserCreated, store.EventUserUpdated, store.EventUserDeleted}
+
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only returned when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func convertModelWebhook(webhook *store.Webhook) *Webhook {
+	events := eventTypes
+	if webhook.Events != "" {
+		events = strings.Split(webhook.Events, ",")
+	}
+	return &Webhook{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    events,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      string          `json:"webhookId"`
+	EventID        int64           `json:"eventId"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	ResponseStatus int             `json:"responseStatus"`
+	LastError      string          `json:"lastError"`
+	DeliveredAt    *time.Time      `json:"deliveredAt"`
+	CreatedAt      time.Time       `json:"createdAt"`
+}
+
+func convertModelDelivery(delivery *store.WebhookDelivery) *WebhookDelivery {
+	return &WebhookDelivery{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Payload:        json.RawMessage(delivery.Payload),
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		ResponseStatus: delivery.ResponseStatus,
+		LastError:      delivery.LastError,
+		DeliveredAt:    delivery.DeliveredAt,
+		CreatedAt:      delivery.CreatedAt,
+	}
+}
+
+// createWebhook registers the url param to receive the events param, a comma
+// separated list of event types which defaults to all. The deliveries are
+// signed with the secret param, or a generated secret which is returned once.
+func (s *Service) createWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.error(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	rawURL := r.FormValue("url")
+	if rawURL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param url not set"))
+		return
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param url invalid: must be a http or https url"))
+		return
+	}
+	events := formList(r, "events")
+	for _, e := range events {
+		if !validEventType(e) {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, fmt.Errorf("param events invalid: unknown event %s", e))
+			return
+		}
+	}
+	secret := r.FormValue("secret")
+	if secret == "" {
+		secret, err = newSecret()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.error(w, err)
+			return
+		}
+	}
+
+	webhook := &store.Webhook{
+		ID:     s.ids.NewID(),
+		URL:    rawURL,
+		Secret: secret,
+		Events: strings.Join(events, ","),
+	}
+	if err := s.store.Webhooks().Create(webhook); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, err)
+		return
+	}
+	data := convertModelWebhook(webhook)
+	data.Secret = secret
+	s.data(w, data)
+}
+
+func validEventType(eventType string) bool {
+	for _, e := range eventTypes {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate secret failed: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Service) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.store.Webhooks().List()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, err)
+		return
+	}
+	data := make([]Webhook, 0, len(webhooks))
+	for i := range webhooks {
+		data = append(data, *convertModelWebhook(&webhooks[i]))
+	}
+	s.data(w, data)
+}
+
+// deleteWebhook deletes the webhook of the id param and its deliveries.
+func (s *Service) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.error(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param id not set"))
+		return
+	}
+	if err := s.store.Webhooks().Delete(id); err != nil {
+		w.WriteHeader(notFoundStatus(err))
+		s.error(w, err)
+	}
+}
+
+// notFoundStatus is the status code of an error of the webhook repository.
+func notFoundStatus(err error) int {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// listDeliveries lists the deliveries filtered by the webhook_id, event_id
+// and status params, the newest first.
+func (s *Service) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	filter := store.DeliveryFilter{
+		WebhookID: r.FormValue("webhook_id"),
+		Status:    r.FormValue("status"),
+	}
+	switch filter.Status {
+	case "", store.DeliveryStatusPending, store.DeliveryStatusSucceeded, store.DeliveryStatusFailed:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param status must be pending, succeeded or failed"))
+		return
+	}
+	if value := r.FormValue("event_id"); value != "" {
+		eventID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.error(w, fmt.Errorf("param event_id invalid: %s", value))
+			return
+		}
+		filter.EventID = eventID
+	}
+	page, err := formInt(r, "page")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return
+	}
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, err := formInt(r, "page_size")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, err)
+		return
+	}
+	if pageSize < 0 || pageSize > maxDeliveryPageSize {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param page_size must be at most %d", maxDeliveryPageSize))
+		return
+	}
+	if pageSize == 0 {
+		pageSize = 100
+	}
+
+	deliveries, total, err := s.store.Webhooks().ListDeliveries(filter, page, pageSize)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, err)
+		return
+	}
+	data := make([]WebhookDelivery, 0, len(deliveries))
+	for i := range deliveries {
+		data = append(data, *convertModelDelivery(&deliveries[i]))
+	}
+	s.data(w, map[string]interface{}{
+		"total":      total,
+		"deliveries": data,
+	})
+}
+
+// redeliver sends the delivery of the id param again as soon as possible, its
+// retries start over.
+func (s *Service) redeliver(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.error(w, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.error(w, fmt.Errorf("param id invalid: %s", r.FormValue("id")))
+		return
+	}
+	delivery, err := s.store.Webhooks().GetDelivery(id)
+	if err != nil {
+		w.WriteHeader(notFoundStatus(err))
+		s.error(w, err)
+		return
+	}
+	delivery.Status = store.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = s.clock.Now()
+	if err := s.store.Webhooks().UpdateDelivery(delivery); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.error(w, err)
+		return
+	}
+	s.data(w, convertModelDelivery(delivery))
+}
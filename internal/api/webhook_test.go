@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+func (s *ServiceTestSuite) TestCreateWebhook() {
+	s.Run("method not allowed", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/webhook/create?url=http://a.com/hook", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusMethodNotAllowed, w.Code)
+	})
+	s.Run("invalid url", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/create?url=ftp://a.com", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param url invalid: must be a http or https url"}`, w.Body.String())
+	})
+	s.Run("invalid events", func() {
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/create?url=http://a.com/hook&events=user.created,user.renamed", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param events invalid: unknown event user.renamed"}`, w.Body.String())
+	})
+	s.Run("generated secret", func() {
+		var created *store.Webhook
+		s.mockWebhooks.EXPECT().Create(gomock.Any()).DoAndReturn(func(webhook *store.Webhook) error {
+			created = webhook
+			return nil
+		}).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/create?url=http://a.com/hook&events=user.deleted", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		var resp struct {
+			Data Webhook `json:"data"`
+		}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		s.Equal(created.ID, resp.Data.ID)
+		s.Equal("user.deleted", created.Events)
+		s.Equal([]string{"user.deleted"}, resp.Data.Events)
+		s.Len(resp.Data.Secret, 64)
+		s.Equal(created.Secret, resp.Data.Secret)
+	})
+}
+
+func (s *ServiceTestSuite) TestListWebhooks() {
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.mockWebhooks.EXPECT().List().Return([]store.Webhook{
+		{ID: "w1", URL: "http://a.com/hook", Secret: "s3cret", CreatedAt: createdAt},
+	}, nil).Times(1)
+
+	req := httptest.NewRequest("GET", "http://127.0.0.1:8888/webhook/list", nil)
+	w := httptest.NewRecorder()
+	s.svc.ServeHTTP(w, req)
+	s.EqualValues(http.StatusOK, w.Code)
+	s.JSONEq(`{"data":[{"id":"w1","url":"http://a.com/hook","events":["user.created","user.updated","user.deleted"],"createdAt":"2024-01-01T00:00:00Z"}]}`, w.Body.String())
+}
+
+func (s *ServiceTestSuite) TestDeleteWebhook() {
+	s.Run("success", func() {
+		s.mockWebhooks.EXPECT().Delete("w1").Return(nil).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/delete?id=w1", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+	})
+	s.Run("webhook not found", func() {
+		s.mockWebhooks.EXPECT().Delete("w1").Return(gorm.ErrRecordNotFound).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/delete?id=w1", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusNotFound, w.Code)
+		s.EqualValues(`{"error":"record not found"}`, w.Body.String())
+	})
+	s.Run("delete failed", func() {
+		s.mockWebhooks.EXPECT().Delete("w1").Return(errors.New("db down")).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/delete?id=w1", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusInternalServerError, w.Code)
+	})
+}
+
+func (s *ServiceTestSuite) TestListDeliveries() {
+	s.Run("invalid status", func() {
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/webhook/deliveries?status=lost", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusBadRequest, w.Code)
+		s.EqualValues(`{"error":"param status must be pending, succeeded or failed"}`, w.Body.String())
+	})
+	s.Run("success", func() {
+		s.mockWebhooks.EXPECT().ListDeliveries(store.DeliveryFilter{WebhookID: "w1", EventID: 7, Status: store.DeliveryStatusFailed}, 2, 10).
+			Return([]store.WebhookDelivery{{ID: 3, WebhookID: "w1", EventID: 7, Payload: `{"id":7}`, Status: store.DeliveryStatusFailed, Attempts: 10, ResponseStatus: 500}}, int64(11), nil).Times(1)
+
+		req := httptest.NewRequest("GET", "http://127.0.0.1:8888/webhook/deliveries?webhook_id=w1&event_id=7&status=failed&page=2&page_size=10", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusOK, w.Code)
+		var resp struct {
+			Data struct {
+				Total      int64             `json:"total"`
+				Deliveries []WebhookDelivery `json:"deliveries"`
+			} `json:"data"`
+		}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		s.EqualValues(11, resp.Data.Total)
+		s.Require().Len(resp.Data.Deliveries, 1)
+		s.Equal(10, resp.Data.Deliveries[0].Attempts)
+		s.JSONEq(`{"id":7}`, string(resp.Data.Deliveries[0].Payload))
+	})
+}
+
+func (s *ServiceTestSuite) TestRedeliver() {
+	s.mockWebhooks.EXPECT().GetDelivery(int64(3)).Return(&store.WebhookDelivery{ID: 3, Payload: `{}`, Status: store.DeliveryStatusFailed, Attempts: 10}, nil).Times(1)
+	s.mockWebhooks.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery *store.WebhookDelivery) error {
+		s.Equal(store.DeliveryStatusPending, delivery.Status)
+		s.Equal(0, delivery.Attempts)
+		s.WithinDuration(time.Now(), delivery.NextAttemptAt, time.Second)
+		return nil
+	}).Times(1)
+
+	req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/redeliver?id=3", nil)
+	w := httptest.NewRecorder()
+	s.svc.ServeHTTP(w, req)
+	s.EqualValues(http.StatusOK, w.Code)
+	s.Run("delivery not found", func() {
+		s.mockWebhooks.EXPECT().GetDelivery(int64(4)).Return(nil, gorm.ErrRecordNotFound).Times(1)
+
+		req := httptest.NewRequest("POST", "http://127.0.0.1:8888/webhook/redeliver?id=4", nil)
+		w := httptest.NewRecorder()
+		s.svc.ServeHTTP(w, req)
+		s.EqualValues(http.StatusNotFound, w.Code)
+		s.EqualValues(`{"error":"record not found"}`, w.Body.String())
+	})
+}
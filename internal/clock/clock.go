@@ -0,0 +1,65 @@
+// Package clock abstracts the current time, so the tests of the code reading
+// it can set the time instead of patching time.Now.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// Func is a func used as a Clock.
+type Func func() time.Time
+
+func (f Func) Now() time.Time {
+	return f()
+}
+
+// System is the Clock of time.Now.
+var System Clock = Func(time.Now)
+
+// Fake is a Clock which only moves when it is set or advanced, and by step
+// after every Now.
+type Fake struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewFake returns a Fake at now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Set sets the time of c.
+func (c *Fake) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves c forward by d.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetStep makes every Now advance c by step, so the times read one after
+// another are distinct. The step is 0 by default.
+func (c *Fake) SetStep(step time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.step = step
+}
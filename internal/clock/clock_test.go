@@ -0,0 +1,25 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Unix(1752999201, 0)
+	c := NewFake(start)
+	assert.Equal(t, start, c.Now())
+	assert.Equal(t, start, c.Now())
+
+	c.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), c.Now())
+	c.Set(start)
+	assert.Equal(t, start, c.Now())
+
+	c.SetStep(time.Second)
+	assert.Equal(t, start, c.Now())
+	assert.Equal(t, start.Add(time.Second), c.Now())
+	assert.Equal(t, start.Add(2*time.Second), c.Now())
+}
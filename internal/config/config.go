@@ -3,32 +3,261 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+	"golang.org/x/exp/slog"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
+	DBDriver   string `yaml:"dbdriver"`
 	DBHost     string `yaml:"dbhost"`
 	DBPort     int    `yaml:"dbport"`
 	DBUser     string `yaml:"dbuser"`
 	DBPassword string `json:"-" yaml:"dbpassword"`
 	DBName     string `yaml:"dbname"`
 	ListenPort int    `yaml:"listenPort"`
+	// GRPCPort is the port of the gRPC API, 0 disables it.
+	GRPCPort int `yaml:"grpcPort"`
+
+	// DSN is used as is when set, the other connection fields above and below
+	// are ignored except the connection pool settings.
+	DSN            string        `json:"-" yaml:"dsn"`
+	DBCharset      string        `yaml:"dbcharset"`
+	DBLoc          string        `yaml:"dbloc"`
+	DBTLS          string        `yaml:"dbtls"`
+	DBTLSCA        string        `yaml:"dbtlsca"`
+	DBTimeout      time.Duration `yaml:"dbtimeout"`
+	DBReadTimeout  time.Duration `yaml:"dbreadtimeout"`
+	DBWriteTimeout time.Duration `yaml:"dbwritetimeout"`
+
+	DBMaxOpenConns    int           `yaml:"dbmaxopenconns"`
+	DBMaxIdleConns    int           `yaml:"dbmaxidleconns"`
+	DBConnMaxLifetime time.Duration `yaml:"dbconnmaxlifetime"`
+	DBConnMaxIdleTime time.Duration `yaml:"dbconnmaxidletime"`
 
 	PprofAddr string `yaml:"pprofAddr"`
+	LogLevel  string `yaml:"logLevel"`
+	// MaxBatchSize is the maximum number of users a batch request may get,
+	// update or delete, 0 means DefaultMaxBatchSize.
+	MaxBatchSize int `yaml:"maxBatchSize"`
+	// IDVersion is the uuid version of the ids of the created users and
+	// webhooks, v4 or the time ordered v7, empty means v4.
+	IDVersion string `yaml:"idVersion"`
+
+	// OutboxSinks are where the relay publishes the user change events, each
+	// one of stdout, file:PATH or a http(s) webhook url.
+	OutboxSinks     []string      `yaml:"outboxSinks"`
+	OutboxInterval  time.Duration `yaml:"outboxInterval"`
+	OutboxRetention time.Duration `yaml:"outboxRetention"`
 }
 
+const DefaultMaxBatchSize = 1000
+
 func (c *Config) AddFlags(flags *pflag.FlagSet) {
 	flags.SortFlags = false
+	flags.StringVar(&c.DBDriver, "dbdriver", "mysql", "The database driver, one of mysql, postgres, sqlite.")
 	flags.StringVar(&c.DBHost, "dbhost", "", "The database host/ip.")
 	flags.IntVar(&c.DBPort, "dbport", 3306, "The database port.")
 	flags.StringVar(&c.DBUser, "dbuser", "root", "The database user.")
 	flags.StringVar(&c.DBPassword, "dbpassword", "", "The database password of user.")
-	flags.StringVar(&c.DBName, "dbname", "user_manage", "The database name.")
+	flags.StringVar(&c.DBName, "dbname", "user_manage", "The database name, or the database file path for sqlite.")
+	flags.StringVar(&c.DSN, "dsn", "", "The database DSN, overrides the other database connection flags when set.")
+	flags.StringVar(&c.DBCharset, "dbcharset", "utf8mb4", "The database connection charset.")
+	flags.StringVar(&c.DBLoc, "dbloc", "Local", "The time zone location of database time values.")
+	flags.StringVar(&c.DBTLS, "dbtls", "", "The database TLS mode, one of true, false, skip-verify, preferred.")
+	flags.StringVar(&c.DBTLSCA, "dbtlsca", "", "The CA file to verify the database server certificate.")
+	flags.DurationVar(&c.DBTimeout, "dbtimeout", 10*time.Second, "The database dial timeout.")
+	flags.DurationVar(&c.DBReadTimeout, "dbreadtimeout", 0, "The database I/O read timeout.")
+	flags.DurationVar(&c.DBWriteTimeout, "dbwritetimeout", 0, "The database I/O write timeout.")
+	flags.IntVar(&c.DBMaxOpenConns, "dbmaxopenconns", 0, "The maximum number of open database connections, 0 means unlimited.")
+	flags.IntVar(&c.DBMaxIdleConns, "dbmaxidleconns", 0, "The maximum number of idle database connections, 0 means the database/sql default.")
+	flags.DurationVar(&c.DBConnMaxLifetime, "dbconnmaxlifetime", 0, "The maximum amount of time a database connection may be reused, 0 means forever.")
+	flags.DurationVar(&c.DBConnMaxIdleTime, "dbconnmaxidletime", 0, "The maximum amount of time a database connection may be idle, 0 means forever.")
 
 	flags.IntVar(&c.ListenPort, "listen-port", 8000, "HTTP server listen port.")
+	flags.IntVar(&c.GRPCPort, "grpc-port", 0, "gRPC server listen port, 0 disables the gRPC server.")
 	flags.StringVar(&c.PprofAddr, "pprof-addr", ":8090", "The address the pprof endpoint binds to.")
+	flags.StringVar(&c.LogLevel, "log-level", "info", "The log level, one of debug, info, warn, error.")
+	flags.IntVar(&c.MaxBatchSize, "max-batch-size", DefaultMaxBatchSize, "The maximum number of users a batch request may get, update or delete.")
+	flags.StringVar(&c.IDVersion, "id-version", "v4", "The uuid version of the created ids, v4 or the time ordered v7.")
+	flags.StringSliceVar(&c.OutboxSinks, "outbox-sink", nil, "The sinks the relay publishes user events to, stdout, file:PATH or a http(s) url, may be repeated.")
+	flags.DurationVar(&c.OutboxInterval, "outbox-interval", time.Second, "How often the relay polls the outbox for new events.")
+	flags.DurationVar(&c.OutboxRetention, "outbox-retention", 7*24*time.Hour, "How long published events are kept, 0 keeps them forever.")
+}
+
+// Validate checks the config values, it should be called before a config is
+// used or published to the running service.
+func (c *Config) Validate() error {
+	switch c.DBDriver {
+	case "", "mysql", "postgres", "sqlite":
+	default:
+		return fmt.Errorf("invalid dbdriver: %s", c.DBDriver)
+	}
+	if c.DBPort <= 0 || c.DBPort > 65535 {
+		return fmt.Errorf("invalid dbport: %d", c.DBPort)
+	}
+	if c.ListenPort <= 0 || c.ListenPort > 65535 {
+		return fmt.Errorf("invalid listenPort: %d", c.ListenPort)
+	}
+	if c.GRPCPort < 0 || c.GRPCPort > 65535 || (c.GRPCPort != 0 && c.GRPCPort == c.ListenPort) {
+		return fmt.Errorf("invalid grpcPort: %d", c.GRPCPort)
+	}
+	if _, err := c.SlogLevel(); err != nil {
+		return err
+	}
+	switch c.DBTLS {
+	case "", "true", "false", "skip-verify", "preferred":
+	default:
+		return fmt.Errorf("invalid dbtls: %s", c.DBTLS)
+	}
+	if c.DBLoc != "" {
+		if _, err := time.LoadLocation(c.DBLoc); err != nil {
+			return fmt.Errorf("invalid dbloc: %v", err)
+		}
+	}
+	if c.DBMaxOpenConns < 0 || c.DBMaxIdleConns < 0 {
+		return fmt.Errorf("invalid db connection pool size: maxopenconns %d, maxidleconns %d", c.DBMaxOpenConns, c.DBMaxIdleConns)
+	}
+	if c.MaxBatchSize < 0 {
+		return fmt.Errorf("invalid maxBatchSize: %d", c.MaxBatchSize)
+	}
+	switch c.IDVersion {
+	case "", "v4", "v7":
+	default:
+		return fmt.Errorf("invalid idVersion: %s", c.IDVersion)
+	}
+	for _, sink := range c.OutboxSinks {
+		if sink != "stdout" && !strings.HasPrefix(sink, "file:") &&
+			!strings.HasPrefix(sink, "http://") && !strings.HasPrefix(sink, "https://") {
+			return fmt.Errorf("invalid outboxSinks: %s", sink)
+		}
+	}
+	if c.OutboxInterval < 0 || c.OutboxRetention < 0 {
+		return fmt.Errorf("invalid outbox durations: interval %s, retention %s", c.OutboxInterval, c.OutboxRetention)
+	}
+	return nil
+}
+
+// BatchLimit returns MaxBatchSize, or DefaultMaxBatchSize if it is not set.
+func (c *Config) BatchLimit() int {
+	if c.MaxBatchSize == 0 {
+		return DefaultMaxBatchSize
+	}
+	return c.MaxBatchSize
+}
+
+// SlogLevel parses LogLevel, an empty LogLevel means info.
+func (c *Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if c.LogLevel == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return level, fmt.Errorf("invalid logLevel: %s", c.LogLevel)
+	}
+	return level, nil
+}
+
+// LogValue logs the config with the password and the DSN, which may contain
+// the password, replaced.
+func (c *Config) LogValue() slog.Value {
+	// plain has no LogValue method, so the copy is not resolved again
+	type plain Config
+	redacted := plain(*c)
+	if redacted.DBPassword != "" {
+		redacted.DBPassword = "REDACTED"
+	}
+	if redacted.DSN != "" {
+		redacted.DSN = "REDACTED"
+	}
+	return slog.AnyValue(redacted)
+}
+
+// RestartRequired returns the yaml names of the fields which differ between
+// c and newConf and can not be applied without restarting the process.
+func (c *Config) RestartRequired(newConf *Config) []string {
+	var fields []string
+	if c.DBDriver != newConf.DBDriver {
+		fields = append(fields, "dbdriver")
+	}
+	if c.DBHost != newConf.DBHost {
+		fields = append(fields, "dbhost")
+	}
+	if c.DBPort != newConf.DBPort {
+		fields = append(fields, "dbport")
+	}
+	if c.DBUser != newConf.DBUser {
+		fields = append(fields, "dbuser")
+	}
+	if c.DBPassword != newConf.DBPassword {
+		fields = append(fields, "dbpassword")
+	}
+	if c.DBName != newConf.DBName {
+		fields = append(fields, "dbname")
+	}
+	if c.DSN != newConf.DSN {
+		fields = append(fields, "dsn")
+	}
+	if c.DBCharset != newConf.DBCharset {
+		fields = append(fields, "dbcharset")
+	}
+	if c.DBLoc != newConf.DBLoc {
+		fields = append(fields, "dbloc")
+	}
+	if c.DBTLS != newConf.DBTLS {
+		fields = append(fields, "dbtls")
+	}
+	if c.DBTLSCA != newConf.DBTLSCA {
+		fields = append(fields, "dbtlsca")
+	}
+	if c.DBTimeout != newConf.DBTimeout {
+		fields = append(fields, "dbtimeout")
+	}
+	if c.DBReadTimeout != newConf.DBReadTimeout {
+		fields = append(fields, "dbreadtimeout")
+	}
+	if c.DBWriteTimeout != newConf.DBWriteTimeout {
+		fields = append(fields, "dbwritetimeout")
+	}
+	if c.ListenPort != newConf.ListenPort {
+		fields = append(fields, "listenPort")
+	}
+	if c.GRPCPort != newConf.GRPCPort {
+		fields = append(fields, "grpcPort")
+	}
+	if c.PprofAddr != newConf.PprofAddr {
+		fields = append(fields, "pprofAddr")
+	}
+	if c.IDVersion != newConf.IDVersion {
+		fields = append(fields, "idVersion")
+	}
+	return fields
+}
+
+// keepRestartFields copies the fields which require restart from c to
+// newConf, so a published snapshot always describes the running process.
+func (c *Config) keepRestartFields(newConf *Config) {
+	newConf.DBDriver = c.DBDriver
+	newConf.DBHost = c.DBHost
+	newConf.DBPort = c.DBPort
+	newConf.DBUser = c.DBUser
+	newConf.DBPassword = c.DBPassword
+	newConf.DBName = c.DBName
+	newConf.DSN = c.DSN
+	newConf.DBCharset = c.DBCharset
+	newConf.DBLoc = c.DBLoc
+	newConf.DBTLS = c.DBTLS
+	newConf.DBTLSCA = c.DBTLSCA
+	newConf.DBTimeout = c.DBTimeout
+	newConf.DBReadTimeout = c.DBReadTimeout
+	newConf.DBWriteTimeout = c.DBWriteTimeout
+	newConf.ListenPort = c.ListenPort
+	newConf.GRPCPort = c.GRPCPort
+	newConf.PprofAddr = c.PprofAddr
+	newConf.IDVersion = c.IDVersion
 }
 
 func LoadConfig(configFile string) (*Config, error) {
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"golang.org/x/exp/slog"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -99,4 +101,104 @@ func assertConfig(t *testing.T, expectedConfig *Config, conf *Config) {
 		expectedConfig.PprofAddr != conf.PprofAddr {
 		t.Errorf("assert config not equal, expected: %v, actual: %v", expectedConfig, conf)
 	}
-}
\ No newline at end of file
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{DBPort: 3306, ListenPort: 8000, LogLevel: "info", DBLoc: "Local"}
+	}
+	cases := []struct {
+		name   string
+		modify func(c *Config)
+		errMsg string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Config) {},
+		},
+		{
+			name:   "invalid listen port",
+			modify: func(c *Config) { c.ListenPort = 70000 },
+			errMsg: "invalid listenPort",
+		},
+		{
+			name:   "invalid grpc port",
+			modify: func(c *Config) { c.GRPCPort = 8000 },
+			errMsg: "invalid grpcPort",
+		},
+		{
+			name:   "invalid log level",
+			modify: func(c *Config) { c.LogLevel = "verbose" },
+			errMsg: "invalid logLevel",
+		},
+		{
+			name:   "invalid tls mode",
+			modify: func(c *Config) { c.DBTLS = "always" },
+			errMsg: "invalid dbtls",
+		},
+		{
+			name:   "invalid location",
+			modify: func(c *Config) { c.DBLoc = "Mars/Olympus" },
+			errMsg: "invalid dbloc",
+		},
+		{
+			name:   "invalid pool size",
+			modify: func(c *Config) { c.DBMaxIdleConns = -1 },
+			errMsg: "invalid db connection pool size",
+		},
+		{
+			name:   "invalid max batch size",
+			modify: func(c *Config) { c.MaxBatchSize = -1 },
+			errMsg: "invalid maxBatchSize",
+		},
+		{
+			name:   "invalid id version",
+			modify: func(c *Config) { c.IDVersion = "v1" },
+			errMsg: "invalid idVersion",
+		},
+		{
+			name: "valid outbox sinks",
+			modify: func(c *Config) {
+				c.OutboxSinks = []string{"stdout", "file:/tmp/events.ndjson", "https://example.com/hook"}
+			},
+		},
+		{
+			name:   "invalid outbox sink",
+			modify: func(c *Config) { c.OutboxSinks = []string{"kafka://events"} },
+			errMsg: "invalid outboxSinks",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := valid()
+			tc.modify(&conf)
+			err := conf.Validate()
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Errorf("expect nil error, but got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Errorf("expect error contains \"%s\", but got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	conf := &Config{DBUser: "root", DBPassword: "123456", DSN: "root:123456@tcp(127.0.0.1:3306)/test"}
+	logger.Info("load config", "config", conf)
+	if strings.Contains(buf.String(), "123456") {
+		t.Errorf("expect credentials redacted, but got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "DBUser:root") || !strings.Contains(buf.String(), "DBPassword:REDACTED") {
+		t.Errorf("expect config logged, but got %s", buf.String())
+	}
+	if conf.DBPassword != "123456" {
+		t.Errorf("expect config unchanged, but got password %s", conf.DBPassword)
+	}
+}
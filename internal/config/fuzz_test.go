@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FuzzLoadConfig loads arbitrary yaml, a loaded config must validate without
+// panic and be written as the same yaml after it is loaded back.
+func FuzzLoadConfig(f *testing.F) {
+	f.Add([]byte("dbdriver: sqlite\ndbname: /tmp/users.db\nlistenPort: 8000\n"))
+	f.Add([]byte("dbhost: 127.0.0.1\ndbport: 3306\ndbtimeout: 10s\noutboxSinks:\n  - stdout\n  - file:/tmp/events.ndjson\n"))
+	f.Add([]byte("dbport: ten\n"))
+	f.Add([]byte("- a\n- b\n"))
+	f.Add([]byte(""))
+	dir := f.TempDir()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatalf("write config failed: %v", err)
+		}
+		conf, err := LoadConfig(path)
+		if err != nil {
+			return
+		}
+		conf.Validate()
+
+		out, err := yaml.Marshal(conf)
+		if err != nil {
+			t.Fatalf("marshal config failed: %v", err)
+		}
+		var reloaded Config
+		if err := yaml.Unmarshal(out, &reloaded); err != nil {
+			t.Fatalf("parse marshaled config failed: %v\n%s", err, out)
+		}
+		// a nil list is written as [] and loaded back empty, so compare the
+		// yaml instead of the configs
+		again, err := yaml.Marshal(&reloaded)
+		if err != nil {
+			t.Fatalf("marshal reloaded config failed: %v", err)
+		}
+		if string(out) != string(again) {
+			t.Errorf("config changed after a round trip:\n%s\nreloaded as\n%s", out, again)
+		}
+	})
+}
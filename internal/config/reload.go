@@ -0,0 +1,131 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Reloader holds the current config snapshot and reloads it from the config
+// file on SIGHUP or when the file is modified.
+type Reloader struct {
+	path     string
+	interval time.Duration
+
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	modTime     time.Time
+	subscribers []func(*Config)
+}
+
+func NewReloader(path string, conf *Config) *Reloader {
+	r := &Reloader{
+		path:     path,
+		interval: 5 * time.Second,
+	}
+	r.current.Store(conf)
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// SetInterval sets the interval of checking the config file modification.
+func (r *Reloader) SetInterval(interval time.Duration) {
+	r.interval = interval
+}
+
+// Config returns the current config snapshot, the returned config must not be
+// modified.
+func (r *Reloader) Config() *Config {
+	return r.current.Load()
+}
+
+// Subscribe registers fn to be called with every new snapshot.
+func (r *Reloader) Subscribe(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Reload loads and validates the config file and publishes it as the new
+// snapshot. Changed fields which can not be applied live keep their current
+// values and are returned, so the caller can report that a restart is needed.
+func (r *Reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	newConf, err := LoadConfig(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := newConf.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config failed: %v", err)
+	}
+
+	old := r.current.Load()
+	restartFields := old.RestartRequired(newConf)
+	old.keepRestartFields(newConf)
+	r.current.Store(newConf)
+	for _, fn := range r.subscribers {
+		fn(newConf)
+	}
+	return restartFields, nil
+}
+
+// Run reloads the config on SIGHUP or config file modification until ctx is
+// done.
+func (r *Reloader) Run(ctx context.Context) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigChan:
+			r.reload("signal")
+		case <-ticker.C:
+			if r.modified() {
+				r.reload("file change")
+			}
+		}
+	}
+}
+
+func (r *Reloader) modified() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !info.ModTime().Equal(r.modTime)
+}
+
+func (r *Reloader) reload(reason string) {
+	restartFields, err := r.Reload()
+	if err != nil {
+		slog.Error("reload config failed", "reason", reason, "error", err)
+		return
+	}
+	slog.Info("config reloaded", "reason", reason)
+	if len(restartFields) > 0 {
+		slog.Warn("config fields changed but require restart", "fields", restartFields)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	writeConfig := func(t *testing.T, content string) {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig(t, `
+dbhost: 127.0.0.1
+dbport: 3306
+listenPort: 6666
+logLevel: info`)
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReloader(configPath, conf)
+
+	var published *Config
+	r.Subscribe(func(c *Config) {
+		published = c
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		writeConfig(t, `
+dbhost: 127.0.0.1
+dbport: 3306
+listenPort: 6666
+logLevel: verbose`)
+		_, err := r.Reload()
+		if err == nil {
+			t.Fatalf("expect validate error, but got nil error")
+		}
+		if r.Config() != conf {
+			t.Errorf("expect snapshot not changed, but got %v", r.Config())
+		}
+		if published != nil {
+			t.Errorf("expect no snapshot published, but got %v", published)
+		}
+	})
+
+	t.Run("live fields", func(t *testing.T) {
+		writeConfig(t, `
+dbhost: 127.0.0.1
+dbport: 3306
+listenPort: 6666
+logLevel: debug`)
+		restartFields, err := r.Reload()
+		if err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if len(restartFields) != 0 {
+			t.Errorf("expect no restart fields, but got %v", restartFields)
+		}
+		if r.Config().LogLevel != "debug" {
+			t.Errorf("expect logLevel debug, but got %s", r.Config().LogLevel)
+		}
+		if published != r.Config() {
+			t.Errorf("expect snapshot published to subscriber")
+		}
+	})
+
+	t.Run("restart fields", func(t *testing.T) {
+		writeConfig(t, `
+dbhost: 10.0.0.1
+dbport: 3306
+listenPort: 7777
+logLevel: warn`)
+		restartFields, err := r.Reload()
+		if err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if !reflect.DeepEqual([]string{"dbhost", "listenPort"}, restartFields) {
+			t.Errorf("expect restart fields [dbhost listenPort], but got %v", restartFields)
+		}
+		if r.Config().DBHost != "127.0.0.1" || r.Config().ListenPort != 6666 {
+			t.Errorf("expect restart fields unchanged, but got %v", r.Config())
+		}
+		if r.Config().LogLevel != "warn" {
+			t.Errorf("expect logLevel warn, but got %s", r.Config().LogLevel)
+		}
+	})
+
+	t.Run("watch file change", func(t *testing.T) {
+		r.SetInterval(10 * time.Millisecond)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go r.Run(ctx)
+
+		writeConfig(t, `
+dbhost: 127.0.0.1
+dbport: 3306
+listenPort: 6666
+logLevel: error`)
+		// make sure the modification time differs on file systems with a
+		// coarse timestamp granularity
+		future := time.Now().Add(time.Minute)
+		if err := os.Chtimes(configPath, future, future); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for r.Config().LogLevel != "error" {
+			if time.Now().After(deadline) {
+				t.Fatalf("expect config reloaded after file change, but got %v", r.Config())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
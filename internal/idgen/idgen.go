@@ -0,0 +1,55 @@
+// Package idgen generates the ids of the created records, random uuids by
+// default, time ordered uuids as an option and sequential ids in tests.
+package idgen
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type IDGenerator interface {
+	NewID() string
+}
+
+// Func is a func used as an IDGenerator.
+type Func func() string
+
+func (f Func) NewID() string {
+	return f()
+}
+
+// UUIDv4 generates random uuids, the default ids.
+var UUIDv4 IDGenerator = Func(uuid.NewString)
+
+// UUIDv7 generates uuids starting with the time they are generated, so the
+// ids sort in creation order and are inserted at the end of the indexes. It
+// falls back to a random uuid if the random source fails.
+var UUIDv7 IDGenerator = Func(func() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+})
+
+// Sequence generates the ids <prefix>-1, <prefix>-2 and so on, it is safe for
+// concurrent use.
+type Sequence struct {
+	prefix string
+
+	mu sync.Mutex
+	n  int
+}
+
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix}
+}
+
+func (s *Sequence) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return fmt.Sprintf("%s-%d", s.prefix, s.n)
+}
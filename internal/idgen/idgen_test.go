@@ -0,0 +1,31 @@
+package idgen
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequence(t *testing.T) {
+	ids := NewSequence("user")
+	assert.Equal(t, "user-1", ids.NewID())
+	assert.Equal(t, "user-2", ids.NewID())
+}
+
+func TestUUID(t *testing.T) {
+	id, err := uuid.Parse(UUIDv4.NewID())
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, id.Version())
+
+	var ids []string
+	for i := 0; i < 100; i++ {
+		ids = append(ids, UUIDv7.NewID())
+	}
+	id, err = uuid.Parse(ids[0])
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, id.Version())
+	assert.True(t, sort.StringsAreSorted(ids), "uuids v7 are not ordered: %v", ids)
+}
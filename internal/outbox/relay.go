@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+// Relay publishes the outbox events to the sinks. An event is marked published
+// only after all sinks accepted it, and is retried with exponential backoff
+// otherwise, so delivery is at least once. A failed event does not hold back
+// the later events, consumers should expect duplicates and reordering and can
+// use the event id to detect both.
+type Relay struct {
+	outbox store.OutboxRepository
+	sinks  []Sink
+	now    func() time.Time
+
+	// BatchSize is the number of events loaded at a time.
+	BatchSize int
+	// Interval is how long to wait when there are no due events.
+	Interval time.Duration
+	// MinBackoff and MaxBackoff bound the delay of a retry, which doubles
+	// with every attempt.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long published events are kept, 0 keeps them.
+	Retention time.Duration
+}
+
+func NewRelay(outbox store.OutboxRepository, sinks ...Sink) *Relay {
+	return &Relay{
+		outbox:     outbox,
+		sinks:      sinks,
+		now:        time.Now,
+		BatchSize:  100,
+		Interval:   time.Second,
+		MinBackoff: time.Second,
+		MaxBackoff: 10 * time.Minute,
+	}
+}
+
+// Run publishes events until ctx is done.
+func (r *Relay) Run(ctx context.Context) error {
+	var lastPrune time.Time
+	for {
+		if r.Retention > 0 && r.now().Sub(lastPrune) >= time.Hour {
+			lastPrune = r.now()
+			if n, err := r.outbox.DeletePublished(lastPrune.Add(-r.Retention)); err != nil {
+				slog.Error("delete published outbox events failed", "error", err)
+			} else if n > 0 {
+				slog.Info("deleted published outbox events", "count", n)
+			}
+		}
+
+		n, err := r.RunOnce(ctx)
+		if err != nil {
+			slog.Error("relay outbox events failed", "error", err)
+		}
+		// keep going while there are full batches
+		if err == nil && n == r.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.Interval):
+		}
+	}
+}
+
+// RunOnce publishes one batch of due events and returns the number of events
+// loaded.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	events, err := r.outbox.Pending(r.now(), r.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("load pending events failed: %v", err)
+	}
+
+	var published []int64
+	for i := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		e := &events[i]
+		if err := r.publish(ctx, EventFromOutbox(e)); err != nil {
+			backoff := r.backoff(e.Attempts + 1)
+			slog.Warn("publish outbox event failed", "id", e.ID, "type", e.Type, "attempts", e.Attempts+1, "retryIn", backoff, "error", err)
+			if err := r.outbox.MarkFailed(e.ID, err.Error(), r.now().Add(backoff)); err != nil {
+				return len(events), fmt.Errorf("mark event %d failed failed: %v", e.ID, err)
+			}
+			continue
+		}
+		published = append(published, e.ID)
+	}
+	if err := r.outbox.MarkPublished(published, r.now()); err != nil {
+		return len(events), fmt.Errorf("mark events published failed: %v", err)
+	}
+	return len(events), nil
+}
+
+func (r *Relay) publish(ctx context.Context, event Event) error {
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("sink %s: %v", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// backoff returns the delay before the attempts+1 attempt.
+func (r *Relay) backoff(attempts int) time.Duration {
+	return Backoff(r.MinBackoff, r.MaxBackoff, attempts)
+}
+
+// Backoff returns the delay after the attempts failed attempts, it starts at
+// min and doubles with every attempt up to max.
+func Backoff(min, max time.Duration, attempts int) time.Duration {
+	d := min
+	for i := 1; i < attempts && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
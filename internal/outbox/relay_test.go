@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+type fakeSink struct {
+	events []Event
+	err    error
+}
+
+func (s *fakeSink) Name() string {
+	return "fake"
+}
+
+func (s *fakeSink) Publish(ctx context.Context, event Event) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+type RelayTestSuite struct {
+	suite.Suite
+
+	ctrl       *gomock.Controller
+	mockOutbox *store.MockOutboxRepository
+	sink       *fakeSink
+	relay      *Relay
+	now        time.Time
+}
+
+func (s *RelayTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockOutbox = store.NewMockOutboxRepository(s.ctrl)
+	s.sink = &fakeSink{}
+	s.now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.relay = NewRelay(s.mockOutbox, s.sink)
+	s.relay.now = func() time.Time { return s.now }
+}
+
+func (s *RelayTestSuite) TearDownTest() {
+	s.ctrl.Finish()
+}
+
+func (s *RelayTestSuite) TestRunOnce() {
+	events := []store.OutboxEvent{
+		{ID: 1, Type: store.EventUserCreated, UserID: "u1", Payload: `{"id":"u1"}`, RequestID: "req1"},
+		{ID: 2, Type: store.EventUserDeleted, UserID: "u2", Payload: `{"id":"u2"}`},
+	}
+
+	s.Run("publish", func() {
+		s.sink.events = nil
+		s.mockOutbox.EXPECT().Pending(s.now, 100).Return(events, nil).Times(1)
+		s.mockOutbox.EXPECT().MarkPublished([]int64{1, 2}, s.now).Return(nil).Times(1)
+		n, err := s.relay.RunOnce(context.Background())
+		s.Require().NoError(err)
+		s.Equal(2, n)
+		s.Require().Len(s.sink.events, 2)
+		s.Equal(Event{ID: 1, Type: store.EventUserCreated, UserID: "u1", RequestID: "req1", Data: []byte(`{"id":"u1"}`)}, s.sink.events[0])
+	})
+
+	s.Run("sink failed", func() {
+		s.sink.err = errors.New("connection refused")
+		defer func() { s.sink.err = nil }()
+		s.mockOutbox.EXPECT().Pending(s.now, 100).Return([]store.OutboxEvent{{ID: 3, Attempts: 2}}, nil).Times(1)
+		// the third attempt waits 4s
+		s.mockOutbox.EXPECT().MarkFailed(int64(3), "sink fake: connection refused", s.now.Add(4*time.Second)).Return(nil).Times(1)
+		s.mockOutbox.EXPECT().MarkPublished(gomock.Len(0), s.now).Return(nil).Times(1)
+		n, err := s.relay.RunOnce(context.Background())
+		s.Require().NoError(err)
+		s.Equal(1, n)
+	})
+
+	s.Run("pending failed", func() {
+		s.mockOutbox.EXPECT().Pending(s.now, 100).Return(nil, errors.New("db down")).Times(1)
+		_, err := s.relay.RunOnce(context.Background())
+		s.Require().ErrorContains(err, "load pending events failed")
+	})
+}
+
+func (s *RelayTestSuite) TestBackoff() {
+	s.Equal(time.Second, s.relay.backoff(1))
+	s.Equal(2*time.Second, s.relay.backoff(2))
+	s.Equal(8*time.Second, s.relay.backoff(4))
+	s.Equal(10*time.Minute, s.relay.backoff(100))
+}
+
+func TestRelay(t *testing.T) {
+	suite.Run(t, new(RelayTestSuite))
+}
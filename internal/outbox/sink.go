@@ -0,0 +1,156 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+// Event is the published form of an outbox event, ID increases with the
+// order the events were written in.
+type Event struct {
+	ID         int64           `json:"id"`
+	Type       string          `json:"type"`
+	UserID     string          `json:"userId"`
+	RequestID  string          `json:"requestId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func EventFromOutbox(e *store.OutboxEvent) Event {
+	return Event{
+		ID:         e.ID,
+		Type:       e.Type,
+		UserID:     e.UserID,
+		RequestID:  e.RequestID,
+		OccurredAt: e.CreatedAt,
+		Data:       json.RawMessage(e.Payload),
+	}
+}
+
+// Sink publishes events, an event is retried until Publish returns nil, so a
+// sink may see an event more than once.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// ParseSink returns the sink of spec, which is one of stdout, file:PATH for a
+// ndjson file, or a http or https webhook url.
+func ParseSink(spec string) (Sink, error) {
+	switch {
+	case spec == "stdout":
+		return NewWriterSink("stdout", os.Stdout), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSink(strings.TrimPrefix(spec, "file:"))
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if _, err := url.Parse(spec); err != nil {
+			return nil, fmt.Errorf("invalid webhook url: %v", err)
+		}
+		return NewWebhookSink(spec, &http.Client{Timeout: 10 * time.Second}), nil
+	}
+	return nil, fmt.Errorf("invalid sink %q, must be stdout, file:PATH or a http url", spec)
+}
+
+// WriterSink writes events as ndjson to w.
+type WriterSink struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{name: name, w: w}
+}
+
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+func (s *WriterSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// FileSink appends events as ndjson to a file and syncs it after each event.
+type FileSink struct {
+	WriterSink
+	f *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file sink path not set")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{WriterSink: WriterSink{name: "file:" + path, w: f}, f: f}, nil
+}
+
+func (s *FileSink) Publish(ctx context.Context, event Event) error {
+	if err := s.WriterSink.Publish(ctx, event); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// WebhookSink posts each event as json to a url, any status other than 2xx
+// fails the delivery.
+type WebhookSink struct {
+	url        string
+	httpClient *http.Client
+}
+
+func NewWebhookSink(url string, httpClient *http.Client) *WebhookSink {
+	return &WebhookSink{url: url, httpClient: httpClient}
+}
+
+func (s *WebhookSink) Name() string {
+	return s.url
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", fmt.Sprint(event.ID))
+	req.Header.Set("X-Event-Type", event.Type)
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
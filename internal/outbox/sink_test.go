@@ -0,0 +1,65 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSink(t *testing.T) {
+	sink, err := ParseSink("stdout")
+	require.NoError(t, err)
+	assert.Equal(t, "stdout", sink.Name())
+
+	sink, err = ParseSink("https://example.com/hook")
+	require.NoError(t, err)
+	assert.IsType(t, &WebhookSink{}, sink)
+
+	_, err = ParseSink("kafka://events")
+	assert.ErrorContains(t, err, "invalid sink")
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := ParseSink("file:" + path)
+	require.NoError(t, err)
+	defer sink.(*FileSink).Close()
+
+	for _, id := range []int64{1, 2} {
+		require.NoError(t, sink.Publish(context.Background(), Event{ID: id, Type: "user.created", Data: json.RawMessage(`{}`)}))
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1,"type":"user.created","userId":"","requestId":"","occurredAt":"0001-01-01T00:00:00Z","data":{}}
+{"id":2,"type":"user.created","userId":"","requestId":"","occurredAt":"0001-01-01T00:00:00Z","data":{}}
+`, string(data))
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusNoContent
+	var got Event
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, server.Client())
+	event := Event{ID: 7, Type: "user.updated", UserID: "u1", Data: json.RawMessage(`{"id":"u1"}`)}
+	require.NoError(t, sink.Publish(context.Background(), event))
+	assert.Equal(t, "7", header.Get("X-Event-ID"))
+	assert.Equal(t, "user.updated", header.Get("X-Event-Type"))
+	assert.Equal(t, event, got)
+
+	status = http.StatusInternalServerError
+	assert.ErrorContains(t, sink.Publish(context.Background(), event), "unexpected status code 500")
+}
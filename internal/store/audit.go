@@ -0,0 +1,125 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+//go:generate mockgen -source=audit.go -destination=audit_mock.go -package=store
+type AuditRepository interface {
+	CreateBatch(logs []AuditLog) error
+	// List returns the logs matching filter, the newest first.
+	List(filter AuditFilter, page, pageSize int) ([]AuditLog, int64, error)
+}
+
+// AuditFilter selects audit logs, zero value fields match all logs.
+type AuditFilter struct {
+	TargetID      string
+	Actor         string
+	Action        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func (f AuditFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.TargetID != "" {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.Actor != "" {
+		db = db.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if !f.CreatedAfter.IsZero() {
+		db = db.Where("created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", f.CreatedBefore)
+	}
+	return db
+}
+
+// NewUserAuditLog returns the audit log of a change of a user from before to
+// after, with only the changed fields in the diff. before is nil for a create
+// and after is nil for a delete.
+func NewUserAuditLog(actor, requestID string, before, after *User) AuditLog {
+	log := AuditLog{Actor: actor, RequestID: requestID}
+	var beforeFields, afterFields map[string]interface{}
+	switch {
+	case before == nil:
+		log.Action = AuditActionCreate
+		log.TargetID = after.ID
+		afterFields = auditFields(after)
+	case after == nil:
+		log.Action = AuditActionDelete
+		log.TargetID = before.ID
+		beforeFields = auditFields(before)
+	default:
+		log.Action = AuditActionUpdate
+		log.TargetID = after.ID
+		beforeFields = auditFields(before)
+		afterFields = auditFields(after)
+		for k, v := range beforeFields {
+			if afterFields[k] == v {
+				delete(beforeFields, k)
+				delete(afterFields, k)
+			}
+		}
+	}
+	if beforeFields != nil {
+		data, _ := json.Marshal(beforeFields)
+		log.Before = string(data)
+	}
+	if afterFields != nil {
+		data, _ := json.Marshal(afterFields)
+		log.After = string(data)
+	}
+	return log
+}
+
+// auditFields are the audited fields of a user, the password is left out.
+func auditFields(u *User) map[string]interface{} {
+	return map[string]interface{}{
+		"name":  u.Name,
+		"email": u.Email,
+		"age":   u.Age,
+	}
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB, opts ...Option) AuditRepository {
+	return &auditRepository{db: newOptions(opts).session(db)}
+}
+
+func (r *auditRepository) CreateBatch(logs []AuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return r.db.Create(&logs).Error
+}
+
+func (r *auditRepository) List(filter AuditFilter, page, pageSize int) ([]AuditLog, int64, error) {
+	var logs []AuditLog
+	var total int64
+	if err := filter.apply(r.db.Model(&AuditLog{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := filter.apply(r.db.Model(&AuditLog{})).Order("id DESC").Offset(offset).Limit(pageSize).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *UserTestSuite) TestTransactionAudit() {
+	st := NewStore(s.db)
+	user := &User{ID: uuid.NewString(), Name: "audit1", Email: "audit1@bb.com"}
+	defer s.db.Unscoped().Delete(&User{ID: user.ID})
+	defer s.db.Where("target_id = ?", user.ID).Delete(&AuditLog{})
+
+	// the user and the audit log are rolled back together
+	errRollback := errors.New("rollback")
+	err := st.Transaction(func(tx Store) error {
+		if err := tx.Users().Create(user); err != nil {
+			return err
+		}
+		if err := tx.Audits().CreateBatch([]AuditLog{NewUserAuditLog("admin", "req1", nil, user)}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	s.Require().ErrorIs(err, errRollback)
+	_, err = st.Users().GetByID(user.ID)
+	s.Require().ErrorContains(err, "not found")
+	_, total, err := st.Audits().List(AuditFilter{TargetID: user.ID}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(0, total)
+
+	start := time.Now().Add(-time.Second)
+	err = st.Transaction(func(tx Store) error {
+		if err := tx.Users().Create(user); err != nil {
+			return err
+		}
+		return tx.Audits().CreateBatch([]AuditLog{NewUserAuditLog("admin", "req2", nil, user)})
+	})
+	s.Require().NoError(err)
+	before := *user
+	user.Age = 20
+	err = st.Transaction(func(tx Store) error {
+		if err := tx.Users().Update(user); err != nil {
+			return err
+		}
+		return tx.Audits().CreateBatch([]AuditLog{NewUserAuditLog("root", "req3", &before, user)})
+	})
+	s.Require().NoError(err)
+
+	logs, total, err := st.Audits().List(AuditFilter{TargetID: user.ID, CreatedAfter: start}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(2, total)
+	s.Require().Len(logs, 2)
+	s.Equal(AuditActionUpdate, logs[0].Action, "the newest log first")
+	s.Equal("req3", logs[0].RequestID)
+	s.JSONEq(`{"age":20}`, logs[0].After)
+	s.Equal(AuditActionCreate, logs[1].Action)
+
+	logs, total, err = st.Audits().List(AuditFilter{TargetID: user.ID, Actor: "root"}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(1, total)
+	s.Equal("root", logs[0].Actor)
+
+	_, total, err = st.Audits().List(AuditFilter{TargetID: user.ID, CreatedBefore: start}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(0, total)
+}
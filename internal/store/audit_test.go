@@ -0,0 +1,30 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUserAuditLog(t *testing.T) {
+	before := &User{ID: "id1", Name: "liuliu", Email: "aa@bb.com", Password: "secret", Age: 10}
+	after := &User{ID: "id1", Name: "liuliu", Email: "cc@dd.com", Password: "secret2", Age: 10}
+
+	log := NewUserAuditLog("admin", "req1", nil, before)
+	assert.Equal(t, AuditActionCreate, log.Action)
+	assert.Equal(t, "id1", log.TargetID)
+	assert.Equal(t, "admin", log.Actor)
+	assert.Equal(t, "req1", log.RequestID)
+	assert.Empty(t, log.Before)
+	assert.JSONEq(t, `{"name":"liuliu","email":"aa@bb.com","age":10}`, log.After)
+
+	log = NewUserAuditLog("admin", "req1", before, after)
+	assert.Equal(t, AuditActionUpdate, log.Action)
+	assert.JSONEq(t, `{"email":"aa@bb.com"}`, log.Before)
+	assert.JSONEq(t, `{"email":"cc@dd.com"}`, log.After)
+
+	log = NewUserAuditLog("admin", "req1", after, nil)
+	assert.Equal(t, AuditActionDelete, log.Action)
+	assert.JSONEq(t, `{"name":"liuliu","email":"cc@dd.com","age":10}`, log.Before)
+	assert.Empty(t, log.After)
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/config"
+)
+
+// tlsConfigName is the name the custom TLS config is registered with when a
+// database CA file is configured.
+const tlsConfigName = "user_manage"
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Open opens the database configured by conf and applies the connection pool
+// settings.
+func Open(conf *config.Config, gormConf *gorm.Config) (*gorm.DB, error) {
+	dialector, err := Dialector(conf)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dialector, gormConf)
+	if err != nil {
+		return nil, err
+	}
+	if err := ApplyPool(db, conf); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Dialector returns the gorm dialector of the driver selected by conf.DBDriver,
+// an empty driver means mysql.
+func Dialector(conf *config.Config) (gorm.Dialector, error) {
+	switch conf.DBDriver {
+	case "", DriverMySQL:
+		dsn, err := MySQLDSN(conf)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(PostgresDSN(conf)), nil
+	case DriverSQLite:
+		return sqlite.Open(SQLiteDSN(conf)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", conf.DBDriver)
+	}
+}
+
+// MySQLDSN builds the MySQL DSN from conf, conf.DSN is returned as is when set.
+func MySQLDSN(conf *config.Config) (string, error) {
+	if conf.DSN != "" {
+		return conf.DSN, nil
+	}
+
+	c := mysqldriver.NewConfig()
+	c.User = conf.DBUser
+	c.Passwd = conf.DBPassword
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(conf.DBHost, strconv.Itoa(conf.DBPort))
+	c.DBName = conf.DBName
+	c.ParseTime = true
+	c.Timeout = conf.DBTimeout
+	c.ReadTimeout = conf.DBReadTimeout
+	c.WriteTimeout = conf.DBWriteTimeout
+	if conf.DBCharset != "" {
+		c.Params = map[string]string{"charset": conf.DBCharset}
+	}
+	if conf.DBLoc != "" {
+		loc, err := time.LoadLocation(conf.DBLoc)
+		if err != nil {
+			return "", fmt.Errorf("load dbloc failed: %v", err)
+		}
+		c.Loc = loc
+	}
+
+	c.TLSConfig = conf.DBTLS
+	if conf.DBTLSCA != "" {
+		tlsConf, err := loadTLSConfig(conf)
+		if err != nil {
+			return "", err
+		}
+		if err := mysqldriver.RegisterTLSConfig(tlsConfigName, tlsConf); err != nil {
+			return "", fmt.Errorf("register tls config failed: %v", err)
+		}
+		c.TLSConfig = tlsConfigName
+	}
+	return c.FormatDSN(), nil
+}
+
+// PostgresDSN builds the PostgreSQL connection URL from conf, conf.DSN is
+// returned as is when set.
+func PostgresDSN(conf *config.Config) string {
+	if conf.DSN != "" {
+		return conf.DSN
+	}
+
+	query := url.Values{}
+	switch conf.DBTLS {
+	case "", "false":
+		query.Set("sslmode", "disable")
+	case "true":
+		query.Set("sslmode", "verify-full")
+	case "skip-verify":
+		query.Set("sslmode", "require")
+	case "preferred":
+		query.Set("sslmode", "prefer")
+	}
+	if conf.DBTLSCA != "" {
+		query.Set("sslrootcert", conf.DBTLSCA)
+	}
+	// Local is the time zone of this process, which postgres does not know, the
+	// session keeps the time zone of the server then
+	if conf.DBLoc != "" && conf.DBLoc != "Local" {
+		query.Set("TimeZone", conf.DBLoc)
+	}
+	if conf.DBTimeout > 0 {
+		query.Set("connect_timeout", strconv.Itoa(int(conf.DBTimeout.Seconds())))
+	}
+	if conf.DBCharset != "" && conf.DBCharset != "utf8mb4" {
+		query.Set("client_encoding", conf.DBCharset)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(conf.DBUser, conf.DBPassword),
+		Host:     net.JoinHostPort(conf.DBHost, strconv.Itoa(conf.DBPort)),
+		Path:     "/" + conf.DBName,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// SQLiteDSN builds the SQLite DSN from conf, conf.DBName is the database file
+// path and conf.DSN is returned as is when set.
+func SQLiteDSN(conf *config.Config) string {
+	if conf.DSN != "" {
+		return conf.DSN
+	}
+	query := url.Values{}
+	query.Add("_pragma", "busy_timeout(5000)")
+	query.Add("_pragma", "foreign_keys(1)")
+	return fmt.Sprintf("file:%s?%s", conf.DBName, query.Encode())
+}
+
+func loadTLSConfig(conf *config.Config) (*tls.Config, error) {
+	pem, err := os.ReadFile(conf.DBTLSCA)
+	if err != nil {
+		return nil, fmt.Errorf("read dbtlsca failed: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("parse dbtlsca failed: no certificate found in %s", conf.DBTLSCA)
+	}
+	return &tls.Config{
+		RootCAs:            pool,
+		ServerName:         conf.DBHost,
+		InsecureSkipVerify: conf.DBTLS == "skip-verify",
+	}, nil
+}
+
+// defaultMaxIdleConns is the maximum number of idle connections database/sql
+// keeps when it is not set.
+const defaultMaxIdleConns = 2
+
+// ApplyPool applies the connection pool settings of conf to the underlying
+// *sql.DB, it is safe to call on a database in use. A zero DBMaxIdleConns
+// sets the database/sql default, also when it was set to another value before.
+func ApplyPool(db *gorm.DB, conf *config.Config) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(conf.DBMaxOpenConns)
+	maxIdleConns := conf.DBMaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(conf.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(conf.DBConnMaxIdleTime)
+	return nil
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/clock"
+)
+
+// memoryUserRepository is a UserRepository keeping the users in memory, it
+// behaves like userRepository on SQLite: the errors of missing users are
+// gorm.ErrRecordNotFound, deleted users are soft deleted and keep their email
+// taken, and List returns the users in creation order.
+type memoryUserRepository struct {
+	mu sync.RWMutex
+	// users are all the users by id, the soft deleted ones too
+	users map[string]*memoryUser
+	seq   int64
+	now   func() time.Time
+}
+
+type memoryUser struct {
+	User
+	// seq is the creation order of the user, like the rowid of SQLite
+	seq int64
+}
+
+// NewMemoryUserRepository returns an empty in memory UserRepository, it is
+// safe for concurrent use but has no transactions.
+func NewMemoryUserRepository(opts ...Option) UserRepository {
+	c := newOptions(opts).clock
+	if c == nil {
+		c = clock.System
+	}
+	return &memoryUserRepository{
+		users: make(map[string]*memoryUser),
+		now:   c.Now,
+	}
+}
+
+func duplicateError(field, value string) error {
+	return fmt.Errorf("user %s %s exists: %w", field, value, gorm.ErrDuplicatedKey)
+}
+
+// checkUnique returns an error if the id or email of u is taken by another
+// user, it must be called with r.mu held.
+func (r *memoryUserRepository) checkUnique(u *User, update bool) error {
+	if _, ok := r.users[u.ID]; ok && !update {
+		return duplicateError("id", u.ID)
+	}
+	for _, other := range r.users {
+		if other.ID != u.ID && other.Email == u.Email {
+			return duplicateError("email", u.Email)
+		}
+	}
+	return nil
+}
+
+// insert adds u and sets its timestamps like autoCreateTime and
+// autoUpdateTime, it must be called with r.mu held.
+func (r *memoryUserRepository) insert(u *User) {
+	now := r.now()
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = now
+	}
+	if u.UpdatedAt.IsZero() {
+		u.UpdatedAt = now
+	}
+	r.seq++
+	r.users[u.ID] = &memoryUser{User: *u, seq: r.seq}
+}
+
+func (r *memoryUserRepository) Create(user *User) error {
+	if user.ID == "" {
+		return errors.New("user id not set")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkUnique(user, false); err != nil {
+		return err
+	}
+	r.insert(user)
+	return nil
+}
+
+// CreateBatch creates users, either all or none are created.
+func (r *memoryUserRepository) CreateBatch(users []User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make(map[string]struct{}, len(users))
+	emails := make(map[string]struct{}, len(users))
+	for i := range users {
+		if users[i].ID == "" {
+			return errors.New("user id not set")
+		}
+		if err := r.checkUnique(&users[i], false); err != nil {
+			return err
+		}
+		if _, ok := ids[users[i].ID]; ok {
+			return duplicateError("id", users[i].ID)
+		}
+		if _, ok := emails[users[i].Email]; ok {
+			return duplicateError("email", users[i].Email)
+		}
+		ids[users[i].ID] = struct{}{}
+		emails[users[i].Email] = struct{}{}
+	}
+	for i := range users {
+		r.insert(&users[i])
+	}
+	return nil
+}
+
+// find returns the users which are not deleted and match, in creation order.
+// It must be called with r.mu held.
+func (r *memoryUserRepository) find(match func(u *User) bool) []User {
+	var found []*memoryUser
+	for _, u := range r.users {
+		if !u.DeletedAt.Valid && match(&u.User) {
+			found = append(found, u)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].seq < found[j].seq
+	})
+	users := make([]User, 0, len(found))
+	for _, u := range found {
+		users = append(users, u.User)
+	}
+	return users
+}
+
+func (r *memoryUserRepository) first(match func(u *User) bool) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := r.find(match)
+	if len(users) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &users[0], nil
+}
+
+func (r *memoryUserRepository) GetByID(id string) (*User, error) {
+	return r.first(func(u *User) bool { return u.ID == id })
+}
+
+func (r *memoryUserRepository) GetByEmail(email string) (*User, error) {
+	return r.first(func(u *User) bool { return u.Email == email })
+}
+
+func (r *memoryUserRepository) GetByIDs(ids []string) ([]User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.find(func(u *User) bool { return containsString(ids, u.ID) }), nil
+}
+
+func (r *memoryUserRepository) GetByEmails(emails []string) ([]User, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.find(func(u *User) bool { return containsString(emails, u.Email) }), nil
+}
+
+// Update saves all the fields of user like gorm's Save: a user which does not
+// exist is created, and a soft deleted user is overwritten.
+func (r *memoryUserRepository) Update(user *User) error {
+	if user.ID == "" {
+		return errors.New("user id not set")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkUnique(user, true); err != nil {
+		return err
+	}
+	old, ok := r.users[user.ID]
+	if !ok {
+		r.insert(user)
+		return nil
+	}
+	if !old.DeletedAt.Valid {
+		user.UpdatedAt = r.now()
+	}
+	old.User = *user
+	return nil
+}
+
+// DeleteByID soft deletes the user, deleting a missing user is not an error.
+func (r *memoryUserRepository) DeleteByID(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok && !u.DeletedAt.Valid {
+		u.DeletedAt = gorm.DeletedAt{Time: r.now(), Valid: true}
+	}
+	return nil
+}
+
+// List pages the users like Offset and Limit: an offset below 0 is ignored,
+// and a page size below 0 is unlimited.
+func (r *memoryUserRepository) List(page, pageSize int) ([]User, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := r.find(func(u *User) bool { return true })
+	total := int64(len(users))
+
+	offset := (page - 1) * pageSize
+	if offset > 0 {
+		if offset >= len(users) {
+			return []User{}, total, nil
+		}
+		users = users[offset:]
+	}
+	if pageSize >= 0 && pageSize < len(users) {
+		users = users[:pageSize]
+	}
+	return users, total, nil
+}
+
+// matching returns the users matching filter ordered by id, it must be called
+// with r.mu held.
+func (r *memoryUserRepository) matching(filter UserFilter) []User {
+	users := r.find(filter.match)
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
+
+// Iterate visits a snapshot of the matching users taken before the first
+// batch.
+func (r *memoryUserRepository) Iterate(filter UserFilter, batchSize int, fn func(users []User) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size %d not positive", batchSize)
+	}
+	r.mu.RLock()
+	users := r.matching(filter)
+	r.mu.RUnlock()
+	for len(users) > 0 {
+		n := batchSize
+		if n > len(users) {
+			n = len(users)
+		}
+		if err := fn(users[:n:n]); err != nil {
+			return err
+		}
+		users = users[n:]
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) Count(filter UserFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return int64(len(r.find(filter.match))), nil
+}
+
+func (r *memoryUserRepository) DeleteBatch(filter UserFilter, limit int) ([]User, error) {
+	return r.limitBatch(filter, limit, func(u *memoryUser, now time.Time) {
+		u.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	})
+}
+
+func (r *memoryUserRepository) UpdateBatch(filter UserFilter, fields UserFields, limit int) ([]User, error) {
+	if len(fields.updates()) == 0 {
+		return nil, errors.New("user fields not set")
+	}
+	return r.limitBatch(filter, limit, func(u *memoryUser, now time.Time) {
+		fields.Apply(&u.User)
+		u.UpdatedAt = now
+	})
+}
+
+func (r *memoryUserRepository) limitBatch(filter UserFilter, limit int, op func(u *memoryUser, now time.Time)) ([]User, error) {
+	if filter.IsZero() {
+		return nil, ErrEmptyFilter
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := r.matching(filter)
+	if len(users) > limit {
+		return nil, &BatchLimitError{Matched: int64(len(users)), Limit: limit}
+	}
+	now := r.now()
+	for _, u := range users {
+		op(r.users[u.ID], now)
+	}
+	return users, nil
+}
+
+// match is apply for a user in memory, the name matches case-insensitively
+// like LIKE on SQLite and MySQL.
+func (f UserFilter) match(u *User) bool {
+	if len(f.IDs) > 0 && !containsString(f.IDs, u.ID) {
+		return false
+	}
+	return (f.Name == "" || strings.Contains(strings.ToLower(u.Name), strings.ToLower(f.Name))) &&
+		(f.Email == "" || u.Email == f.Email) &&
+		(f.CreatedAfter.IsZero() || !u.CreatedAt.Before(f.CreatedAfter)) &&
+		(f.CreatedBefore.IsZero() || u.CreatedAt.Before(f.CreatedBefore))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package store
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFS embed.FS
+
+const (
+	migrationTable     = "schema_migrations"
+	migrationLockTable = "schema_migrations_lock"
+	migrationLockName  = "user_manage_migrate"
+	// migrationLockKey is the postgres advisory lock key.
+	migrationLockKey = 1952539813
+)
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned schema change with its up and down SQL.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations of the database dialect. All
+// operations hold a database lock, so replicas never migrate concurrently.
+type Migrator struct {
+	db         *gorm.DB
+	dialect    string
+	migrations []Migration
+
+	// LockTimeout is how long to wait for the migration lock.
+	LockTimeout time.Duration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:          db,
+		dialect:     dialect,
+		migrations:  migrations,
+		LockTimeout: time.Minute,
+	}, nil
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %s", dialect)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		matches := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(matches[1], 10, 64)
+		data, err := fs.ReadFile(migrationFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if matches[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down sql", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies all pending migrations in version order and returns them.
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(func(conn *gorm.DB) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := execStatements(tx, migration.Up); err != nil {
+					return err
+				}
+				return tx.Exec(fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (?, ?, ?)", migrationTable),
+					migration.Version, migration.Name, time.Now()).Error
+			})
+			if err != nil {
+				return fmt.Errorf("apply migration %d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the latest steps applied migrations and returns them.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(func(conn *gorm.DB) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := execStatements(tx, migration.Down); err != nil {
+					return err
+				}
+				return tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = ?", migrationTable), migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("revert migration %d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status returns all known migrations and whether they are applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.withLock(func(conn *gorm.DB) error {
+		versions, err := m.appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			appliedAt, ok := versions[migration.Version]
+			status = append(status, MigrationStatus{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+	return status, err
+}
+
+func (m *Migrator) appliedVersions(conn *gorm.DB) (map[int64]time.Time, error) {
+	err := conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)",
+		migrationTable)).Error
+	if err != nil {
+		return nil, fmt.Errorf("create %s table failed: %v", migrationTable, err)
+	}
+
+	var rows []struct {
+		Version   int64
+		AppliedAt time.Time
+	}
+	err = conn.Raw(fmt.Sprintf("SELECT version, applied_at FROM %s", migrationTable)).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("query %s failed: %v", migrationTable, err)
+	}
+	versions := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row.AppliedAt
+	}
+	return versions, nil
+}
+
+// withLock runs fn on a single connection holding the migration lock.
+func (m *Migrator) withLock(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		unlock, err := m.lock(conn)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) lock(conn *gorm.DB) (func(), error) {
+	switch m.dialect {
+	case DriverMySQL:
+		var got sql.NullInt64
+		err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationLockName, int(m.LockTimeout.Seconds())).Scan(&got).Error
+		if err != nil {
+			return nil, fmt.Errorf("acquire migration lock failed: %v", err)
+		}
+		if got.Int64 != 1 {
+			return nil, fmt.Errorf("acquire migration lock failed: timeout after %s", m.LockTimeout)
+		}
+		return func() {
+			var released sql.NullInt64
+			conn.Raw("SELECT RELEASE_LOCK(?)", migrationLockName).Scan(&released)
+		}, nil
+	case DriverPostgres:
+		deadline := time.Now().Add(m.LockTimeout)
+		for {
+			var got bool
+			err := conn.Raw("SELECT pg_try_advisory_lock(?)", migrationLockKey).Scan(&got).Error
+			if err != nil {
+				return nil, fmt.Errorf("acquire migration lock failed: %v", err)
+			}
+			if got {
+				break
+			}
+			if time.Now().After(deadline) {
+				return nil, fmt.Errorf("acquire migration lock failed: timeout after %s", m.LockTimeout)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		return func() {
+			var released bool
+			conn.Raw("SELECT pg_advisory_unlock(?)", migrationLockKey).Scan(&released)
+		}, nil
+	default:
+		return m.lockTable(conn)
+	}
+}
+
+// lockTable locks by inserting the single row of the lock table, it is used by
+// databases without named locks. A lock older than staleAfter is considered
+// left by a crashed process and is taken over.
+func (m *Migrator) lockTable(conn *gorm.DB) (func(), error) {
+	const staleAfter = 10 * time.Minute
+
+	err := conn.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INTEGER NOT NULL PRIMARY KEY, locked_at TIMESTAMP NOT NULL)",
+		migrationLockTable)).Error
+	if err != nil {
+		return nil, fmt.Errorf("create %s table failed: %v", migrationLockTable, err)
+	}
+
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		conn.Exec(fmt.Sprintf("DELETE FROM %s WHERE locked_at < ?", migrationLockTable), time.Now().Add(-staleAfter))
+		err = conn.Exec(fmt.Sprintf("INSERT INTO %s (id, locked_at) VALUES (1, ?)", migrationLockTable), time.Now()).Error
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("acquire migration lock failed: timeout after %s", m.LockTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return func() {
+		conn.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = 1", migrationLockTable))
+	}, nil
+}
+
+// execStatements executes the ';' terminated statements of a migration one by
+// one, as not all drivers support multiple statements in one call.
+func execStatements(tx *gorm.DB, script string) error {
+	for _, stmt := range strings.Split(script, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
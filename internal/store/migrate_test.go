@@ -0,0 +1,118 @@
+package store
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go-unittest-best-practice/internal/config"
+)
+
+func openTestSQLite(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+	db, err := Open(&config.Config{DBDriver: DriverSQLite, DBName: path}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, dialect := range []string{DriverMySQL, DriverPostgres, DriverSQLite} {
+		migrations, err := loadMigrations(dialect)
+		require.NoError(t, err, dialect)
+		require.NotEmpty(t, migrations, dialect)
+		for i, m := range migrations {
+			assert.EqualValues(t, i+1, m.Version, "%s migrations must be numbered continuously", dialect)
+		}
+	}
+
+	_, err := loadMigrations("oracle")
+	assert.ErrorContains(t, err, "no migrations for dialect oracle")
+}
+
+func TestMigrator(t *testing.T) {
+	db := openTestSQLite(t, filepath.Join(t.TempDir(), "migrate.db"))
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+
+	t.Run("up", func(t *testing.T) {
+		applied, err := migrator.Up()
+		require.NoError(t, err)
+		assert.Len(t, applied, len(migrator.migrations))
+
+		// the migrated schema must match the model
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(&User{}))
+		for _, field := range stmt.Schema.Fields {
+			assert.True(t, db.Migrator().HasColumn(&User{}, field.DBName), "column %s not found", field.DBName)
+		}
+
+		applied, err = migrator.Up()
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		status, err := migrator.Status()
+		require.NoError(t, err)
+		require.Len(t, status, len(migrator.migrations))
+		for _, s := range status {
+			assert.True(t, s.Applied)
+			assert.False(t, s.AppliedAt.IsZero())
+		}
+	})
+
+	t.Run("down", func(t *testing.T) {
+		reverted, err := migrator.Down(1)
+		require.NoError(t, err)
+		require.Len(t, reverted, 1)
+		assert.Equal(t, migrator.migrations[len(migrator.migrations)-1].Version, reverted[0].Version)
+
+		status, err := migrator.Status()
+		require.NoError(t, err)
+		assert.False(t, status[len(status)-1].Applied)
+	})
+}
+
+func TestMigratorConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrate.db")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	applied := make([]int, 3)
+	for i := range errs {
+		db := openTestSQLite(t, path)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			migrator, err := NewMigrator(db)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			migrations, err := migrator.Up()
+			errs[i] = err
+			applied[i] = len(migrations)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range errs {
+		assert.NoError(t, errs[i])
+		total += applied[i]
+	}
+	migrations, _ := loadMigrations(DriverSQLite)
+	assert.Equal(t, len(migrations), total, "every migration must be applied exactly once")
+}
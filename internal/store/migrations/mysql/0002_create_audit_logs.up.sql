@@ -0,0 +1,13 @@
+CREATE TABLE audit_logs (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    actor VARCHAR(128) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    target_id VARCHAR(191) NOT NULL,
+    `before` TEXT NULL,
+    `after` TEXT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_audit_logs_target_id (target_id, created_at),
+    INDEX idx_audit_logs_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,14 @@
+CREATE TABLE outbox_events (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    type VARCHAR(32) NOT NULL,
+    user_id VARCHAR(191) NOT NULL,
+    payload TEXT NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(3) NOT NULL,
+    last_error TEXT NULL,
+    published_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_outbox_events_pending (published_at, next_attempt_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,27 @@
+CREATE TABLE webhooks (
+    id VARCHAR(191) NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(191) NOT NULL,
+    events VARCHAR(191) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    updated_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE webhook_deliveries (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    webhook_id VARCHAR(191) NOT NULL,
+    event_id BIGINT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(3) NOT NULL,
+    response_status BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    delivered_at DATETIME(3) NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX idx_webhook_deliveries_event (webhook_id, event_id),
+    INDEX idx_webhook_deliveries_pending (status, next_attempt_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,12 @@
+CREATE TABLE audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(128) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    target_id VARCHAR(191) NOT NULL,
+    before TEXT NULL,
+    after TEXT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_audit_logs_target_id ON audit_logs (target_id, created_at);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at);
@@ -0,0 +1,13 @@
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    user_id VARCHAR(191) NOT NULL,
+    payload TEXT NOT NULL,
+    request_id VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT NULL,
+    published_at TIMESTAMPTZ NULL
+);
+CREATE INDEX idx_outbox_events_pending ON outbox_events (published_at, next_attempt_at);
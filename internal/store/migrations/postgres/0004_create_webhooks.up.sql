@@ -0,0 +1,25 @@
+CREATE TABLE webhooks (
+    id VARCHAR(191) PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(191) NOT NULL,
+    events VARCHAR(191) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id VARCHAR(191) NOT NULL,
+    event_id BIGINT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts BIGINT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    response_status BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    delivered_at TIMESTAMPTZ NULL
+);
+CREATE UNIQUE INDEX idx_webhook_deliveries_event ON webhook_deliveries (webhook_id, event_id);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,12 @@
+CREATE TABLE audit_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    before TEXT NULL,
+    after TEXT NULL,
+    request_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_audit_logs_target_id ON audit_logs (target_id, created_at);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs (created_at);
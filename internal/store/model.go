@@ -16,3 +16,64 @@ type User struct {
 	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
 	DeletedAt gorm.DeletedAt
 }
+
+// AuditLog is a change of a user, Before and After are json objects of the
+// changed fields, Before is empty for a create and After for a delete.
+type AuditLog struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Actor     string    `gorm:"size:128;not null"`
+	Action    string    `gorm:"size:32;not null"`
+	TargetID  string    `gorm:"size:191;not null;index"`
+	Before    string    `gorm:"type:text"`
+	After     string    `gorm:"type:text"`
+	RequestID string    `gorm:"size:64;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime;index"`
+}
+
+// OutboxEvent is a user event waiting to be published, it is written in the
+// transaction of the change. Payload is the json of the user after the change,
+// or before it for a delete.
+type OutboxEvent struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Type      string    `gorm:"size:32;not null"`
+	UserID    string    `gorm:"size:191;not null"`
+	Payload   string    `gorm:"type:text;not null"`
+	RequestID string    `gorm:"size:64;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+
+	// the delivery state
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null"`
+	LastError     string    `gorm:"type:text"`
+	PublishedAt   *time.Time
+}
+
+// Webhook is a subscription of an endpoint to the user events, Events is the
+// comma separated event types, empty means all.
+type Webhook struct {
+	ID        string    `gorm:"primaryKey;size:191"`
+	URL       string    `gorm:"type:text;not null"`
+	Secret    string    `gorm:"size:191;not null"`
+	Events    string    `gorm:"size:191;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+}
+
+// WebhookDelivery is the delivery of an outbox event to a webhook, there is at
+// most one delivery of an event to a webhook.
+type WebhookDelivery struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	WebhookID string    `gorm:"size:191;not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventID   int64     `gorm:"not null;uniqueIndex:idx_webhook_deliveries_event"`
+	EventType string    `gorm:"size:32;not null"`
+	Payload   string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+
+	// the delivery state
+	Status         string    `gorm:"size:16;not null"`
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `gorm:"not null"`
+	ResponseStatus int       `gorm:"not null;default:0"`
+	LastError      string    `gorm:"type:text"`
+	DeliveredAt    *time.Time
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+//go:generate mockgen -source=outbox.go -destination=outbox_mock.go -package=store
+type OutboxRepository interface {
+	CreateBatch(events []OutboxEvent) error
+	// Pending returns at most limit unpublished events due at now in id
+	// order.
+	Pending(now time.Time, limit int) ([]OutboxEvent, error)
+	MarkPublished(ids []int64, at time.Time) error
+	// MarkFailed records a failed attempt, the event is retried at
+	// nextAttemptAt.
+	MarkFailed(id int64, lastError string, nextAttemptAt time.Time) error
+	// DeletePublished deletes the events published before t.
+	DeletePublished(before time.Time) (int64, error)
+	// After returns at most limit events with an id greater than afterID in id
+	// order, published or not, of the types or of all types if types is empty.
+	After(afterID int64, types []string, limit int) ([]OutboxEvent, error)
+	// FirstID returns the id of the oldest event not deleted yet, 0 if there
+	// is none.
+	FirstID() (int64, error)
+	// LastID returns the id of the last event, 0 if there is none.
+	LastID() (int64, error)
+}
+
+// eventUser is the payload of the user events, the same as the user of the
+// API.
+type eventUser struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Age       int       `json:"age"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NewUserOutboxEvent returns the event of a change of a user from before to
+// after, before is nil for a create and after is nil for a delete. The event
+// is due when it is created.
+func NewUserOutboxEvent(requestID string, before, after *User) OutboxEvent {
+	event := OutboxEvent{RequestID: requestID}
+	user := after
+	switch {
+	case before == nil:
+		event.Type = EventUserCreated
+	case after == nil:
+		event.Type = EventUserDeleted
+		user = before
+	default:
+		event.Type = EventUserUpdated
+	}
+	event.UserID = user.ID
+	data, _ := json.Marshal(&eventUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Age:       user.Age,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	})
+	event.Payload = string(data)
+	return event
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB, opts ...Option) OutboxRepository {
+	return &outboxRepository{db: newOptions(opts).session(db)}
+}
+
+// CreateBatch creates the events, the events without a next attempt time are
+// due at the time of the clock of the repository.
+func (r *outboxRepository) CreateBatch(events []OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := r.db.NowFunc()
+	for i := range events {
+		if events[i].NextAttemptAt.IsZero() {
+			events[i].NextAttemptAt = now
+		}
+	}
+	return r.db.Create(&events).Error
+}
+
+func (r *outboxRepository) Pending(now time.Time, limit int) ([]OutboxEvent, error) {
+	var events []OutboxEvent
+	err := r.db.Where("published_at IS NULL AND next_attempt_at <= ?", now).
+		Order("id").Limit(limit).Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ids []int64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&OutboxEvent{}).Where("id IN ?", ids).Update("published_at", at).Error
+}
+
+func (r *outboxRepository) MarkFailed(id int64, lastError string, nextAttemptAt time.Time) error {
+	return r.db.Model(&OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	}).Error
+}
+
+func (r *outboxRepository) DeletePublished(before time.Time) (int64, error) {
+	result := r.db.Where("published_at IS NOT NULL AND published_at < ?", before).Delete(&OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *outboxRepository) After(afterID int64, types []string, limit int) ([]OutboxEvent, error) {
+	db := r.db.Where("id > ?", afterID)
+	if len(types) > 0 {
+		db = db.Where("type IN ?", types)
+	}
+	var events []OutboxEvent
+	if err := db.Order("id").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) FirstID() (int64, error) {
+	var id int64
+	err := r.db.Model(&OutboxEvent{}).Select("COALESCE(MIN(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (r *outboxRepository) LastID() (int64, error) {
+	var id int64
+	err := r.db.Model(&OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
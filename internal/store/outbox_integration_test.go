@@ -0,0 +1,118 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-unittest-best-practice/internal/clock"
+)
+
+func (s *UserTestSuite) TestOutbox() {
+	repo := NewOutboxRepository(s.db)
+	user := &User{ID: uuid.NewString(), Name: "outbox1", Email: "outbox1@bb.com"}
+	defer s.db.Where("user_id = ?", user.ID).Delete(&OutboxEvent{})
+
+	now := time.Now()
+	created := NewUserOutboxEvent("req1", nil, user)
+	created.NextAttemptAt = now.Add(-time.Minute)
+	deleted := NewUserOutboxEvent("req2", user, nil)
+	deleted.NextAttemptAt = now.Add(-time.Minute)
+	s.Require().NoError(repo.CreateBatch([]OutboxEvent{created, deleted}))
+
+	pending, err := repo.Pending(now, 10)
+	s.Require().NoError(err)
+	pending = s.userEvents(pending, user.ID)
+	s.Require().Len(pending, 2)
+	s.Equal(EventUserCreated, pending[0].Type)
+	s.Equal(EventUserDeleted, pending[1].Type)
+	s.JSONEq(`{"id":"`+user.ID+`","name":"outbox1","email":"outbox1@bb.com","age":0,"createdAt":"0001-01-01T00:00:00Z","updatedAt":"0001-01-01T00:00:00Z"}`, pending[1].Payload)
+
+	// the failed event is not due until the next attempt
+	s.Require().NoError(repo.MarkFailed(pending[1].ID, "timeout", now.Add(time.Minute)))
+	s.Require().NoError(repo.MarkPublished([]int64{pending[0].ID}, now))
+	pending, err = repo.Pending(now, 10)
+	s.Require().NoError(err)
+	s.Len(s.userEvents(pending, user.ID), 0)
+
+	pending, err = repo.Pending(now.Add(2*time.Minute), 10)
+	s.Require().NoError(err)
+	pending = s.userEvents(pending, user.ID)
+	s.Require().Len(pending, 1)
+	s.Equal(1, pending[0].Attempts)
+	s.Equal("timeout", pending[0].LastError)
+
+	n, err := repo.DeletePublished(now.Add(time.Second))
+	s.Require().NoError(err)
+	s.GreaterOrEqual(n, int64(1))
+	var count int64
+	s.Require().NoError(s.db.Model(&OutboxEvent{}).Where("user_id = ?", user.ID).Count(&count).Error)
+	s.EqualValues(1, count, "the unpublished event is kept")
+}
+
+// userEvents returns the events of the user, other tests may share the table.
+func (s *UserTestSuite) userEvents(events []OutboxEvent, userID string) []OutboxEvent {
+	var result []OutboxEvent
+	for _, e := range events {
+		if e.UserID == userID {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func (s *UserTestSuite) TestOutboxClock() {
+	now := time.Date(2025, 7, 20, 8, 13, 21, 0, time.UTC)
+	repo := NewOutboxRepository(s.db, WithClock(clock.NewFake(now)))
+	user := &User{ID: uuid.NewString(), Name: "outbox3", Email: "outbox3@bb.com"}
+	defer s.db.Where("user_id = ?", user.ID).Delete(&OutboxEvent{})
+
+	// the event is due at the time of the clock of the repository
+	s.Require().NoError(repo.CreateBatch([]OutboxEvent{NewUserOutboxEvent("req1", nil, user)}))
+	pending, err := repo.Pending(now.Add(-time.Second), 10)
+	s.Require().NoError(err)
+	s.Empty(s.userEvents(pending, user.ID))
+	pending, err = repo.Pending(now, 10)
+	s.Require().NoError(err)
+	pending = s.userEvents(pending, user.ID)
+	s.Require().Len(pending, 1)
+	s.True(pending[0].NextAttemptAt.Equal(now), "next attempt at %v", pending[0].NextAttemptAt)
+}
+
+func (s *UserTestSuite) TestOutboxAfter() {
+	repo := NewOutboxRepository(s.db)
+	user := &User{ID: uuid.NewString(), Name: "outbox2", Email: "outbox2@bb.com"}
+	defer s.db.Where("user_id = ?", user.ID).Delete(&OutboxEvent{})
+
+	lastID, err := repo.LastID()
+	s.Require().NoError(err)
+	updated := *user
+	updated.Age = 20
+	s.Require().NoError(repo.CreateBatch([]OutboxEvent{
+		NewUserOutboxEvent("req1", nil, user),
+		NewUserOutboxEvent("req2", user, &updated),
+		NewUserOutboxEvent("req3", &updated, nil),
+	}))
+
+	events, err := repo.After(lastID, nil, 10)
+	s.Require().NoError(err)
+	events = s.userEvents(events, user.ID)
+	s.Require().Len(events, 3)
+	newLastID, err := repo.LastID()
+	s.Require().NoError(err)
+	s.Equal(events[2].ID, newLastID)
+	firstID, err := repo.FirstID()
+	s.Require().NoError(err)
+	s.Positive(firstID)
+	s.LessOrEqual(firstID, events[0].ID)
+
+	events, err = repo.After(events[0].ID, []string{EventUserCreated, EventUserDeleted}, 10)
+	s.Require().NoError(err)
+	events = s.userEvents(events, user.ID)
+	s.Require().Len(events, 1)
+	s.Equal(EventUserDeleted, events[0].Type)
+
+	events, err = repo.After(lastID, nil, 1)
+	s.Require().NoError(err)
+	s.Len(events, 1)
+}
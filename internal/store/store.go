@@ -0,0 +1,82 @@
+package store
+
+import (
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/clock"
+)
+
+// Store gives access to the repositories, the repositories of the Store passed
+// to the Transaction fn share the transaction.
+//
+//go:generate mockgen -source=store.go -destination=store_mock.go -package=store
+type Store interface {
+	Users() UserRepository
+	Audits() AuditRepository
+	Outbox() OutboxRepository
+	Webhooks() WebhookRepository
+	// Transaction runs fn in a transaction, which is committed if fn returns
+	// nil and rolled back otherwise.
+	Transaction(fn func(tx Store) error) error
+}
+
+// Option configures a Store or a repository.
+type Option func(o *options)
+
+type options struct {
+	clock clock.Clock
+}
+
+// WithClock sets the clock of the created, updated and deleted times, the
+// clock of db by default.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// session returns db with the options applied.
+func (o options) session(db *gorm.DB) *gorm.DB {
+	if o.clock == nil {
+		return db
+	}
+	return db.Session(&gorm.Session{NowFunc: o.clock.Now})
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB, opts ...Option) Store {
+	return &gormStore{db: newOptions(opts).session(db)}
+}
+
+func (s *gormStore) Users() UserRepository {
+	return NewUserRepository(s.db)
+}
+
+func (s *gormStore) Audits() AuditRepository {
+	return NewAuditRepository(s.db)
+}
+
+func (s *gormStore) Outbox() OutboxRepository {
+	return NewOutboxRepository(s.db)
+}
+
+func (s *gormStore) Webhooks() WebhookRepository {
+	return NewWebhookRepository(s.db)
+}
+
+func (s *gormStore) Transaction(fn func(tx Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
@@ -0,0 +1,110 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: store.go
+//
+// Generated by this command:
+//
+//	mockgen -source=store.go -destination=store_mock.go -package=store
+//
+
+// Package store is a generated GoMock package.
+package store
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// Audits mocks base method.
+func (m *MockStore) Audits() AuditRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Audits")
+	ret0, _ := ret[0].(AuditRepository)
+	return ret0
+}
+
+// Audits indicates an expected call of Audits.
+func (mr *MockStoreMockRecorder) Audits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audits", reflect.TypeOf((*MockStore)(nil).Audits))
+}
+
+// Outbox mocks base method.
+func (m *MockStore) Outbox() OutboxRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Outbox")
+	ret0, _ := ret[0].(OutboxRepository)
+	return ret0
+}
+
+// Outbox indicates an expected call of Outbox.
+func (mr *MockStoreMockRecorder) Outbox() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Outbox", reflect.TypeOf((*MockStore)(nil).Outbox))
+}
+
+// Transaction mocks base method.
+func (m *MockStore) Transaction(fn func(Store) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transaction", fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Transaction indicates an expected call of Transaction.
+func (mr *MockStoreMockRecorder) Transaction(fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transaction", reflect.TypeOf((*MockStore)(nil).Transaction), fn)
+}
+
+// Users mocks base method.
+func (m *MockStore) Users() UserRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Users")
+	ret0, _ := ret[0].(UserRepository)
+	return ret0
+}
+
+// Users indicates an expected call of Users.
+func (mr *MockStoreMockRecorder) Users() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Users", reflect.TypeOf((*MockStore)(nil).Users))
+}
+
+// Webhooks mocks base method.
+func (m *MockStore) Webhooks() WebhookRepository {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webhooks")
+	ret0, _ := ret[0].(WebhookRepository)
+	return ret0
+}
+
+// Webhooks indicates an expected call of Webhooks.
+func (mr *MockStoreMockRecorder) Webhooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webhooks", reflect.TypeOf((*MockStore)(nil).Webhooks))
+}
@@ -0,0 +1,47 @@
+// Package storetest has the contract tests of the store repositories, every
+// implementation and decorator of store.UserRepository in this module runs
+// them to prove it behaves like the gorm repository. The package is internal
+// like store, it is not meant for backends outside of this module.
+package storetest
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/store"
+)
+
+var sqliteSeq atomic.Int64
+
+// OpenSQLite opens a migrated in memory SQLite database for the test t, it is
+// closed when the test finishes.
+func OpenSQLite(t testing.TB) *gorm.DB {
+	t.Helper()
+	conf := &config.Config{
+		DBDriver: store.DriverSQLite,
+		DSN:      fmt.Sprintf("file:storetest_%d_%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", time.Now().UnixNano(), sqliteSeq.Add(1)),
+	}
+	db, err := store.Open(conf, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database failed: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get database failed: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := store.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("new migrator failed: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,412 @@
+package storetest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/store"
+)
+
+// UserRepositorySuite is the behavior every store.UserRepository must have:
+//
+//	suite.Run(t, &storetest.UserRepositorySuite{
+//		NewRepo: func(t *testing.T) store.UserRepository {
+//			return store.NewUserRepository(storetest.OpenSQLite(t))
+//		},
+//	})
+type UserRepositorySuite struct {
+	suite.Suite
+
+	// NewRepo returns an empty repository for each test.
+	NewRepo func(t *testing.T) store.UserRepository
+	// Repo is the repository of the current test.
+	Repo store.UserRepository
+}
+
+func (s *UserRepositorySuite) SetupTest() {
+	s.Repo = s.NewRepo(s.T())
+}
+
+func (s *UserRepositorySuite) create(users ...store.User) []store.User {
+	for i := range users {
+		if users[i].ID == "" {
+			users[i].ID = fmt.Sprintf("user-%02d", i+1)
+		}
+		s.Require().NoError(s.Repo.Create(&users[i]))
+	}
+	return users
+}
+
+func (s *UserRepositorySuite) TestCreateGet() {
+	start := time.Now().Add(-time.Second)
+	users := s.create(store.User{Name: "liuliu", Email: "aa@bb.com", Age: 18})
+	s.False(users[0].CreatedAt.Before(start))
+	s.True(users[0].UpdatedAt.Equal(users[0].CreatedAt))
+
+	user, err := s.Repo.GetByID("user-01")
+	s.Require().NoError(err)
+	s.Equal("liuliu", user.Name)
+	s.Equal("aa@bb.com", user.Email)
+	s.Equal(18, user.Age)
+	s.WithinDuration(users[0].CreatedAt, user.CreatedAt, time.Second)
+
+	user, err = s.Repo.GetByEmail("aa@bb.com")
+	s.Require().NoError(err)
+	s.Equal("user-01", user.ID)
+}
+
+func (s *UserRepositorySuite) TestCreateDuplicate() {
+	s.create(store.User{Name: "liuliu", Email: "aa@bb.com"})
+	s.Error(s.Repo.Create(&store.User{ID: "user-02", Name: "zhangsan", Email: "aa@bb.com"}))
+	s.Error(s.Repo.Create(&store.User{ID: "user-01", Name: "zhangsan", Email: "cc@dd.com"}))
+
+	// either all or none of a batch are created
+	err := s.Repo.CreateBatch([]store.User{
+		{ID: "user-03", Name: "lisi", Email: "ee@ff.com"},
+		{ID: "user-04", Name: "wangwu", Email: "aa@bb.com"},
+	})
+	s.Error(err)
+	_, err = s.Repo.GetByID("user-03")
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	s.Error(s.Repo.CreateBatch([]store.User{
+		{ID: "user-05", Name: "lisi", Email: "gg@hh.com"},
+		{ID: "user-06", Name: "wangwu", Email: "gg@hh.com"},
+	}))
+	_, err = s.Repo.GetByEmail("gg@hh.com")
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	_, total, err := s.Repo.List(1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(1, total)
+}
+
+func (s *UserRepositorySuite) TestNotFound() {
+	_, err := s.Repo.GetByID("user-01")
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	_, err = s.Repo.GetByEmail("aa@bb.com")
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	users, err := s.Repo.GetByIDs([]string{"user-01"})
+	s.Require().NoError(err)
+	s.Empty(users)
+	users, err = s.Repo.GetByIDs(nil)
+	s.Require().NoError(err)
+	s.Empty(users)
+	s.NoError(s.Repo.DeleteByID("user-01"))
+}
+
+func (s *UserRepositorySuite) TestSoftDelete() {
+	s.create(
+		store.User{Name: "liuliu", Email: "aa@bb.com"},
+		store.User{Name: "zhangsan", Email: "cc@dd.com"},
+	)
+	s.Require().NoError(s.Repo.DeleteByID("user-01"))
+
+	_, err := s.Repo.GetByID("user-01")
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	_, err = s.Repo.GetByEmail("aa@bb.com")
+	s.ErrorIs(err, gorm.ErrRecordNotFound)
+	users, err := s.Repo.GetByIDs([]string{"user-01", "user-02"})
+	s.Require().NoError(err)
+	s.Len(users, 1)
+	users, err = s.Repo.GetByEmails([]string{"aa@bb.com", "cc@dd.com"})
+	s.Require().NoError(err)
+	s.Len(users, 1)
+	users, total, err := s.Repo.List(1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(1, total)
+	s.Len(users, 1)
+	count, err := s.Repo.Count(store.UserFilter{Name: "liuliu"})
+	s.Require().NoError(err)
+	s.EqualValues(0, count)
+
+	// the email of a deleted user stays taken
+	s.Error(s.Repo.Create(&store.User{ID: "user-03", Name: "lisi", Email: "aa@bb.com"}))
+}
+
+func (s *UserRepositorySuite) TestUpdate() {
+	users := s.create(
+		store.User{Name: "liuliu", Email: "aa@bb.com", Age: 18},
+		store.User{Name: "zhangsan", Email: "cc@dd.com"},
+	)
+	time.Sleep(10 * time.Millisecond)
+
+	user := users[0]
+	user.Name = "liuliu2"
+	user.Age = 19
+	s.Require().NoError(s.Repo.Update(&user))
+	got, err := s.Repo.GetByID("user-01")
+	s.Require().NoError(err)
+	s.Equal("liuliu2", got.Name)
+	s.Equal(19, got.Age)
+	s.WithinDuration(users[0].CreatedAt, got.CreatedAt, time.Second)
+	s.True(got.UpdatedAt.After(users[0].UpdatedAt))
+
+	user.Email = "cc@dd.com"
+	s.Error(s.Repo.Update(&user))
+	got, err = s.Repo.GetByID("user-01")
+	s.Require().NoError(err)
+	s.Equal("aa@bb.com", got.Email)
+}
+
+func (s *UserRepositorySuite) TestList() {
+	var users []store.User
+	for i := 0; i < 5; i++ {
+		users = append(users, store.User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@bb.com", i)})
+	}
+	s.create(users...)
+
+	// the pages are disjoint and in creation order
+	var listed []string
+	for page := 1; page <= 3; page++ {
+		got, total, err := s.Repo.List(page, 2)
+		s.Require().NoError(err)
+		s.EqualValues(5, total)
+		for _, u := range got {
+			listed = append(listed, u.ID)
+		}
+	}
+	s.Equal([]string{"user-01", "user-02", "user-03", "user-04", "user-05"}, listed)
+
+	got, total, err := s.Repo.List(4, 2)
+	s.Require().NoError(err)
+	s.EqualValues(5, total)
+	s.Empty(got)
+}
+
+func (s *UserRepositorySuite) TestIterate() {
+	var users []store.User
+	for i := 5; i > 0; i-- {
+		users = append(users, store.User{ID: fmt.Sprintf("user-%02d", i), Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@bb.com", i)})
+	}
+	s.create(users...)
+
+	var batches [][]string
+	err := s.Repo.Iterate(store.UserFilter{}, 2, func(users []store.User) error {
+		var ids []string
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		batches = append(batches, ids)
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([][]string{{"user-01", "user-02"}, {"user-03", "user-04"}, {"user-05"}}, batches)
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = s.Repo.Iterate(store.UserFilter{IDs: []string{"user-02", "user-04", "user-05"}}, 1, func(users []store.User) error {
+		calls++
+		return errStop
+	})
+	s.ErrorIs(err, errStop)
+	s.Equal(1, calls)
+
+	for _, batchSize := range []int{0, -1} {
+		err = s.Repo.Iterate(store.UserFilter{}, batchSize, func(users []store.User) error {
+			s.Fail("fn called with batch size %d", batchSize)
+			return nil
+		})
+		s.EqualError(err, fmt.Sprintf("batch size %d not positive", batchSize))
+	}
+}
+
+func (s *UserRepositorySuite) TestNameFilter() {
+	s.create(
+		store.User{Name: "liuliu", Email: "aa@bb.com"},
+		store.User{Name: "100% liu_liu!", Email: "cc@dd.com"},
+	)
+	for _, tc := range []struct {
+		name    string
+		matched int64
+	}{
+		{name: "LIU", matched: 2},
+		{name: "%", matched: 1},
+		{name: "_", matched: 1},
+		{name: "u_l", matched: 1},
+		{name: "liu%", matched: 0},
+		{name: "!", matched: 1},
+	} {
+		n, err := s.Repo.Count(store.UserFilter{Name: tc.name})
+		s.Require().NoError(err)
+		s.Equal(tc.matched, n, "name %q", tc.name)
+	}
+}
+
+func (s *UserRepositorySuite) TestBatch() {
+	s.create(
+		store.User{Name: "liuliu", Email: "aa@bb.com", Age: 18},
+		store.User{Name: "liuliu2", Email: "cc@dd.com", Age: 20},
+		store.User{Name: "zhangsan", Email: "ee@ff.com"},
+	)
+
+	_, err := s.Repo.DeleteBatch(store.UserFilter{}, 10)
+	s.ErrorIs(err, store.ErrEmptyFilter)
+	_, err = s.Repo.UpdateBatch(store.UserFilter{Name: "liuliu"}, store.UserFields{}, 10)
+	s.Error(err)
+
+	_, err = s.Repo.DeleteBatch(store.UserFilter{Name: "liuliu"}, 1)
+	var limitErr *store.BatchLimitError
+	s.Require().ErrorAs(err, &limitErr)
+	s.Equal(&store.BatchLimitError{Matched: 2, Limit: 1}, limitErr)
+
+	age := 30
+	updated, err := s.Repo.UpdateBatch(store.UserFilter{Name: "liuliu"}, store.UserFields{Age: &age}, 2)
+	s.Require().NoError(err)
+	s.Len(updated, 2)
+	s.Equal(18, updated[0].Age)
+	got, err := s.Repo.GetByID("user-02")
+	s.Require().NoError(err)
+	s.Equal(30, got.Age)
+	s.Equal("liuliu2", got.Name)
+
+	deleted, err := s.Repo.DeleteBatch(store.UserFilter{IDs: []string{"user-01", "user-03"}}, 2)
+	s.Require().NoError(err)
+	s.Len(deleted, 2)
+	count, err := s.Repo.Count(store.UserFilter{IDs: []string{"user-01", "user-02", "user-03"}})
+	s.Require().NoError(err)
+	s.EqualValues(1, count)
+}
+
+// TestListPaging checks the edges of List, which pages like Offset and Limit:
+// pages below 1 are the first page, a page size of 0 returns no users and a
+// negative page size returns all of them.
+func (s *UserRepositorySuite) TestListPaging() {
+	users, total, err := s.Repo.List(1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(0, total)
+	s.Empty(users)
+
+	s.create(
+		store.User{Name: "liuliu", Email: "aa@bb.com"},
+		store.User{Name: "zhangsan", Email: "cc@dd.com"},
+		store.User{Name: "lisi", Email: "ee@ff.com"},
+	)
+	for _, tc := range []struct {
+		page, pageSize int
+		ids            []string
+	}{
+		{page: 1, pageSize: 3, ids: []string{"user-01", "user-02", "user-03"}},
+		{page: 1, pageSize: 10, ids: []string{"user-01", "user-02", "user-03"}},
+		{page: 2, pageSize: 2, ids: []string{"user-03"}},
+		{page: 3, pageSize: 1, ids: []string{"user-03"}},
+		{page: 2, pageSize: 3, ids: nil},
+		{page: 0, pageSize: 2, ids: []string{"user-01", "user-02"}},
+		{page: -1, pageSize: 2, ids: []string{"user-01", "user-02"}},
+		{page: 1, pageSize: 0, ids: nil},
+		{page: 1, pageSize: -1, ids: []string{"user-01", "user-02", "user-03"}},
+	} {
+		users, total, err := s.Repo.List(tc.page, tc.pageSize)
+		s.Require().NoError(err)
+		s.EqualValues(3, total)
+		var ids []string
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		s.Equal(tc.ids, ids, "page %d size %d", tc.page, tc.pageSize)
+	}
+}
+
+// TestConcurrentUpdates updates users from many goroutines, every update must
+// succeed and the users end up with the last update of each.
+func (s *UserRepositorySuite) TestConcurrentUpdates() {
+	const n = 10
+	var users []store.User
+	for i := 0; i < n; i++ {
+		users = append(users, store.User{Name: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@bb.com", i)})
+	}
+	users = s.create(users...)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n*n)
+	for i := range users {
+		for age := 1; age <= n; age++ {
+			wg.Add(1)
+			go func(u store.User, age int) {
+				defer wg.Done()
+				u.Age = age
+				errs <- s.Repo.Update(&u)
+			}(users[i], age)
+		}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := s.Repo.GetByID(id)
+			errs <- err
+		}(users[i].ID)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		s.NoError(err)
+	}
+
+	for _, u := range users {
+		got, err := s.Repo.GetByID(u.ID)
+		s.Require().NoError(err)
+		s.Equal(u.Name, got.Name)
+		s.Equal(u.Email, got.Email)
+		s.True(got.Age >= 1 && got.Age <= n, "age %d", got.Age)
+	}
+	count, err := s.Repo.Count(store.UserFilter{Name: "user"})
+	s.Require().NoError(err)
+	s.EqualValues(n, count)
+}
+
+func (s *UserRepositorySuite) TestTimestamps() {
+	created := time.Date(2025, 7, 20, 16, 13, 21, 0, time.UTC)
+	users := s.create(
+		store.User{Name: "liuliu", Email: "aa@bb.com", CreatedAt: created, UpdatedAt: created},
+		store.User{Name: "zhangsan", Email: "cc@dd.com"},
+	)
+
+	// set timestamps are kept
+	got, err := s.Repo.GetByID("user-01")
+	s.Require().NoError(err)
+	s.True(got.CreatedAt.Equal(created), "created at %v", got.CreatedAt)
+	s.True(got.UpdatedAt.Equal(created), "updated at %v", got.UpdatedAt)
+	s.False(got.DeletedAt.Valid)
+
+	count, err := s.Repo.Count(store.UserFilter{CreatedBefore: created.Add(time.Second)})
+	s.Require().NoError(err)
+	s.EqualValues(1, count)
+	count, err = s.Repo.Count(store.UserFilter{CreatedAfter: created.Add(time.Second)})
+	s.Require().NoError(err)
+	s.EqualValues(1, count)
+	count, err = s.Repo.Count(store.UserFilter{CreatedAfter: created})
+	s.Require().NoError(err)
+	s.EqualValues(2, count)
+
+	// Update keeps the creation time and moves the update time
+	start := time.Now().Add(-time.Second)
+	got.Age = 18
+	s.Require().NoError(s.Repo.Update(got))
+	got, err = s.Repo.GetByID("user-01")
+	s.Require().NoError(err)
+	s.True(got.CreatedAt.Equal(created), "created at %v", got.CreatedAt)
+	s.False(got.UpdatedAt.Before(start), "updated at %v", got.UpdatedAt)
+
+	// so does UpdateBatch, which returns the users as they were before
+	time.Sleep(10 * time.Millisecond)
+	age := 20
+	updated, err := s.Repo.UpdateBatch(store.UserFilter{IDs: []string{"user-02"}}, store.UserFields{Age: &age}, 1)
+	s.Require().NoError(err)
+	s.Require().Len(updated, 1)
+	s.WithinDuration(users[1].UpdatedAt, updated[0].UpdatedAt, time.Millisecond)
+	got, err = s.Repo.GetByID("user-02")
+	s.Require().NoError(err)
+	s.WithinDuration(users[1].CreatedAt, got.CreatedAt, time.Millisecond)
+	s.True(got.UpdatedAt.After(users[1].UpdatedAt), "updated at %v", got.UpdatedAt)
+
+	// DeleteBatch returns the users before they were deleted
+	deleted, err := s.Repo.DeleteBatch(store.UserFilter{IDs: []string{"user-01", "user-02"}}, 2)
+	s.Require().NoError(err)
+	s.Require().Len(deleted, 2)
+	for _, u := range deleted {
+		s.False(u.DeletedAt.Valid, "user %s", u.ID)
+	}
+}
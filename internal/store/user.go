@@ -1,29 +1,144 @@
 package store
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 //go:generate mockgen -source=user.go -destination=user_mock.go -package=store
 type UserRepository interface {
 	Create(user *User) error
+	CreateBatch(users []User) error
 	GetByID(id string) (*User, error)
 	GetByEmail(email string) (*User, error)
+	// GetByIDs returns the users with the ids in one query, ids not found are
+	// left out, the order of the users is not defined.
+	GetByIDs(ids []string) ([]User, error)
+	// GetByEmails is GetByIDs by emails.
+	GetByEmails(emails []string) ([]User, error)
 	Update(user *User) error
 	DeleteByID(id string) error
 	List(page, pageSize int) ([]User, int64, error)
+	// Iterate calls fn with batches of at most batchSize users matching filter
+	// ordered by id, until all users are visited or fn returns an error. The
+	// batchSize must be positive.
+	Iterate(filter UserFilter, batchSize int, fn func(users []User) error) error
+	// Count returns the number of users matching filter.
+	Count(filter UserFilter) (int64, error)
+	// DeleteBatch deletes the users matching filter in a transaction and
+	// returns them as they were before the delete. If more than limit users
+	// match, nothing is deleted and a *BatchLimitError is returned.
+	DeleteBatch(filter UserFilter, limit int) ([]User, error)
+	// UpdateBatch sets fields of the users matching filter, the same way as
+	// DeleteBatch.
+	UpdateBatch(filter UserFilter, fields UserFields, limit int) ([]User, error)
+}
+
+// ErrEmptyFilter is returned by the batch operations for a zero UserFilter,
+// which would match all users.
+var ErrEmptyFilter = errors.New("user filter not set")
+
+// BatchLimitError is returned when a batch operation matches more users than
+// its limit.
+type BatchLimitError struct {
+	Matched int64
+	Limit   int
+}
+
+func (e *BatchLimitError) Error() string {
+	return fmt.Sprintf("%d users matched, at most %d are allowed", e.Matched, e.Limit)
+}
+
+// UserFields are the fields set by UpdateBatch, nil fields are not changed.
+// The email is unique and can not be set in batch.
+type UserFields struct {
+	Name *string
+	Age  *int
+}
+
+// Apply sets the fields on u.
+func (f UserFields) Apply(u *User) {
+	if f.Name != nil {
+		u.Name = *f.Name
+	}
+	if f.Age != nil {
+		u.Age = *f.Age
+	}
+}
+
+func (f UserFields) updates() map[string]interface{} {
+	updates := make(map[string]interface{})
+	if f.Name != nil {
+		updates["name"] = *f.Name
+	}
+	if f.Age != nil {
+		updates["age"] = *f.Age
+	}
+	return updates
+}
+
+// UserFilter selects users, zero value fields match all users.
+type UserFilter struct {
+	IDs []string
+	// Name matches users whose name contains Name.
+	Name          string
+	Email         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// IsZero reports whether f matches all users.
+func (f UserFilter) IsZero() bool {
+	return len(f.IDs) == 0 && f.Name == "" && f.Email == "" && f.CreatedAfter.IsZero() && f.CreatedBefore.IsZero()
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with '!', which unlike a
+// backslash is not an escape of the string literals of any of the databases.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+func (f UserFilter) apply(db *gorm.DB) *gorm.DB {
+	if len(f.IDs) > 0 {
+		db = db.Where("id IN ?", f.IDs)
+	}
+	if f.Name != "" {
+		db = db.Where("name LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(f.Name)+"%")
+	}
+	if f.Email != "" {
+		db = db.Where("email = ?", f.Email)
+	}
+	if !f.CreatedAfter.IsZero() {
+		db = db.Where("created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", f.CreatedBefore)
+	}
+	return db
 }
 
 type userRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository(db *gorm.DB) UserRepository {
-	return &userRepository{db: db}
+func NewUserRepository(db *gorm.DB, opts ...Option) UserRepository {
+	return &userRepository{db: newOptions(opts).session(db)}
 }
 
 func (r *userRepository) Create(user *User) error {
 	return r.db.Create(user).Error
 }
 
+// CreateBatch creates users in one statement, either all or none are created.
+func (r *userRepository) CreateBatch(users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	return r.db.Create(&users).Error
+}
+
 func (r *userRepository) GetByID(id string) (*User, error) {
 	var user User
 	err := r.db.Where("id = ?", id).First(&user).Error
@@ -42,6 +157,28 @@ func (r *userRepository) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) GetByIDs(ids []string) ([]User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var users []User
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) GetByEmails(emails []string) ([]User, error) {
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	var users []User
+	if err := r.db.Where("email IN ?", emails).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (r *userRepository) Update(user *User) error {
 	return r.db.Save(user).Error
 }
@@ -64,4 +201,92 @@ func (r *userRepository) List(page, pageSize int) ([]User, int64, error) {
 	}
 
 	return users, total, nil
-}
\ No newline at end of file
+}
+
+// Iterate pages by the last id of the previous batch instead of offset, so
+// each batch is an index range scan and memory stays constant.
+func (r *userRepository) Iterate(filter UserFilter, batchSize int, fn func(users []User) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size %d not positive", batchSize)
+	}
+	lastID := ""
+	for {
+		var users []User
+		db := filter.apply(r.db.Model(&User{}))
+		if lastID != "" {
+			db = db.Where("id > ?", lastID)
+		}
+		if err := db.Order("id").Limit(batchSize).Find(&users).Error; err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		if err := fn(users); err != nil {
+			return err
+		}
+		if len(users) < batchSize {
+			return nil
+		}
+		lastID = users[len(users)-1].ID
+	}
+}
+
+func (r *userRepository) Count(filter UserFilter) (int64, error) {
+	var count int64
+	if err := filter.apply(r.db.Model(&User{})).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *userRepository) DeleteBatch(filter UserFilter, limit int) ([]User, error) {
+	return r.limitBatch(filter, limit, func(db *gorm.DB) *gorm.DB {
+		return db.Delete(&User{})
+	})
+}
+
+func (r *userRepository) UpdateBatch(filter UserFilter, fields UserFields, limit int) ([]User, error) {
+	updates := fields.updates()
+	if len(updates) == 0 {
+		return nil, errors.New("user fields not set")
+	}
+	return r.limitBatch(filter, limit, func(db *gorm.DB) *gorm.DB {
+		return db.Updates(updates)
+	})
+}
+
+// limitBatch runs op in a transaction on the users matching filter and returns
+// the users as they were before op. At most limit+1 users are loaded to check
+// the limit, and op only changes the loaded users, so users created
+// concurrently are not changed beyond the limit.
+func (r *userRepository) limitBatch(filter UserFilter, limit int, op func(db *gorm.DB) *gorm.DB) ([]User, error) {
+	if filter.IsZero() {
+		return nil, ErrEmptyFilter
+	}
+	var users []User
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := filter.apply(tx).Order("id").Limit(limit + 1).Find(&users).Error; err != nil {
+			return err
+		}
+		if len(users) > limit {
+			var matched int64
+			if err := filter.apply(tx.Model(&User{})).Count(&matched).Error; err != nil {
+				return err
+			}
+			return &BatchLimitError{Matched: matched, Limit: limit}
+		}
+		if len(users) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.ID)
+		}
+		return op(tx.Model(&User{}).Where("id IN ?", ids)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
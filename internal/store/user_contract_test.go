@@ -0,0 +1,70 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"go-unittest-best-practice/internal/clock"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/internal/store/storetest"
+)
+
+func TestMemoryUserRepository(t *testing.T) {
+	suite.Run(t, &storetest.UserRepositorySuite{
+		NewRepo: func(t *testing.T) store.UserRepository {
+			return store.NewMemoryUserRepository()
+		},
+	})
+}
+
+func TestGormUserRepository(t *testing.T) {
+	suite.Run(t, &storetest.UserRepositorySuite{
+		NewRepo: func(t *testing.T) store.UserRepository {
+			return store.NewUserRepository(storetest.OpenSQLite(t))
+		},
+	})
+}
+
+func TestUserRepositoryClock(t *testing.T) {
+	for name, newRepo := range map[string]func(t *testing.T, opts ...store.Option) store.UserRepository{
+		"memory": func(t *testing.T, opts ...store.Option) store.UserRepository {
+			return store.NewMemoryUserRepository(opts...)
+		},
+		"gorm": func(t *testing.T, opts ...store.Option) store.UserRepository {
+			return store.NewUserRepository(storetest.OpenSQLite(t), opts...)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			start := time.Unix(1752999201, 0)
+			c := clock.NewFake(start)
+			repo := newRepo(t, store.WithClock(c))
+
+			user := &store.User{ID: "user-1", Name: "liuliu", Email: "aa@bb.com"}
+			require.NoError(t, repo.Create(user))
+			got, err := repo.GetByID("user-1")
+			require.NoError(t, err)
+			assert.True(t, got.CreatedAt.Equal(start), "created at %v", got.CreatedAt)
+			assert.True(t, got.UpdatedAt.Equal(start), "updated at %v", got.UpdatedAt)
+
+			c.Advance(time.Hour)
+			got.Age = 18
+			require.NoError(t, repo.Update(got))
+			got, err = repo.GetByID("user-1")
+			require.NoError(t, err)
+			assert.True(t, got.CreatedAt.Equal(start), "created at %v", got.CreatedAt)
+			assert.True(t, got.UpdatedAt.Equal(start.Add(time.Hour)), "updated at %v", got.UpdatedAt)
+
+			c.Advance(time.Hour)
+			age := 20
+			_, err = repo.UpdateBatch(store.UserFilter{IDs: []string{"user-1"}}, store.UserFields{Age: &age}, 1)
+			require.NoError(t, err)
+			got, err = repo.GetByID("user-1")
+			require.NoError(t, err)
+			assert.True(t, got.UpdatedAt.Equal(start.Add(2*time.Hour)), "updated at %v", got.UpdatedAt)
+		})
+	}
+}
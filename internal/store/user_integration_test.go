@@ -7,29 +7,62 @@ import (
 	"testing"
 	"time"
 
-	// _ "github.com/glebarez/go-sqlite"
-	// "gorm.io/driver/sqlite"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/config"
 )
 
+// UserTestSuite runs against an in-memory SQLite database by default, set
+// TEST_DBDRIVER to mysql or postgres and TEST_DBHOST to run against a real
+// database server.
 type UserTestSuite struct {
 	suite.Suite
 
 	dbname   string
+	conf     *config.Config
 	db       *gorm.DB
 	userRepo UserRepository
 }
 
 func (s *UserTestSuite) SetupSuite() {
+	s.dbname = fmt.Sprintf("testuser_%d", time.Now().UnixNano())
+	driver := os.Getenv("TEST_DBDRIVER")
+	if driver == "" || driver == DriverSQLite {
+		s.conf = &config.Config{
+			DBDriver: DriverSQLite,
+			DSN:      fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=busy_timeout(5000)", s.dbname),
+		}
+	} else {
+		s.conf = s.serverConfig(driver)
+	}
+
+	db, err := Open(s.conf, &gorm.Config{})
+	if err != nil {
+		s.T().Skipf("skip test: open database failed: %v", err)
+	}
+	s.db = db
+	s.userRepo = NewUserRepository(db)
+
+	migrator, err := NewMigrator(db)
+	s.Require().NoError(err)
+	_, err = migrator.Up()
+	s.Require().NoError(err)
+}
+
+// serverConfig creates the test database on the database server and returns
+// the config to connect to it.
+func (s *UserTestSuite) serverConfig(driver string) *config.Config {
 	dbhost := os.Getenv("TEST_DBHOST")
 	if dbhost == "" {
 		s.T().Skip("skip test: env TEST_DBHOST not set")
 	}
 	dbportStr := os.Getenv("TEST_DBPORT")
 	dbport := 3306
+	if driver == DriverPostgres {
+		dbport = 5432
+	}
 	if dbportStr != "" {
 		var err error
 		dbport, err = strconv.Atoi(dbportStr)
@@ -37,12 +70,22 @@ func (s *UserTestSuite) SetupSuite() {
 			s.T().Skipf("skip test: parse dbport from ENV failed: %s", dbportStr)
 		}
 	}
-	dbuser := os.Getenv("TEST_DBUSER")
-	dbpassword := os.Getenv("TEST_DBPASSWORD")
-	s.dbname = fmt.Sprintf("testuser_%d", time.Now().UnixNano())
+	conf := &config.Config{
+		DBDriver:   driver,
+		DBHost:     dbhost,
+		DBPort:     dbport,
+		DBUser:     os.Getenv("TEST_DBUSER"),
+		DBPassword: os.Getenv("TEST_DBPASSWORD"),
+		DBName:     "mysql",
+		DBCharset:  "utf8mb4",
+		DBLoc:      "Local",
+	}
+	if driver == DriverPostgres {
+		conf.DBName = "postgres"
+	}
+
 	// create test database
-	db, err := gorm.Open(mysql.Open(fmt.Sprintf("%s:%s@tcp(%s:%d)/mysql?charset=utf8mb4&parseTime=true&loc=Local",
-		dbuser, dbpassword, dbhost, dbport)), &gorm.Config{})
+	db, err := Open(conf, &gorm.Config{})
 	if err != nil {
 		s.T().Skipf("skip test: open database failed: %v", err)
 	}
@@ -50,24 +93,21 @@ func (s *UserTestSuite) SetupSuite() {
 	if err != nil {
 		s.T().Skipf("skip test: create database failed: %v", err)
 	}
-
-	db, err = gorm.Open(mysql.Open(fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&loc=Local",
-		dbuser, dbpassword, dbhost, dbport, s.dbname)), &gorm.Config{})
-	if err != nil {
-		s.T().Skipf("skip test: open database failed: %v", err)
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
 	}
 
-	// db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	// if err != nil {
-	// 	s.T().Skipf("skip test: open database failed: %v", err)
-	// }
-	s.db = db
-	s.userRepo = NewUserRepository(db)
-
-	s.db.AutoMigrate(&User{})
+	conf.DBName = s.dbname
+	return conf
 }
 
 func (s *UserTestSuite) TearDownSuite() {
+	if s.conf.DBDriver == DriverSQLite {
+		if sqlDB, err := s.db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		return
+	}
 	s.db.Exec(fmt.Sprintf("DROP DATABASE %s", s.dbname))
 }
 
@@ -119,6 +159,167 @@ func (s *UserTestSuite) TestUser() {
 	s.Require().ErrorContains(err, "not found")
 }
 
+func (s *UserTestSuite) TestCreateBatch() {
+	users := []User{
+		{ID: uuid.NewString(), Name: "batch1", Email: "batch1@bb.com"},
+		{ID: uuid.NewString(), Name: "batch2", Email: "batch2@bb.com"},
+	}
+	s.Require().NoError(s.userRepo.CreateBatch(users))
+	defer func() {
+		for _, u := range users {
+			s.db.Unscoped().Delete(&User{ID: u.ID})
+		}
+	}()
+	for _, u := range users {
+		user, err := s.userRepo.GetByID(u.ID)
+		s.Require().NoError(err)
+		s.Require().Equal(u.Email, user.Email)
+	}
+
+	// a duplicated email fails the whole batch
+	err := s.userRepo.CreateBatch([]User{
+		{ID: uuid.NewString(), Name: "batch3", Email: "batch3@bb.com"},
+		{ID: uuid.NewString(), Name: "batch4", Email: "batch1@bb.com"},
+	})
+	s.Require().Error(err)
+	_, err = s.userRepo.GetByEmail("batch3@bb.com")
+	s.Require().ErrorContains(err, "not found")
+}
+
+func (s *UserTestSuite) TestGetByIDsAndEmails() {
+	users := []User{
+		{ID: uuid.NewString(), Name: "batchget1", Email: "batchget1@bb.com"},
+		{ID: uuid.NewString(), Name: "batchget2", Email: "batchget2@bb.com"},
+	}
+	s.Require().NoError(s.userRepo.CreateBatch(users))
+	defer func() {
+		for _, u := range users {
+			s.db.Unscoped().Delete(&User{ID: u.ID})
+		}
+	}()
+
+	found, err := s.userRepo.GetByIDs([]string{users[0].ID, users[1].ID, uuid.NewString()})
+	s.Require().NoError(err)
+	s.Len(found, 2)
+
+	found, err = s.userRepo.GetByEmails([]string{"batchget2@bb.com", "missing@bb.com"})
+	s.Require().NoError(err)
+	s.Require().Len(found, 1)
+	s.Equal(users[1].ID, found[0].ID)
+
+	// deleted users are not found
+	s.Require().NoError(s.userRepo.DeleteByID(users[0].ID))
+	found, err = s.userRepo.GetByIDs([]string{users[0].ID})
+	s.Require().NoError(err)
+	s.Empty(found)
+}
+
+func (s *UserTestSuite) TestBatchUpdateAndDelete() {
+	var users []User
+	for i := 0; i < 3; i++ {
+		users = append(users, User{
+			ID:    uuid.NewString(),
+			Name:  fmt.Sprintf("batchop%d", i),
+			Email: fmt.Sprintf("batchop%d@bb.com", i),
+		})
+	}
+	s.Require().NoError(s.userRepo.CreateBatch(users))
+	defer func() {
+		for _, u := range users {
+			s.db.Unscoped().Delete(&User{ID: u.ID})
+		}
+	}()
+	filter := UserFilter{Name: "batchop"}
+
+	count, err := s.userRepo.Count(filter)
+	s.Require().NoError(err)
+	s.EqualValues(3, count)
+
+	// over limit, nothing is updated
+	age := 30
+	_, err = s.userRepo.UpdateBatch(filter, UserFields{Age: &age}, 2)
+	var limitErr *BatchLimitError
+	s.Require().ErrorAs(err, &limitErr)
+	user, err := s.userRepo.GetByID(users[0].ID)
+	s.Require().NoError(err)
+	s.Equal(0, user.Age)
+
+	updated, err := s.userRepo.UpdateBatch(filter, UserFields{Age: &age}, 3)
+	s.Require().NoError(err)
+	s.Len(updated, 3)
+	user, err = s.userRepo.GetByID(users[2].ID)
+	s.Require().NoError(err)
+	s.Equal(30, user.Age)
+	s.Equal("batchop2", user.Name)
+
+	_, err = s.userRepo.DeleteBatch(filter, 2)
+	s.Require().ErrorAs(err, &limitErr)
+	count, err = s.userRepo.Count(filter)
+	s.Require().NoError(err)
+	s.EqualValues(3, count)
+
+	deleted, err := s.userRepo.DeleteBatch(UserFilter{IDs: []string{users[0].ID, users[1].ID}}, 2)
+	s.Require().NoError(err)
+	s.Len(deleted, 2)
+	s.Equal(30, deleted[0].Age)
+	count, err = s.userRepo.Count(filter)
+	s.Require().NoError(err)
+	s.EqualValues(1, count)
+}
+
+func (s *UserTestSuite) TestIterate() {
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	var users []User
+	for i := 0; i < 5; i++ {
+		users = append(users, User{
+			ID:        uuid.NewString(),
+			Name:      fmt.Sprintf("iterate%d", i),
+			Email:     fmt.Sprintf("iterate%d@bb.com", i),
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	s.Require().NoError(s.userRepo.CreateBatch(users))
+	defer func() {
+		for _, u := range users {
+			s.db.Unscoped().Delete(&User{ID: u.ID})
+		}
+	}()
+
+	var batches []int
+	seen := make(map[string]bool)
+	err := s.userRepo.Iterate(UserFilter{Name: "iterate"}, 2, func(batch []User) error {
+		batches = append(batches, len(batch))
+		for _, u := range batch {
+			seen[u.ID] = true
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal([]int{2, 2, 1}, batches)
+	s.Len(seen, 5)
+
+	var emails []string
+	err = s.userRepo.Iterate(UserFilter{
+		Name:          "iterate",
+		CreatedAfter:  created.Add(time.Minute),
+		CreatedBefore: created.Add(3 * time.Minute),
+	}, 10, func(batch []User) error {
+		for _, u := range batch {
+			emails = append(emails, u.Email)
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"iterate1@bb.com", "iterate2@bb.com"}, emails)
+
+	err = s.userRepo.Iterate(UserFilter{Email: "iterate3@bb.com"}, 10, func(batch []User) error {
+		s.Require().Len(batch, 1)
+		s.Equal(users[3].ID, batch[0].ID)
+		return nil
+	})
+	s.Require().NoError(err)
+}
+
 func TestUserIntegration(t *testing.T) {
 	suite.Run(t, new(UserTestSuite))
 }
\ No newline at end of file
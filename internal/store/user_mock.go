@@ -19,6 +19,7 @@ import (
 type MockUserRepository struct {
 	ctrl     *gomock.Controller
 	recorder *MockUserRepositoryMockRecorder
+	isgomock struct{}
 }
 
 // MockUserRepositoryMockRecorder is the mock recorder for MockUserRepository.
@@ -38,6 +39,21 @@ func (m *MockUserRepository) EXPECT() *MockUserRepositoryMockRecorder {
 	return m.recorder
 }
 
+// Count mocks base method.
+func (m *MockUserRepository) Count(filter UserFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", filter)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockUserRepositoryMockRecorder) Count(filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockUserRepository)(nil).Count), filter)
+}
+
 // Create mocks base method.
 func (m *MockUserRepository) Create(user *User) error {
 	m.ctrl.T.Helper()
@@ -52,6 +68,35 @@ func (mr *MockUserRepositoryMockRecorder) Create(user any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserRepository)(nil).Create), user)
 }
 
+// CreateBatch mocks base method.
+func (m *MockUserRepository) CreateBatch(users []User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBatch", users)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBatch indicates an expected call of CreateBatch.
+func (mr *MockUserRepositoryMockRecorder) CreateBatch(users any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBatch", reflect.TypeOf((*MockUserRepository)(nil).CreateBatch), users)
+}
+
+// DeleteBatch mocks base method.
+func (m *MockUserRepository) DeleteBatch(filter UserFilter, limit int) ([]User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBatch", filter, limit)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteBatch indicates an expected call of DeleteBatch.
+func (mr *MockUserRepositoryMockRecorder) DeleteBatch(filter, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBatch", reflect.TypeOf((*MockUserRepository)(nil).DeleteBatch), filter, limit)
+}
+
 // DeleteByID mocks base method.
 func (m *MockUserRepository) DeleteByID(id string) error {
 	m.ctrl.T.Helper()
@@ -81,6 +126,21 @@ func (mr *MockUserRepositoryMockRecorder) GetByEmail(email any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmail", reflect.TypeOf((*MockUserRepository)(nil).GetByEmail), email)
 }
 
+// GetByEmails mocks base method.
+func (m *MockUserRepository) GetByEmails(emails []string) ([]User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByEmails", emails)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByEmails indicates an expected call of GetByEmails.
+func (mr *MockUserRepositoryMockRecorder) GetByEmails(emails any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByEmails", reflect.TypeOf((*MockUserRepository)(nil).GetByEmails), emails)
+}
+
 // GetByID mocks base method.
 func (m *MockUserRepository) GetByID(id string) (*User, error) {
 	m.ctrl.T.Helper()
@@ -96,6 +156,35 @@ func (mr *MockUserRepositoryMockRecorder) GetByID(id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByID", reflect.TypeOf((*MockUserRepository)(nil).GetByID), id)
 }
 
+// GetByIDs mocks base method.
+func (m *MockUserRepository) GetByIDs(ids []string) ([]User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByIDs", ids)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByIDs indicates an expected call of GetByIDs.
+func (mr *MockUserRepositoryMockRecorder) GetByIDs(ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIDs", reflect.TypeOf((*MockUserRepository)(nil).GetByIDs), ids)
+}
+
+// Iterate mocks base method.
+func (m *MockUserRepository) Iterate(filter UserFilter, batchSize int, fn func([]User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Iterate", filter, batchSize, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Iterate indicates an expected call of Iterate.
+func (mr *MockUserRepositoryMockRecorder) Iterate(filter, batchSize, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Iterate", reflect.TypeOf((*MockUserRepository)(nil).Iterate), filter, batchSize, fn)
+}
+
 // List mocks base method.
 func (m *MockUserRepository) List(page, pageSize int) ([]User, int64, error) {
 	m.ctrl.T.Helper()
@@ -125,3 +214,18 @@ func (mr *MockUserRepositoryMockRecorder) Update(user any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserRepository)(nil).Update), user)
 }
+
+// UpdateBatch mocks base method.
+func (m *MockUserRepository) UpdateBatch(filter UserFilter, fields UserFields, limit int) ([]User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBatch", filter, fields, limit)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateBatch indicates an expected call of UpdateBatch.
+func (mr *MockUserRepositoryMockRecorder) UpdateBatch(filter, fields, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBatch", reflect.TypeOf((*MockUserRepository)(nil).UpdateBatch), filter, fields, limit)
+}
@@ -68,6 +68,19 @@ func TestUserWithMock(t *testing.T) {
 		assert.NoError(t, err)
 	})
 
+	// CreateBatch
+	t.Run("CreateBatch", func(t *testing.T) {
+		users := []User{
+			{ID: uuid.NewString(), Name: "liuhong", Email: "aaa@bb.com"},
+			{ID: uuid.NewString(), Name: "liuliu", Email: "bbb@bb.com"},
+		}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectExec("INSERT INTO `users`").WillReturnResult(sqlmock.NewResult(2, 2))
+		sqlMock.ExpectCommit()
+		err := testUserRepo.CreateBatch(users)
+		assert.NoError(t, err)
+	})
+
 	// GetByID
 	t.Run("GetByID", func(t *testing.T) {
 		rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "age", "created_at", "updated_at", "deleted_at"}).
@@ -92,6 +105,37 @@ func TestUserWithMock(t *testing.T) {
 		}
 	})
 
+	// GetByIDs
+	t.Run("GetByIDs", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "age", "created_at", "updated_at", "deleted_at"}).
+			AddRow("id1", "liuliu", "aa@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{})
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN \\(\\?,\\?\\)").
+			WithArgs("id1", "id2").
+			WillReturnRows(rows)
+		users, err := testUserRepo.GetByIDs([]string{"id1", "id2"})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "id1", users[0].ID)
+
+		// no query without ids
+		users, err = testUserRepo.GetByIDs(nil)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+	})
+
+	// GetByEmails
+	t.Run("GetByEmails", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "name", "email", "password", "age", "created_at", "updated_at", "deleted_at"}).
+			AddRow("id1", "liuliu", "aa@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{})
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE email IN \\(\\?\\)").
+			WithArgs("aa@bb.com").
+			WillReturnRows(rows)
+		users, err := testUserRepo.GetByEmails([]string{"aa@bb.com"})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "aa@bb.com", users[0].Email)
+	})
+
 	// Update
 	t.Run("Update", func(t *testing.T) {
 		u := &User{
@@ -127,4 +171,81 @@ func TestUserWithMock(t *testing.T) {
 		err := testUserRepo.DeleteByID("idddddddddd")
 		assert.NoError(t, err)
 	})
+
+	// Iterate
+	t.Run("Iterate", func(t *testing.T) {
+		columns := []string{"id", "name", "email", "password", "age", "created_at", "updated_at", "deleted_at"}
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE name LIKE").
+			WithArgs("%liu%", 2).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("id1", "liuliu", "aa@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}).
+				AddRow("id2", "liuhong", "bb@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}))
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE name LIKE .* AND id >").
+			WithArgs("%liu%", "id2", 2).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("id3", "liuliu3", "cc@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}))
+		var ids []string
+		err := testUserRepo.Iterate(UserFilter{Name: "liu"}, 2, func(users []User) error {
+			for _, u := range users {
+				ids = append(ids, u.ID)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id1", "id2", "id3"}, ids)
+	})
+
+	// DeleteBatch
+	t.Run("DeleteBatch", func(t *testing.T) {
+		columns := []string{"id", "name", "email", "password", "age", "created_at", "updated_at", "deleted_at"}
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE id IN .* ORDER BY id LIMIT").
+			WithArgs("id1", "id2", 11).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("id1", "liuliu", "aa@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}).
+				AddRow("id2", "liuhong", "bb@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}))
+		sqlMock.ExpectExec("UPDATE `users` SET `deleted_at`=.* WHERE id IN").WillReturnResult(sqlmock.NewResult(0, 2))
+		sqlMock.ExpectCommit()
+		users, err := testUserRepo.DeleteBatch(UserFilter{IDs: []string{"id1", "id2"}}, 10)
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, "liuhong", users[1].Name)
+
+		// over limit, counted and rolled back before deleting
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE name LIKE").
+			WithArgs("%liu%", 2).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("id1", "liuliu", "aa@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}).
+				AddRow("id2", "liuhong", "bb@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}))
+		sqlMock.ExpectQuery("SELECT count\\(\\*\\) FROM `users` WHERE name LIKE").WillReturnRows(sqlmock.NewRows([]string{"count(*)"}).AddRow(3))
+		sqlMock.ExpectRollback()
+		_, err = testUserRepo.DeleteBatch(UserFilter{Name: "liu"}, 1)
+		var limitErr *BatchLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.EqualValues(t, 3, limitErr.Matched)
+
+		_, err = testUserRepo.DeleteBatch(UserFilter{}, 2)
+		assert.ErrorIs(t, err, ErrEmptyFilter)
+	})
+
+	// UpdateBatch
+	t.Run("UpdateBatch", func(t *testing.T) {
+		name := "liuliu2"
+		sqlMock.ExpectBegin()
+		sqlMock.ExpectQuery("SELECT \\* FROM `users` WHERE email =").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "password", "age", "created_at", "updated_at", "deleted_at"}).
+				AddRow("id1", "liuliu", "aa@bb.com", "", 10, time.Now(), time.Now(), sql.NullTime{}))
+		sqlMock.ExpectExec("UPDATE `users` SET `name`=.*,`updated_at`=.* WHERE id IN").
+			WithArgs(name, sqlmock.AnyArg(), "id1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		sqlMock.ExpectCommit()
+		users, err := testUserRepo.UpdateBatch(UserFilter{Email: "aa@bb.com"}, UserFields{Name: &name}, 10)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		assert.Equal(t, "liuliu", users[0].Name, "the users are returned as before the update")
+
+		_, err = testUserRepo.UpdateBatch(UserFilter{Email: "aa@bb.com"}, UserFields{}, 10)
+		assert.EqualError(t, err, "user fields not set")
+	})
 }
\ No newline at end of file
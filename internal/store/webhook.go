@@ -0,0 +1,196 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	// DeliveryStatusFailed means the delivery gave up retrying, it is only
+	// retried again when it is redelivered.
+	DeliveryStatusFailed = "failed"
+)
+
+//go:generate mockgen -source=webhook.go -destination=webhook_mock.go -package=store
+type WebhookRepository interface {
+	Create(webhook *Webhook) error
+	Get(id string) (*Webhook, error)
+	List() ([]Webhook, error)
+	// Delete deletes the webhook and its deliveries.
+	Delete(id string) error
+	// Subscribed returns the webhooks subscribed to the event type.
+	Subscribed(eventType string) ([]Webhook, error)
+
+	// CreateDeliveries creates the deliveries, a delivery of an event to a
+	// webhook which exists already is ignored, and so is a delivery to a
+	// webhook which does not exist.
+	CreateDeliveries(deliveries []WebhookDelivery) error
+	GetDelivery(id int64) (*WebhookDelivery, error)
+	// ListDeliveries lists the deliveries, the newest first.
+	ListDeliveries(filter DeliveryFilter, page, pageSize int) ([]WebhookDelivery, int64, error)
+	// PendingDeliveries returns at most limit pending deliveries due at now in
+	// id order.
+	PendingDeliveries(now time.Time, limit int) ([]WebhookDelivery, error)
+	UpdateDelivery(delivery *WebhookDelivery) error
+}
+
+// Subscribes reports whether the webhook receives the events of eventType.
+func (w *Webhook) Subscribes(eventType string) bool {
+	if w.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(w.Events, ",") {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryFilter struct {
+	WebhookID string
+	EventID   int64
+	Status    string
+}
+
+func (f DeliveryFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.WebhookID != "" {
+		db = db.Where("webhook_id = ?", f.WebhookID)
+	}
+	if f.EventID != 0 {
+		db = db.Where("event_id = ?", f.EventID)
+	}
+	if f.Status != "" {
+		db = db.Where("status = ?", f.Status)
+	}
+	return db
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB, opts ...Option) WebhookRepository {
+	return &webhookRepository{db: newOptions(opts).session(db)}
+}
+
+func (r *webhookRepository) Create(webhook *Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *webhookRepository) Get(id string) (*Webhook, error) {
+	var webhook Webhook
+	if err := r.db.Where("id = ?", id).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *webhookRepository) List() ([]Webhook, error) {
+	var webhooks []Webhook
+	if err := r.db.Order("created_at, id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *webhookRepository) Delete(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&Webhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error
+	})
+}
+
+func (r *webhookRepository) Subscribed(eventType string) ([]Webhook, error) {
+	// the few webhooks are filtered here rather than matching the comma
+	// separated events in sql
+	webhooks, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	var subscribed []Webhook
+	for i := range webhooks {
+		if webhooks[i].Subscribes(eventType) {
+			subscribed = append(subscribed, webhooks[i])
+		}
+	}
+	return subscribed, nil
+}
+
+func (r *webhookRepository) CreateDeliveries(deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// the webhooks are checked in the transaction, so a webhook deleted
+		// before leaves no deliveries behind
+		ids := make([]string, 0, len(deliveries))
+		for i := range deliveries {
+			ids = append(ids, deliveries[i].WebhookID)
+		}
+		var existing []string
+		if err := tx.Model(&Webhook{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		var create []WebhookDelivery
+		for i := range deliveries {
+			for _, id := range existing {
+				if deliveries[i].WebhookID == id {
+					create = append(create, deliveries[i])
+					break
+				}
+			}
+		}
+		if len(create) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&create).Error
+	})
+}
+
+func (r *webhookRepository) GetDelivery(id int64) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := r.db.Where("id = ?", id).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookRepository) ListDeliveries(filter DeliveryFilter, page, pageSize int) ([]WebhookDelivery, int64, error) {
+	var deliveries []WebhookDelivery
+	var total int64
+	if err := filter.apply(r.db.Model(&WebhookDelivery{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	err := filter.apply(r.db.Model(&WebhookDelivery{})).Order("id DESC").Offset(offset).Limit(pageSize).Find(&deliveries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+func (r *webhookRepository) PendingDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", DeliveryStatusPending, now).
+		Order("id").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) UpdateDelivery(delivery *WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *UserTestSuite) TestWebhooks() {
+	repo := NewWebhookRepository(s.db)
+	all := &Webhook{ID: uuid.NewString(), URL: "http://a.com/hook", Secret: "s1"}
+	deletes := &Webhook{ID: uuid.NewString(), URL: "http://b.com/hook", Secret: "s2", Events: EventUserDeleted}
+	s.Require().NoError(repo.Create(all))
+	s.Require().NoError(repo.Create(deletes))
+	defer repo.Delete(all.ID)
+	defer repo.Delete(deletes.ID)
+
+	subscribed, err := repo.Subscribed(EventUserCreated)
+	s.Require().NoError(err)
+	s.Require().Len(subscribed, 1)
+	s.Equal(all.ID, subscribed[0].ID)
+	subscribed, err = repo.Subscribed(EventUserDeleted)
+	s.Require().NoError(err)
+	s.Len(subscribed, 2)
+
+	now := time.Now()
+	deliveries := []WebhookDelivery{
+		{WebhookID: all.ID, EventID: 1, EventType: EventUserDeleted, Payload: `{}`, Status: DeliveryStatusPending, NextAttemptAt: now},
+		{WebhookID: deletes.ID, EventID: 1, EventType: EventUserDeleted, Payload: `{}`, Status: DeliveryStatusPending, NextAttemptAt: now},
+	}
+	s.Require().NoError(repo.CreateDeliveries(deliveries))
+	// the event is published again by the relay
+	s.Require().NoError(repo.CreateDeliveries([]WebhookDelivery{
+		{WebhookID: all.ID, EventID: 1, EventType: EventUserDeleted, Payload: `{}`, Status: DeliveryStatusPending, NextAttemptAt: now},
+	}))
+	list, total, err := repo.ListDeliveries(DeliveryFilter{EventID: 1}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(2, total)
+	s.Require().Len(list, 2)
+	s.Equal(deletes.ID, list[0].WebhookID, "the newest first")
+
+	pending, err := repo.PendingDeliveries(now.Add(time.Second), 10)
+	s.Require().NoError(err)
+	s.Len(pending, 2)
+	delivery, err := repo.GetDelivery(pending[0].ID)
+	s.Require().NoError(err)
+	delivery.Status = DeliveryStatusSucceeded
+	delivery.Attempts = 1
+	delivery.DeliveredAt = &now
+	s.Require().NoError(repo.UpdateDelivery(delivery))
+	pending, err = repo.PendingDeliveries(now.Add(time.Second), 10)
+	s.Require().NoError(err)
+	s.Len(pending, 1)
+	_, total, err = repo.ListDeliveries(DeliveryFilter{WebhookID: all.ID, Status: DeliveryStatusSucceeded}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(1, total)
+
+	// deleting a webhook deletes its deliveries
+	s.Require().NoError(repo.Delete(deletes.ID))
+	_, err = repo.Get(deletes.ID)
+	s.Require().ErrorContains(err, "not found")
+	_, total, err = repo.ListDeliveries(DeliveryFilter{WebhookID: deletes.ID}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(0, total)
+	s.Require().ErrorContains(repo.Delete(deletes.ID), "not found")
+
+	// no delivery is created for a deleted webhook
+	s.Require().NoError(repo.CreateDeliveries([]WebhookDelivery{
+		{WebhookID: deletes.ID, EventID: 2, EventType: EventUserDeleted, Payload: `{}`, Status: DeliveryStatusPending, NextAttemptAt: now},
+	}))
+	_, total, err = repo.ListDeliveries(DeliveryFilter{WebhookID: deletes.ID}, 1, 10)
+	s.Require().NoError(err)
+	s.EqualValues(0, total)
+}
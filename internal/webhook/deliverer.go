@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/outbox"
+	"go-unittest-best-practice/internal/store"
+)
+
+// maxErrorBody is how much of an error response is kept in LastError.
+const maxErrorBody = 512
+
+// Deliverer sends the pending deliveries to the webhooks. A delivery succeeds
+// on a 2xx response, otherwise it is retried with exponential backoff until
+// MaxAttempts attempts failed.
+type Deliverer struct {
+	webhooks   store.WebhookRepository
+	httpClient *http.Client
+	now        func() time.Time
+
+	BatchSize   int
+	Interval    time.Duration
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewDeliverer(webhooks store.WebhookRepository, httpClient *http.Client) *Deliverer {
+	return &Deliverer{
+		webhooks:    webhooks,
+		httpClient:  httpClient,
+		now:         time.Now,
+		BatchSize:   100,
+		Interval:    time.Second,
+		MaxAttempts: 10,
+		MinBackoff:  10 * time.Second,
+		MaxBackoff:  time.Hour,
+	}
+}
+
+// Run sends deliveries until ctx is done.
+func (d *Deliverer) Run(ctx context.Context) error {
+	for {
+		n, err := d.RunOnce(ctx)
+		if err != nil {
+			slog.Error("deliver webhooks failed", "error", err)
+		}
+		if err == nil && n == d.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(d.Interval):
+		}
+	}
+}
+
+// RunOnce sends one batch of due deliveries and returns the number of
+// deliveries loaded.
+func (d *Deliverer) RunOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.webhooks.PendingDeliveries(d.now(), d.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("load pending deliveries failed: %v", err)
+	}
+	webhooks := make(map[string]*store.Webhook)
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+		delivery := &deliveries[i]
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = d.webhooks.Get(delivery.WebhookID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return len(deliveries), fmt.Errorf("load webhook %s failed: %v", delivery.WebhookID, err)
+			}
+			// a missing webhook is kept as nil, it was deleted after its
+			// deliveries were loaded
+			webhooks[delivery.WebhookID] = webhook
+		}
+
+		if webhook == nil {
+			delivery.Status = store.DeliveryStatusFailed
+			delivery.LastError = "webhook not found"
+		} else {
+			status, err := d.send(ctx, webhook, delivery)
+			d.record(delivery, status, err)
+		}
+		if err := d.webhooks.UpdateDelivery(delivery); err != nil {
+			return len(deliveries), fmt.Errorf("update delivery %d failed: %v", delivery.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// record updates the state of delivery after an attempt.
+func (d *Deliverer) record(delivery *store.WebhookDelivery, status int, err error) {
+	now := d.now()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	if err == nil {
+		delivery.Status = store.DeliveryStatusSucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status = store.DeliveryStatusFailed
+		slog.Warn("webhook delivery failed", "id", delivery.ID, "webhook", delivery.WebhookID, "attempts", delivery.Attempts, "error", err)
+		return
+	}
+	delivery.NextAttemptAt = now.Add(outbox.Backoff(d.MinBackoff, d.MaxBackoff, delivery.Attempts))
+}
+
+// send posts the signed payload and returns the response status code.
+func (d *Deliverer) send(ctx context.Context, webhook *store.Webhook, delivery *store.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := d.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, now, body))
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
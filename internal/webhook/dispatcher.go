@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-unittest-best-practice/internal/outbox"
+	"go-unittest-best-practice/internal/store"
+)
+
+// Dispatcher is the outbox sink which creates a delivery of each event for
+// every subscribed webhook, the deliveries are sent by a Deliverer. Publishing
+// an event again does not create duplicate deliveries.
+type Dispatcher struct {
+	webhooks store.WebhookRepository
+	now      func() time.Time
+}
+
+var _ outbox.Sink = &Dispatcher{}
+
+func NewDispatcher(webhooks store.WebhookRepository) *Dispatcher {
+	return &Dispatcher{webhooks: webhooks, now: time.Now}
+}
+
+func (d *Dispatcher) Name() string {
+	return "webhooks"
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event outbox.Event) error {
+	webhooks, err := d.webhooks.Subscribed(event.Type)
+	if err != nil {
+		return fmt.Errorf("load webhooks failed: %v", err)
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(&event)
+	if err != nil {
+		return err
+	}
+	deliveries := make([]store.WebhookDelivery, 0, len(webhooks))
+	for _, w := range webhooks {
+		deliveries = append(deliveries, store.WebhookDelivery{
+			WebhookID:     w.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        store.DeliveryStatusPending,
+			NextAttemptAt: d.now(),
+		})
+	}
+	if err := d.webhooks.CreateDeliveries(deliveries); err != nil {
+		return fmt.Errorf("create deliveries failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+
+	"go-unittest-best-practice/internal/outbox"
+	"go-unittest-best-practice/internal/store"
+)
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	header := http.Header{}
+	header.Set(HeaderTimestamp, "1700000000")
+	header.Set(HeaderSignature, Sign("s3cret", now, body))
+
+	if err := Verify("s3cret", header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("expect nil error, but got %v", err)
+	}
+	if err := Verify("other", header, body, now, 5*time.Minute); err == nil || err.Error() != "signature mismatch" {
+		t.Errorf("expect signature mismatch, but got %v", err)
+	}
+	if err := Verify("s3cret", header, []byte(`{"id":2}`), now, 5*time.Minute); err == nil {
+		t.Errorf("expect signature mismatch of a changed body, but got nil")
+	}
+	if err := Verify("s3cret", header, body, now.Add(time.Hour), 5*time.Minute); err == nil {
+		t.Errorf("expect timestamp out of tolerance, but got nil")
+	}
+}
+
+// receiver is a webhook endpoint recording the verified deliveries.
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	secret   string
+	received []http.Header
+	errs     []error
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := Verify(r.secret, req.Header, body, time.Now(), time.Minute); err != nil {
+		r.errs = append(r.errs, err)
+	}
+	r.received = append(r.received, req.Header)
+	w.WriteHeader(r.status)
+	w.Write([]byte("busy\n"))
+}
+
+type WebhookTestSuite struct {
+	suite.Suite
+
+	ctrl         *gomock.Controller
+	mockWebhooks *store.MockWebhookRepository
+	receiver     *receiver
+	server       *httptest.Server
+	now          time.Time
+}
+
+func (s *WebhookTestSuite) SetupTest() {
+	s.ctrl = gomock.NewController(s.T())
+	s.mockWebhooks = store.NewMockWebhookRepository(s.ctrl)
+	s.receiver = &receiver{status: http.StatusOK, secret: "s3cret"}
+	s.server = httptest.NewServer(s.receiver)
+	s.now = time.Now()
+}
+
+func (s *WebhookTestSuite) TearDownTest() {
+	s.server.Close()
+	s.ctrl.Finish()
+}
+
+func (s *WebhookTestSuite) TestDispatch() {
+	d := NewDispatcher(s.mockWebhooks)
+	d.now = func() time.Time { return s.now }
+	event := outbox.Event{ID: 7, Type: store.EventUserCreated, UserID: "u1", Data: json.RawMessage(`{"id":"u1"}`)}
+
+	s.Run("no webhooks", func() {
+		s.mockWebhooks.EXPECT().Subscribed(store.EventUserCreated).Return(nil, nil).Times(1)
+		s.Require().NoError(d.Publish(context.Background(), event))
+	})
+	s.Run("deliveries created", func() {
+		s.mockWebhooks.EXPECT().Subscribed(store.EventUserCreated).Return([]store.Webhook{{ID: "w1"}, {ID: "w2"}}, nil).Times(1)
+		s.mockWebhooks.EXPECT().CreateDeliveries(gomock.Any()).DoAndReturn(func(deliveries []store.WebhookDelivery) error {
+			s.Require().Len(deliveries, 2)
+			s.Equal("w2", deliveries[1].WebhookID)
+			s.EqualValues(7, deliveries[1].EventID)
+			s.Equal(store.DeliveryStatusPending, deliveries[1].Status)
+			s.Equal(s.now, deliveries[1].NextAttemptAt)
+			s.JSONEq(`{"id":7,"type":"user.created","userId":"u1","requestId":"","occurredAt":"0001-01-01T00:00:00Z","data":{"id":"u1"}}`, deliveries[1].Payload)
+			return nil
+		}).Times(1)
+		s.Require().NoError(d.Publish(context.Background(), event))
+	})
+	s.Run("load failed", func() {
+		s.mockWebhooks.EXPECT().Subscribed(store.EventUserCreated).Return(nil, errors.New("db down")).Times(1)
+		s.Require().ErrorContains(d.Publish(context.Background(), event), "load webhooks failed")
+	})
+}
+
+func (s *WebhookTestSuite) TestDeliver() {
+	d := NewDeliverer(s.mockWebhooks, s.server.Client())
+	d.now = func() time.Time { return s.now }
+	webhook := &store.Webhook{ID: "w1", URL: s.server.URL, Secret: "s3cret"}
+
+	s.Run("succeeded", func() {
+		s.mockWebhooks.EXPECT().PendingDeliveries(s.now, 100).Return([]store.WebhookDelivery{
+			{ID: 1, WebhookID: "w1", EventType: store.EventUserCreated, Payload: `{"id":1}`, Status: store.DeliveryStatusPending},
+			{ID: 2, WebhookID: "w1", EventType: store.EventUserDeleted, Payload: `{"id":2}`, Status: store.DeliveryStatusPending},
+		}, nil).Times(1)
+		// the webhook is loaded once per batch
+		s.mockWebhooks.EXPECT().Get("w1").Return(webhook, nil).Times(1)
+		s.mockWebhooks.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery *store.WebhookDelivery) error {
+			s.Equal(store.DeliveryStatusSucceeded, delivery.Status)
+			s.Equal(1, delivery.Attempts)
+			s.Equal(http.StatusOK, delivery.ResponseStatus)
+			s.Equal(&s.now, delivery.DeliveredAt)
+			return nil
+		}).Times(2)
+
+		n, err := d.RunOnce(context.Background())
+		s.Require().NoError(err)
+		s.Equal(2, n)
+		s.Empty(s.receiver.errs)
+		s.Require().Len(s.receiver.received, 2)
+		s.Equal("2", s.receiver.received[1].Get(HeaderDeliveryID))
+		s.Equal(store.EventUserDeleted, s.receiver.received[1].Get(HeaderEvent))
+	})
+
+	s.Run("retried", func() {
+		s.receiver.status = http.StatusServiceUnavailable
+		s.mockWebhooks.EXPECT().PendingDeliveries(s.now, 100).Return([]store.WebhookDelivery{
+			{ID: 3, WebhookID: "w1", Payload: `{}`, Status: store.DeliveryStatusPending, Attempts: 2},
+		}, nil).Times(1)
+		s.mockWebhooks.EXPECT().Get("w1").Return(webhook, nil).Times(1)
+		s.mockWebhooks.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery *store.WebhookDelivery) error {
+			s.Equal(store.DeliveryStatusPending, delivery.Status)
+			s.Equal(3, delivery.Attempts)
+			s.Equal(http.StatusServiceUnavailable, delivery.ResponseStatus)
+			s.Equal("unexpected status code 503: busy", delivery.LastError)
+			s.Equal(s.now.Add(40*time.Second), delivery.NextAttemptAt)
+			return nil
+		}).Times(1)
+
+		_, err := d.RunOnce(context.Background())
+		s.Require().NoError(err)
+	})
+
+	s.Run("gave up", func() {
+		s.receiver.status = http.StatusInternalServerError
+		s.mockWebhooks.EXPECT().PendingDeliveries(s.now, 100).Return([]store.WebhookDelivery{
+			{ID: 4, WebhookID: "w1", Payload: `{}`, Status: store.DeliveryStatusPending, Attempts: d.MaxAttempts - 1},
+		}, nil).Times(1)
+		s.mockWebhooks.EXPECT().Get("w1").Return(webhook, nil).Times(1)
+		s.mockWebhooks.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery *store.WebhookDelivery) error {
+			s.Equal(store.DeliveryStatusFailed, delivery.Status)
+			s.Equal(d.MaxAttempts, delivery.Attempts)
+			return nil
+		}).Times(1)
+
+		_, err := d.RunOnce(context.Background())
+		s.Require().NoError(err)
+	})
+
+	s.Run("webhook deleted", func() {
+		s.receiver.status = http.StatusOK
+		s.mockWebhooks.EXPECT().PendingDeliveries(s.now, 100).Return([]store.WebhookDelivery{
+			{ID: 5, WebhookID: "w2", Payload: `{}`, Status: store.DeliveryStatusPending},
+			{ID: 6, WebhookID: "w1", Payload: `{}`, Status: store.DeliveryStatusPending},
+		}, nil).Times(1)
+		s.mockWebhooks.EXPECT().Get("w2").Return(nil, gorm.ErrRecordNotFound).Times(1)
+		s.mockWebhooks.EXPECT().Get("w1").Return(webhook, nil).Times(1)
+		gomock.InOrder(
+			s.mockWebhooks.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery *store.WebhookDelivery) error {
+				s.Equal(store.DeliveryStatusFailed, delivery.Status)
+				s.Equal("webhook not found", delivery.LastError)
+				s.Equal(0, delivery.Attempts)
+				return nil
+			}),
+			s.mockWebhooks.EXPECT().UpdateDelivery(gomock.Any()).DoAndReturn(func(delivery *store.WebhookDelivery) error {
+				s.Equal(store.DeliveryStatusSucceeded, delivery.Status)
+				return nil
+			}),
+		)
+
+		_, err := d.RunOnce(context.Background())
+		s.Require().NoError(err)
+	})
+}
+
+func TestWebhook(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
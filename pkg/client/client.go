@@ -1,12 +1,14 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,14 +19,110 @@ type Client interface {
 	UserUpdate(u User) error
 	UserDelete(id string) error
 	UserList() ([]User, int64, error)
+	// UserCreateContext, UserGetContext, UserUpdateContext, UserDeleteContext
+	// and UserListContext are the methods above sending the request with ctx.
+	UserCreateContext(ctx context.Context, u User) (*User, error)
+	UserGetContext(ctx context.Context, id string) (*User, error)
+	UserUpdateContext(ctx context.Context, u User) error
+	UserDeleteContext(ctx context.Context, id string) error
+	UserListContext(ctx context.Context) ([]User, int64, error)
+	// UserBatchGet gets the users by ids or by emails in one request, only one
+	// of them may be set. The ids or emails without a user are returned as
+	// missing.
+	UserBatchGet(ctx context.Context, ids, emails []string) (users []User, missing []string, err error)
+	// UserBatchDelete deletes the users matching filter, which must not be
+	// zero. Either all or none are deleted, and the request fails if more users
+	// match than the server allows. With dryRun the matched users are only
+	// counted.
+	UserBatchDelete(ctx context.Context, filter UserFilter, dryRun bool) (int64, error)
+	// UserBatchUpdate sets fields of the users matching filter, the same way as
+	// UserBatchDelete.
+	UserBatchUpdate(ctx context.Context, filter UserFilter, fields UserFields, dryRun bool) (int64, error)
+	// UserImport creates users from csv with a header row or ndjson read from
+	// r, with dryRun the rows are validated but not created.
+	UserImport(ctx context.Context, r io.Reader, format string, dryRun bool) (*ImportReport, error)
+	// UserExport streams the users selected by opts to w.
+	UserExport(ctx context.Context, w io.Writer, opts ExportOptions) error
+	// WatchUsers streams the user events of types, or of all types if none is
+	// set, starting with the next event. The stream reconnects after it breaks
+	// and resumes after the last event it got. The channel is closed when ctx
+	// is done.
+	WatchUsers(ctx context.Context, types ...string) (<-chan UserEvent, error)
+}
+
+// UserFilter selects users, zero value fields match all users.
+type UserFilter struct {
+	IDs []string
+	// Name matches users whose name contains Name.
+	Name          string
+	Email         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func (f UserFilter) params(params url.Values) {
+	if len(f.IDs) > 0 {
+		params.Set("ids", strings.Join(f.IDs, ","))
+	}
+	if f.Name != "" {
+		params.Set("name", f.Name)
+	}
+	if f.Email != "" {
+		params.Set("email", f.Email)
+	}
+	if !f.CreatedAfter.IsZero() {
+		params.Set("created_after", f.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !f.CreatedBefore.IsZero() {
+		params.Set("created_before", f.CreatedBefore.Format(time.RFC3339Nano))
+	}
+}
+
+// UserFields are the fields set by UserBatchUpdate, nil fields are not
+// changed.
+type UserFields struct {
+	Name *string
+	Age  *int
+}
+
+// ExportOptions are the options of UserExport, zero value fields are not
+// applied.
+type ExportOptions struct {
+	UserFilter
+	// Format is one of csv, ndjson and json, csv by default.
+	Format string
+	// Fields are the exported fields, all fields by default.
+	Fields []string
+	// Gzip writes the gzip compressed export to w.
+	Gzip bool
 }
 
 var _ Client = &client{}
 
-func New(server string) Client {
+type Option func(c *client)
+
+// WithHTTPClient sets the http client used to send requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithActor sets the actor sent with every request, it is recorded in the
+// audit log of the changes made by the requests.
+func WithActor(actor string) Option {
+	return func(c *client) {
+		c.actor = actor
+	}
+}
+
+func New(server string, opts ...Option) Client {
 	c := &client{
 		httpClient: &http.Client{},
-		server:     server,
+		server:     strings.TrimSuffix(server, "/"),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	return c
 }
@@ -32,122 +130,175 @@ func New(server string) Client {
 type client struct {
 	httpClient *http.Client
 	server     string
+	actor      string
 }
 
 func (c *client) UserCreate(u User) (*User, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/user/create", c.server), nil)
-	if err != nil {
-		return nil, err
+	return c.UserCreateContext(context.Background(), u)
+}
+
+func (c *client) UserGet(id string) (*User, error) {
+	return c.UserGetContext(context.Background(), id)
+}
+
+func (c *client) UserUpdate(u User) error {
+	return c.UserUpdateContext(context.Background(), u)
+}
+
+func (c *client) UserDelete(id string) error {
+	return c.UserDeleteContext(context.Background(), id)
+}
+
+func (c *client) UserList() ([]User, int64, error) {
+	return c.UserListContext(context.Background())
+}
+
+func (c *client) UserCreateContext(ctx context.Context, u User) (*User, error) {
+	params := url.Values{}
+	params.Set("name", u.Name)
+	params.Set("email", u.Email)
+	if u.Age != 0 {
+		params.Set("age", strconv.Itoa(u.Age))
 	}
-	postData := url.Values{}
-	postData.Set("name", u.Name)
-	postData.Set("email", u.Email)
-	req.Form = postData
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+	var userResp CreateGetResponse
+	if err := c.do(ctx, http.MethodPost, "/user/create", params, &userResp); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &userResp.Data, nil
+}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("create user failed: %s", string(data))
-	}
-	if resp.StatusCode != http.StatusOK {
-		var errRes Response
-		json.Unmarshal(data, &errRes)
-		if errRes.Error != "" {
-			return nil, errors.New(errRes.Error)
-		}
-		return nil, fmt.Errorf("create user failed: %d", resp.StatusCode)
-	}
+func (c *client) UserGetContext(ctx context.Context, id string) (*User, error) {
+	params := url.Values{}
+	params.Set("id", id)
 
 	var userResp CreateGetResponse
-	err = json.Unmarshal(data, &userResp)
-	if err != nil {
-		return nil, fmt.Errorf("parse response failed: %v", err)
+	if err := c.do(ctx, http.MethodGet, "/user/get", params, &userResp); err != nil {
+		return nil, err
 	}
 	return &userResp.Data, nil
 }
 
-func (c *client) UserGet(id string) (*User, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/user/create", c.server), nil)
-	if err != nil {
-		return nil, err
+// UserUpdateContext updates the name, email and age of the user u.ID, zero value
+// fields are left unchanged.
+func (c *client) UserUpdateContext(ctx context.Context, u User) error {
+	params := url.Values{}
+	params.Set("id", u.ID)
+	if u.Name != "" {
+		params.Set("name", u.Name)
+	}
+	if u.Email != "" {
+		params.Set("email", u.Email)
 	}
-	postData := url.Values{}
-	postData.Set("id", id)
-	req.Form = postData
+	if u.Age != 0 {
+		params.Set("age", strconv.Itoa(u.Age))
+	}
+	return c.do(ctx, http.MethodPost, "/user/update", params, nil)
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+func (c *client) UserDeleteContext(ctx context.Context, id string) error {
+	params := url.Values{}
+	params.Set("id", id)
+	return c.do(ctx, http.MethodPost, "/user/delete", params, nil)
+}
+
+func (c *client) UserListContext(ctx context.Context) ([]User, int64, error) {
+	var listResp ListResponse
+	if err := c.do(ctx, http.MethodGet, "/user/list", nil, &listResp); err != nil {
+		return nil, 0, err
 	}
-	defer resp.Body.Close()
+	return listResp.Data.Users, listResp.Data.Total, nil
+}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("create user failed: %s", string(data))
+func (c *client) UserBatchGet(ctx context.Context, ids, emails []string) ([]User, []string, error) {
+	params := url.Values{}
+	if len(ids) > 0 {
+		params.Set("ids", strings.Join(ids, ","))
 	}
-	if resp.StatusCode != http.StatusOK {
-		var errRes Response
-		json.Unmarshal(data, &errRes)
-		if errRes.Error != "" {
-			return nil, errors.New(errRes.Error)
-		}
-		return nil, fmt.Errorf("create user failed: %d", resp.StatusCode)
+	if len(emails) > 0 {
+		params.Set("emails", strings.Join(emails, ","))
 	}
 
-	var userResp CreateGetResponse
-	err = json.Unmarshal(data, &userResp)
-	if err != nil {
-		return nil, fmt.Errorf("parse response failed: %v", err)
+	// post the keys in the body, there may be too many for the url
+	var batchResp BatchGetResponse
+	if err := c.do(ctx, http.MethodPost, "/user/batch_get", params, &batchResp); err != nil {
+		return nil, nil, err
 	}
-	return &userResp.Data, nil
+	return batchResp.Data.Users, batchResp.Data.Missing, nil
 }
 
-func (c *client) UserUpdate(u User) error {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/user/update", c.server), nil)
-	if err != nil {
-		return nil
+func (c *client) UserBatchDelete(ctx context.Context, filter UserFilter, dryRun bool) (int64, error) {
+	params := url.Values{}
+	filter.params(params)
+	params.Set("dry_run", strconv.FormatBool(dryRun))
+
+	var batchResp BatchResponse
+	if err := c.do(ctx, http.MethodPost, "/user/batch_delete", params, &batchResp); err != nil {
+		return 0, err
 	}
-	postData := url.Values{}
-	postData.Set("name", u.Name)
-	postData.Set("id", u.ID)
-	req.Form = postData
+	return batchResp.Data.Affected, nil
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil
+func (c *client) UserBatchUpdate(ctx context.Context, filter UserFilter, fields UserFields, dryRun bool) (int64, error) {
+	params := url.Values{}
+	filter.params(params)
+	if fields.Name != nil {
+		params.Set("set_name", *fields.Name)
 	}
-	defer resp.Body.Close()
+	if fields.Age != nil {
+		params.Set("set_age", strconv.Itoa(*fields.Age))
+	}
+	params.Set("dry_run", strconv.FormatBool(dryRun))
 
-	if resp.StatusCode != http.StatusOK {
-		var errRes Response
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("update user failed: %s", string(data))
-		}
-		json.Unmarshal(data, &errRes)
-		if errRes.Error != "" {
-			return errors.New(errRes.Error)
-		}
-		return fmt.Errorf("update user failed: %d", resp.StatusCode)
+	var batchResp BatchResponse
+	if err := c.do(ctx, http.MethodPost, "/user/batch_update", params, &batchResp); err != nil {
+		return 0, err
 	}
+	return batchResp.Data.Affected, nil
+}
 
-	return nil
+func (c *client) UserImport(ctx context.Context, r io.Reader, format string, dryRun bool) (*ImportReport, error) {
+	var contentType string
+	switch format {
+	case ImportFormatCSV:
+		contentType = "text/csv"
+	case ImportFormatNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+	params := url.Values{}
+	params.Set("format", format)
+	params.Set("dry_run", strconv.FormatBool(dryRun))
+
+	var importResp ImportResponse
+	u := c.server + "/user/import?" + params.Encode()
+	if err := c.send(ctx, http.MethodPost, u, contentType, r, &importResp); err != nil {
+		return nil, err
+	}
+	return &importResp.Data, nil
 }
 
-func (c *client) UserDelete(id string) error {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/user/delete", c.server), nil)
+func (c *client) UserExport(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	params := url.Values{}
+	params.Set("format", ExportFormatCSV)
+	if opts.Format != "" {
+		params.Set("format", opts.Format)
+	}
+	if len(opts.Fields) > 0 {
+		params.Set("fields", strings.Join(opts.Fields, ","))
+	}
+	opts.UserFilter.params(params)
+
+	req, err := c.newRequest(ctx, http.MethodGet, c.server+"/user/export?"+params.Encode(), nil)
 	if err != nil {
 		return err
 	}
-	postData := url.Values{}
-	postData.Set("id", id)
-	req.Form = postData
-
+	// the transport decompresses the body only if it sets Accept-Encoding
+	// itself, so setting it keeps the body compressed
+	if opts.Gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
@@ -155,53 +306,98 @@ func (c *client) UserDelete(id string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errRes Response
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("delete user failed: %s", string(data))
-		}
-		json.Unmarshal(data, &errRes)
-		if errRes.Error != "" {
-			return errors.New(errRes.Error)
+		return responseError(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// do sends params as the query of GET requests or as the form body of other
+// requests, and decodes the response into out if out is not nil.
+func (c *client) do(ctx context.Context, method, path string, params url.Values, out interface{}) error {
+	u := c.server + path
+	if method == http.MethodGet {
+		if len(params) > 0 {
+			u += "?" + params.Encode()
 		}
-		return fmt.Errorf("delete user failed: %d", resp.StatusCode)
+		return c.send(ctx, method, u, "", nil, out)
 	}
+	return c.send(ctx, method, u, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()), out)
+}
 
-	return nil
+func (c *client) newRequest(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.actor != "" {
+		req.Header.Set(HeaderActor, c.actor)
+	}
+	return req, nil
 }
 
-func (c *client) UserList() ([]User, int64, error) {
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/user/list", c.server), nil)
+// send sends the request and decodes the response into out if out is not nil.
+func (c *client) send(ctx context.Context, method, u, contentType string, body io.Reader, out interface{}) error {
+	req, err := c.newRequest(ctx, method, u, body)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, 0, fmt.Errorf("list user failed: %s", string(data))
+		return fmt.Errorf("read response failed: %v", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		var errRes Response
-		json.Unmarshal(data, &errRes)
-		if errRes.Error != "" {
-			return nil, 0, errors.New(errRes.Error)
-		}
-		return nil, 0, fmt.Errorf("list user failed: %d", resp.StatusCode)
+		return errorFromBody(resp.StatusCode, data)
 	}
 
-	var listResp ListResponse
-	err = json.Unmarshal(data, &listResp)
-	if err != nil {
-		return nil, 0, fmt.Errorf("parse response failed: %v", err)
+	if out == nil {
+		return nil
 	}
-	return listResp.Data.Users, listResp.Data.Total, nil
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("parse response failed: %v", err)
+	}
+	return nil
 }
 
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return errorFromBody(resp.StatusCode, data)
+}
+
+func errorFromBody(statusCode int, data []byte) error {
+	var errRes Response
+	json.Unmarshal(data, &errRes)
+	return &APIError{StatusCode: statusCode, Message: errRes.Error}
+}
+
+// APIError is an error response of the server, its error is the message of
+// the response.
+type APIError struct {
+	StatusCode int
+	// Message is empty if the response has no error message.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	}
+	return e.Message
+}
+
+// HeaderActor is the request header of the actor.
+const HeaderActor = "X-Actor"
+
 type CreateGetResponse struct {
 	Data User `json:"data"`
 }
@@ -215,16 +411,67 @@ type ListResponseData struct {
 	Users []User `json:"users"`
 }
 
+type BatchGetResponse struct {
+	Data BatchGetResponseData `json:"data"`
+}
+
+type BatchGetResponseData struct {
+	Users   []User   `json:"users" yaml:"users"`
+	Missing []string `json:"missing" yaml:"missing"`
+}
+
+type BatchResponse struct {
+	Data BatchResult `json:"data"`
+}
+
+type BatchResult struct {
+	DryRun   bool  `json:"dryRun" yaml:"dryRun"`
+	Affected int64 `json:"affected" yaml:"affected"`
+}
+
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatJSON   = "json"
+)
+
+type ImportResponse struct {
+	Data ImportReport `json:"data"`
+}
+
+type ImportReport struct {
+	DryRun  bool        `json:"dryRun" yaml:"dryRun"`
+	Created int         `json:"created" yaml:"created"`
+	Skipped int         `json:"skipped" yaml:"skipped"`
+	Failed  int         `json:"failed" yaml:"failed"`
+	Rows    []ImportRow `json:"rows" yaml:"rows"`
+}
+
+type ImportRow struct {
+	Line   int    `json:"line" yaml:"line"`
+	Email  string `json:"email" yaml:"email"`
+	Status string `json:"status" yaml:"status"`
+	ID     string `json:"id,omitempty" yaml:"id,omitempty"`
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
 type Response struct {
 	Data  interface{} `json:"data"`
 	Error string      `json:"error"`
 }
 
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Age       int       `json:"age"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        string    `json:"id" yaml:"id"`
+	Name      string    `json:"name" yaml:"name"`
+	Email     string    `json:"email" yaml:"email"`
+	Age       int       `json:"age" yaml:"age"`
+	CreatedAt time.Time `json:"createdAt" yaml:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" yaml:"updatedAt"`
 }
@@ -10,6 +10,8 @@
 package client
 
 import (
+	context "context"
+	io "io"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -19,6 +21,7 @@ import (
 type MockClient struct {
 	ctrl     *gomock.Controller
 	recorder *MockClientMockRecorder
+	isgomock struct{}
 }
 
 // MockClientMockRecorder is the mock recorder for MockClient.
@@ -38,6 +41,52 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// UserBatchDelete mocks base method.
+func (m *MockClient) UserBatchDelete(ctx context.Context, filter UserFilter, dryRun bool) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserBatchDelete", ctx, filter, dryRun)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserBatchDelete indicates an expected call of UserBatchDelete.
+func (mr *MockClientMockRecorder) UserBatchDelete(ctx, filter, dryRun any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserBatchDelete", reflect.TypeOf((*MockClient)(nil).UserBatchDelete), ctx, filter, dryRun)
+}
+
+// UserBatchGet mocks base method.
+func (m *MockClient) UserBatchGet(ctx context.Context, ids, emails []string) ([]User, []string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserBatchGet", ctx, ids, emails)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UserBatchGet indicates an expected call of UserBatchGet.
+func (mr *MockClientMockRecorder) UserBatchGet(ctx, ids, emails any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserBatchGet", reflect.TypeOf((*MockClient)(nil).UserBatchGet), ctx, ids, emails)
+}
+
+// UserBatchUpdate mocks base method.
+func (m *MockClient) UserBatchUpdate(ctx context.Context, filter UserFilter, fields UserFields, dryRun bool) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserBatchUpdate", ctx, filter, fields, dryRun)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserBatchUpdate indicates an expected call of UserBatchUpdate.
+func (mr *MockClientMockRecorder) UserBatchUpdate(ctx, filter, fields, dryRun any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserBatchUpdate", reflect.TypeOf((*MockClient)(nil).UserBatchUpdate), ctx, filter, fields, dryRun)
+}
+
 // UserCreate mocks base method.
 func (m *MockClient) UserCreate(u User) (*User, error) {
 	m.ctrl.T.Helper()
@@ -53,6 +102,21 @@ func (mr *MockClientMockRecorder) UserCreate(u any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserCreate", reflect.TypeOf((*MockClient)(nil).UserCreate), u)
 }
 
+// UserCreateContext mocks base method.
+func (m *MockClient) UserCreateContext(ctx context.Context, u User) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserCreateContext", ctx, u)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserCreateContext indicates an expected call of UserCreateContext.
+func (mr *MockClientMockRecorder) UserCreateContext(ctx, u any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserCreateContext", reflect.TypeOf((*MockClient)(nil).UserCreateContext), ctx, u)
+}
+
 // UserDelete mocks base method.
 func (m *MockClient) UserDelete(id string) error {
 	m.ctrl.T.Helper()
@@ -67,6 +131,34 @@ func (mr *MockClientMockRecorder) UserDelete(id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserDelete", reflect.TypeOf((*MockClient)(nil).UserDelete), id)
 }
 
+// UserDeleteContext mocks base method.
+func (m *MockClient) UserDeleteContext(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserDeleteContext", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserDeleteContext indicates an expected call of UserDeleteContext.
+func (mr *MockClientMockRecorder) UserDeleteContext(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserDeleteContext", reflect.TypeOf((*MockClient)(nil).UserDeleteContext), ctx, id)
+}
+
+// UserExport mocks base method.
+func (m *MockClient) UserExport(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserExport", ctx, w, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserExport indicates an expected call of UserExport.
+func (mr *MockClientMockRecorder) UserExport(ctx, w, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserExport", reflect.TypeOf((*MockClient)(nil).UserExport), ctx, w, opts)
+}
+
 // UserGet mocks base method.
 func (m *MockClient) UserGet(id string) (*User, error) {
 	m.ctrl.T.Helper()
@@ -82,6 +174,36 @@ func (mr *MockClientMockRecorder) UserGet(id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserGet", reflect.TypeOf((*MockClient)(nil).UserGet), id)
 }
 
+// UserGetContext mocks base method.
+func (m *MockClient) UserGetContext(ctx context.Context, id string) (*User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserGetContext", ctx, id)
+	ret0, _ := ret[0].(*User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserGetContext indicates an expected call of UserGetContext.
+func (mr *MockClientMockRecorder) UserGetContext(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserGetContext", reflect.TypeOf((*MockClient)(nil).UserGetContext), ctx, id)
+}
+
+// UserImport mocks base method.
+func (m *MockClient) UserImport(ctx context.Context, r io.Reader, format string, dryRun bool) (*ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserImport", ctx, r, format, dryRun)
+	ret0, _ := ret[0].(*ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserImport indicates an expected call of UserImport.
+func (mr *MockClientMockRecorder) UserImport(ctx, r, format, dryRun any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserImport", reflect.TypeOf((*MockClient)(nil).UserImport), ctx, r, format, dryRun)
+}
+
 // UserList mocks base method.
 func (m *MockClient) UserList() ([]User, int64, error) {
 	m.ctrl.T.Helper()
@@ -98,6 +220,22 @@ func (mr *MockClientMockRecorder) UserList() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserList", reflect.TypeOf((*MockClient)(nil).UserList))
 }
 
+// UserListContext mocks base method.
+func (m *MockClient) UserListContext(ctx context.Context) ([]User, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserListContext", ctx)
+	ret0, _ := ret[0].([]User)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UserListContext indicates an expected call of UserListContext.
+func (mr *MockClientMockRecorder) UserListContext(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserListContext", reflect.TypeOf((*MockClient)(nil).UserListContext), ctx)
+}
+
 // UserUpdate mocks base method.
 func (m *MockClient) UserUpdate(u User) error {
 	m.ctrl.T.Helper()
@@ -111,3 +249,37 @@ func (mr *MockClientMockRecorder) UserUpdate(u any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserUpdate", reflect.TypeOf((*MockClient)(nil).UserUpdate), u)
 }
+
+// UserUpdateContext mocks base method.
+func (m *MockClient) UserUpdateContext(ctx context.Context, u User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserUpdateContext", ctx, u)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserUpdateContext indicates an expected call of UserUpdateContext.
+func (mr *MockClientMockRecorder) UserUpdateContext(ctx, u any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserUpdateContext", reflect.TypeOf((*MockClient)(nil).UserUpdateContext), ctx, u)
+}
+
+// WatchUsers mocks base method.
+func (m *MockClient) WatchUsers(ctx context.Context, types ...string) (<-chan UserEvent, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range types {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WatchUsers", varargs...)
+	ret0, _ := ret[0].(<-chan UserEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchUsers indicates an expected call of WatchUsers.
+func (mr *MockClientMockRecorder) WatchUsers(ctx any, types ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, types...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchUsers", reflect.TypeOf((*MockClient)(nil).WatchUsers), varargs...)
+}
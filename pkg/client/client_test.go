@@ -1,9 +1,12 @@
 package client
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -23,6 +26,10 @@ func TestClient(t *testing.T) {
 
 	t.Run("create", func(t *testing.T) {
 		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/user/create", r.URL.Path)
+			assert.Equal(t, "liuliu", r.FormValue("name"))
+			assert.Equal(t, "aa@bb.com", r.FormValue("email"))
 			w.Write([]byte(`{"data":{"id":"0198271f-bc9d-74ac-a63b-41cf2c6c2f82","name":"liuliu","email":"aa@bb.com","age":0,"createdAt":"2025-07-20T16:13:21+08:00","updatedAt":"2025-07-20T16:13:21+08:00"}}`))
 		}
 		user, err := c.UserCreate(User{
@@ -40,6 +47,9 @@ func TestClient(t *testing.T) {
 	})
 	t.Run("get", func(t *testing.T) {
 		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/user/get", r.URL.Path)
+			assert.Equal(t, "0198271f-bc9d-74ac-a63b-41cf2c6c2f82", r.FormValue("id"))
 			w.Write([]byte(`{"data":{"id":"0198271f-bc9d-74ac-a63b-41cf2c6c2f82","name":"liuliu","email":"aa@bb.com","age":0,"createdAt":"2025-07-20T16:13:21+08:00","updatedAt":"2025-07-20T16:13:21+08:00"}}`))
 		}
 		user, err := c.UserGet("0198271f-bc9d-74ac-a63b-41cf2c6c2f82")
@@ -52,8 +62,30 @@ func TestClient(t *testing.T) {
 			UpdatedAt: time.Unix(1752999201, 0),
 		}, user)
 	})
+	t.Run("get error", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"record not found"}`))
+		}
+		_, err := c.UserGet("0198271f-bc9d-74ac-a63b-41cf2c6c2f82")
+		assert.EqualError(t, err, "record not found")
+		var apiErr *APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	})
+	t.Run("status error", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, err := c.UserGet("0198271f-bc9d-74ac-a63b-41cf2c6c2f82")
+		assert.Equal(t, &APIError{StatusCode: http.StatusBadGateway}, err)
+		assert.EqualError(t, err, "unexpected status code 502")
+	})
 	t.Run("update", func(t *testing.T) {
 		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/user/update", r.URL.Path)
+			assert.Equal(t, "liuliu2", r.FormValue("name"))
+			assert.Empty(t, r.FormValue("email"))
 		}
 		err := c.UserUpdate(User{
 			ID:   "0198271f-bc9d-74ac-a63b-41cf2c6c2f82",
@@ -67,7 +99,167 @@ func TestClient(t *testing.T) {
 		err := c.UserDelete("0198271f-bc9d-74ac-a63b-41cf2c6c2f82")
 		assert.Nil(t, err)
 	})
+	t.Run("import", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/user/import", r.URL.Path)
+			assert.Equal(t, "ndjson", r.URL.Query().Get("format"))
+			assert.Equal(t, "true", r.URL.Query().Get("dry_run"))
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, `{"name":"liuliu","email":"aa@bb.com"}`, string(body))
+			w.Write([]byte(`{"data":{"dryRun":true,"created":1,"skipped":0,"failed":0,"rows":[{"line":1,"email":"aa@bb.com","status":"created"}]}}`))
+		}
+		report, err := c.UserImport(context.Background(), strings.NewReader(`{"name":"liuliu","email":"aa@bb.com"}`), ImportFormatNDJSON, true)
+		assert.Nil(t, err)
+		assert.EqualValues(t, &ImportReport{
+			DryRun:  true,
+			Created: 1,
+			Rows:    []ImportRow{{Line: 1, Email: "aa@bb.com", Status: ImportStatusCreated}},
+		}, report)
+	})
+	t.Run("batch get", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/user/batch_get", r.URL.Path)
+			assert.Equal(t, "iddddd,idxxxx", r.PostFormValue("ids"))
+			w.Write([]byte(`{"data":{"users":[{"id":"iddddd","name":"liuliu","email":"aa@bb.com","age":0,"createdAt":"2025-07-20T08:13:21Z","updatedAt":"2025-07-20T08:13:21Z"}],"missing":["idxxxx"]}}`))
+		}
+		users, missing, err := c.UserBatchGet(context.Background(), []string{"iddddd", "idxxxx"}, nil)
+		assert.Nil(t, err)
+		assert.EqualValues(t, []User{{
+			ID:        "iddddd",
+			Name:      "liuliu",
+			Email:     "aa@bb.com",
+			CreatedAt: time.Unix(1752999201, 0).UTC(),
+			UpdatedAt: time.Unix(1752999201, 0).UTC(),
+		}}, users)
+		assert.Equal(t, []string{"idxxxx"}, missing)
+	})
+	t.Run("batch delete", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/user/batch_delete", r.URL.Path)
+			assert.Equal(t, "id1,id2", r.PostFormValue("ids"))
+			assert.Equal(t, "true", r.PostFormValue("dry_run"))
+			w.Write([]byte(`{"data":{"dryRun":true,"affected":2}}`))
+		}
+		affected, err := c.UserBatchDelete(context.Background(), UserFilter{IDs: []string{"id1", "id2"}}, true)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, affected)
+	})
+	t.Run("batch update", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/user/batch_update", r.URL.Path)
+			assert.Equal(t, "liu", r.PostFormValue("name"))
+			assert.Equal(t, "0", r.PostFormValue("set_age"))
+			assert.Empty(t, r.PostForm["set_name"])
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"3 users matched, at most 2 are allowed"}`))
+		}
+		age := 0
+		_, err := c.UserBatchUpdate(context.Background(), UserFilter{Name: "liu"}, UserFields{Age: &age}, false)
+		assert.EqualError(t, err, "3 users matched, at most 2 are allowed")
+	})
+	t.Run("export", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/user/export", r.URL.Path)
+			assert.Equal(t, ExportFormatNDJSON, r.FormValue("format"))
+			assert.Equal(t, "id,email", r.FormValue("fields"))
+			assert.Equal(t, "liu", r.FormValue("name"))
+			assert.Equal(t, "2025-07-20T16:13:21.5+08:00", r.FormValue("created_after"))
+			w.Write([]byte(`{"id":"iddddd","email":"aa@bb.com"}` + "\n"))
+		}
+		var buf strings.Builder
+		err := c.UserExport(context.Background(), &buf, ExportOptions{
+			UserFilter: UserFilter{Name: "liu", CreatedAfter: time.Unix(1752999201, 5e8).In(time.FixedZone("CST", 8*3600))},
+			Format:     ExportFormatNDJSON,
+			Fields:     []string{"id", "email"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, `{"id":"iddddd","email":"aa@bb.com"}`+"\n", buf.String())
+	})
+	t.Run("export aborted", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"id":"iddddd","email":"aa@bb.com"}` + "\n"))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		var buf strings.Builder
+		err := c.UserExport(context.Background(), &buf, ExportOptions{Format: ExportFormatNDJSON})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+	t.Run("export error", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"param fields invalid: unknown field bogus"}`))
+		}
+		var buf strings.Builder
+		err := c.UserExport(context.Background(), &buf, ExportOptions{Fields: []string{"bogus"}})
+		assert.EqualError(t, err, "param fields invalid: unknown field bogus")
+		assert.Empty(t, buf.String())
+	})
+	t.Run("actor", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "admin", r.Header.Get(HeaderActor))
+		}
+		err := New(server.URL, WithActor("admin")).UserDelete("iddddd")
+		assert.Nil(t, err)
+	})
+	t.Run("watch", func(t *testing.T) {
+		var mu sync.Mutex
+		var lastEventIDs []string
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/user/watch", r.URL.Path)
+			assert.Equal(t, "user.created,user.deleted", r.FormValue("types"))
+			mu.Lock()
+			lastEventIDs = append(lastEventIDs, r.Header.Get(HeaderLastEventID))
+			mu.Unlock()
+			// each connection sends one event and breaks
+			switch r.Header.Get(HeaderLastEventID) {
+			case "":
+				w.Write([]byte("retry: 10\nid: 5\n\n: heartbeat\n\n"))
+				w.Write([]byte(`id: 6` + "\nevent: user.created\n" + `data: {"id":6,"type":"user.created","userId":"iddddd","data":{"id":"iddddd","name":"liuliu"}}` + "\n\n"))
+			case "6":
+				w.Write([]byte(`id: 9` + "\nevent: user.deleted\n" + `data: {"id":9,"type":"user.deleted",` + "\n" + `data: "userId":"iddddd","data":{"id":"iddddd"}}` + "\n\n"))
+				// the stream breaks in the middle of an event, which is not
+				// the last event then
+				w.Write([]byte(`id: 10` + "\nevent: user.created\n" + `data: {"id":10,`))
+			default:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := c.WatchUsers(ctx, EventUserCreated, EventUserDeleted)
+		assert.Nil(t, err)
+		event := <-events
+		assert.Equal(t, UserEvent{ID: 6, Type: EventUserCreated, UserID: "iddddd", User: User{ID: "iddddd", Name: "liuliu"}}, event)
+		event = <-events
+		assert.Equal(t, UserEvent{ID: 9, Type: EventUserDeleted, UserID: "iddddd", User: User{ID: "iddddd"}}, event)
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(lastEventIDs) >= 3
+		}, time.Second, 10*time.Millisecond)
+		cancel()
+		for range events {
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"", "6", "9"}, lastEventIDs[:3])
+	})
+	t.Run("watch error", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"param types invalid: unknown event user.renamed"}`))
+		}
+		_, err := c.WatchUsers(context.Background(), "user.renamed")
+		assert.EqualError(t, err, "param types invalid: unknown event user.renamed")
+	})
 	t.Run("list", func(t *testing.T) {
+		handleFunc = func(w http.ResponseWriter, r *http.Request) {
+		}
 		patches := gomonkey.NewPatches()
 		defer patches.Reset()
 		patches.ApplyMethodReturn(&http.Client{}, "Do", &http.Response{
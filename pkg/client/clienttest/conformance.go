@@ -0,0 +1,414 @@
+// Package clienttest has the conformance tests of client.Client
+// implementations, they are run against the fake client and the client of a
+// real service to prove the fake behaves like the service.
+package clienttest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/pkg/client"
+)
+
+// NewClient returns a client of an empty service for the test t.
+type NewClient func(t *testing.T) client.Client
+
+// Run runs the conformance tests, every test gets a new client.
+func Run(t *testing.T, newClient NewClient) {
+	for _, tc := range []struct {
+		name string
+		test func(t *testing.T, c client.Client)
+	}{
+		{name: "create and get", test: testCreateGet},
+		{name: "create invalid", test: testCreateInvalid},
+		{name: "get not found", test: testGetNotFound},
+		{name: "update", test: testUpdate},
+		{name: "delete", test: testDelete},
+		{name: "list", test: testList},
+		{name: "batch get", test: testBatchGet},
+		{name: "batch delete", test: testBatchDelete},
+		{name: "batch update", test: testBatchUpdate},
+		{name: "import", test: testImport},
+		{name: "export", test: testExport},
+		{name: "watch", test: testWatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newClient(t))
+		})
+	}
+}
+
+// AssertAPIError asserts err is an error response of the status code, and of
+// the message if it is not empty.
+func AssertAPIError(t *testing.T, err error, statusCode int, message string) {
+	t.Helper()
+	var apiErr *client.APIError
+	if !assert.True(t, errors.As(err, &apiErr), "error %v is not an APIError", err) {
+		return
+	}
+	assert.Equal(t, statusCode, apiErr.StatusCode, "status code of %v", err)
+	if message != "" {
+		assert.Equal(t, message, apiErr.Message)
+	}
+}
+
+func createUsers(t *testing.T, c client.Client, users ...client.User) []client.User {
+	t.Helper()
+	created := make([]client.User, 0, len(users))
+	for _, u := range users {
+		user, err := c.UserCreate(u)
+		require.NoError(t, err)
+		created = append(created, *user)
+	}
+	return created
+}
+
+func ids(users []client.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func testCreateGet(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	created, err := c.UserCreateContext(ctx, client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18})
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, "liuliu", created.Name)
+	assert.Equal(t, "aa@bb.com", created.Email)
+	assert.Equal(t, 18, created.Age)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.True(t, created.UpdatedAt.Equal(created.CreatedAt))
+
+	got, err := c.UserGetContext(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+
+	// the user is a copy, changing it does not change the service
+	got.Name = "changed"
+	got, err = c.UserGetContext(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "liuliu", got.Name)
+}
+
+func testCreateInvalid(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	_, err := c.UserCreateContext(ctx, client.User{Email: "aa@bb.com"})
+	AssertAPIError(t, err, http.StatusBadRequest, "param name not set")
+	_, err = c.UserCreateContext(ctx, client.User{Name: "liuliu"})
+	AssertAPIError(t, err, http.StatusBadRequest, "param email not set")
+	_, err = c.UserCreateContext(ctx, client.User{Name: "\xff", Email: "aa@bb.com"})
+	AssertAPIError(t, err, http.StatusBadRequest, "param name invalid: not utf-8")
+
+	createUsers(t, c, client.User{Name: "liuliu", Email: "aa@bb.com"})
+	_, err = c.UserCreateContext(ctx, client.User{Name: "zhangsan", Email: "aa@bb.com"})
+	AssertAPIError(t, err, http.StatusInternalServerError, "")
+	_, total, err := c.UserListContext(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+}
+
+func testGetNotFound(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	_, err := c.UserGetContext(ctx, "0198271f-bc9d-74ac-a63b-41cf2c6c2f82")
+	AssertAPIError(t, err, http.StatusInternalServerError, "record not found")
+	_, err = c.UserGetContext(ctx, "")
+	AssertAPIError(t, err, http.StatusBadRequest, "param id or email not set")
+}
+
+func testUpdate(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	users := createUsers(t, c,
+		client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18},
+		client.User{Name: "zhangsan", Email: "cc@dd.com", Age: 20},
+	)
+
+	require.NoError(t, c.UserUpdateContext(ctx, client.User{ID: users[0].ID, Email: "ee@ff.com", Age: 19}))
+	got, err := c.UserGetContext(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "liuliu", got.Name)
+	assert.Equal(t, "ee@ff.com", got.Email)
+	assert.Equal(t, 19, got.Age)
+	assert.False(t, got.UpdatedAt.Before(got.CreatedAt))
+
+	// the emails are unique
+	err = c.UserUpdateContext(ctx, client.User{ID: users[0].ID, Name: "lisi", Email: "cc@dd.com"})
+	AssertAPIError(t, err, http.StatusInternalServerError, "")
+	got, err = c.UserGetContext(ctx, users[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "liuliu", got.Name)
+	assert.Equal(t, "ee@ff.com", got.Email)
+
+	err = c.UserUpdateContext(ctx, client.User{ID: users[0].ID})
+	AssertAPIError(t, err, http.StatusBadRequest, "param name, email or age not set")
+	err = c.UserUpdateContext(ctx, client.User{Name: "lisi"})
+	AssertAPIError(t, err, http.StatusBadRequest, "param id not set")
+	err = c.UserUpdateContext(ctx, client.User{ID: users[0].ID, Email: "\xff@bb.com"})
+	AssertAPIError(t, err, http.StatusBadRequest, "param email invalid: not utf-8")
+	err = c.UserUpdateContext(ctx, client.User{ID: "0198271f-bc9d-74ac-a63b-41cf2c6c2f82", Name: "lisi"})
+	AssertAPIError(t, err, http.StatusInternalServerError, "record not found")
+}
+
+func testDelete(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	users := createUsers(t, c, client.User{Name: "liuliu", Email: "aa@bb.com"})
+
+	require.NoError(t, c.UserDeleteContext(ctx, users[0].ID))
+	_, err := c.UserGetContext(ctx, users[0].ID)
+	AssertAPIError(t, err, http.StatusInternalServerError, "record not found")
+	// deleting a missing user is a no-op
+	require.NoError(t, c.UserDeleteContext(ctx, users[0].ID))
+	err = c.UserDeleteContext(ctx, "")
+	AssertAPIError(t, err, http.StatusBadRequest, "param id not set")
+
+	// the users are soft deleted, the email of a deleted user stays taken
+	_, err = c.UserCreateContext(ctx, client.User{Name: "liuliu", Email: "aa@bb.com"})
+	AssertAPIError(t, err, http.StatusInternalServerError, "")
+}
+
+func testList(t *testing.T, c client.Client) {
+	users, total, err := c.UserList()
+	require.NoError(t, err)
+	assert.Empty(t, users)
+	assert.EqualValues(t, 0, total)
+
+	created := createUsers(t, c,
+		client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18},
+		client.User{Name: "zhangsan", Email: "cc@dd.com"},
+		client.User{Name: "lisi", Email: "ee@ff.com"},
+	)
+	users, total, err = c.UserList()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	assert.ElementsMatch(t, created, users)
+}
+
+func testBatchGet(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	users := createUsers(t, c,
+		client.User{Name: "liuliu", Email: "aa@bb.com"},
+		client.User{Name: "zhangsan", Email: "cc@dd.com"},
+	)
+	missingID := "0198271f-bc9d-74ac-a63b-41cf2c6c2f82"
+
+	got, missing, err := c.UserBatchGet(ctx, []string{users[1].ID, missingID, users[0].ID, users[1].ID}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []client.User{users[1], users[0]}, got)
+	assert.Equal(t, []string{missingID}, missing)
+
+	got, missing, err = c.UserBatchGet(ctx, nil, []string{"cc@dd.com", "xx@yy.com"})
+	require.NoError(t, err)
+	assert.Equal(t, []client.User{users[1]}, got)
+	assert.Equal(t, []string{"xx@yy.com"}, missing)
+
+	_, _, err = c.UserBatchGet(ctx, nil, nil)
+	AssertAPIError(t, err, http.StatusBadRequest, "param ids or emails not set")
+	_, _, err = c.UserBatchGet(ctx, []string{users[0].ID}, []string{"aa@bb.com"})
+	AssertAPIError(t, err, http.StatusBadRequest, "param ids and emails can not be set together")
+}
+
+func testBatchDelete(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	users := createUsers(t, c,
+		client.User{Name: "liuliu", Email: "aa@bb.com"},
+		client.User{Name: "liuliu2", Email: "cc@dd.com"},
+		client.User{Name: "zhangsan", Email: "ee@ff.com"},
+	)
+
+	_, err := c.UserBatchDelete(ctx, client.UserFilter{}, false)
+	AssertAPIError(t, err, http.StatusBadRequest, "param ids, name, email, created_after or created_before not set")
+
+	// the name matches users whose name contains it
+	affected, err := c.UserBatchDelete(ctx, client.UserFilter{Name: "liuliu"}, true)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+	_, total, err := c.UserListContext(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+
+	affected, err = c.UserBatchDelete(ctx, client.UserFilter{Name: "liuliu"}, false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+	left, total, err := c.UserListContext(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, []client.User{users[2]}, left)
+
+	affected, err = c.UserBatchDelete(ctx, client.UserFilter{IDs: ids(users[:2])}, false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, affected)
+}
+
+func testBatchUpdate(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	users := createUsers(t, c,
+		client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18},
+		client.User{Name: "zhangsan", Email: "cc@dd.com", Age: 20},
+	)
+
+	_, err := c.UserBatchUpdate(ctx, client.UserFilter{IDs: ids(users)}, client.UserFields{}, false)
+	AssertAPIError(t, err, http.StatusBadRequest, "param set_name or set_age not set")
+	age := 30
+	_, err = c.UserBatchUpdate(ctx, client.UserFilter{}, client.UserFields{Age: &age}, false)
+	AssertAPIError(t, err, http.StatusBadRequest, "param ids, name, email, created_after or created_before not set")
+
+	affected, err := c.UserBatchUpdate(ctx, client.UserFilter{Email: "cc@dd.com"}, client.UserFields{Age: &age}, false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, affected)
+	got, missing, err := c.UserBatchGet(ctx, ids(users), nil)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	require.Len(t, got, 2)
+	assert.Equal(t, 18, got[0].Age)
+	assert.Equal(t, 30, got[1].Age)
+	assert.Equal(t, "zhangsan", got[1].Name)
+}
+
+func testImport(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	createUsers(t, c, client.User{Name: "exists", Email: "cc@dd.com"})
+	input := "name,email,age\n" +
+		"liuliu,aa@bb.com,18\n" +
+		"liuliu2,aa@bb.com,20\n" +
+		"exists,cc@dd.com,\n" +
+		",ee@ff.com,1\n" +
+		"bad,gg@hh.com,x\n"
+	rows := []client.ImportRow{
+		{Line: 2, Email: "aa@bb.com", Status: client.ImportStatusCreated},
+		{Line: 3, Email: "aa@bb.com", Status: client.ImportStatusSkipped, Reason: "duplicate email in input"},
+		{Line: 4, Email: "cc@dd.com", Status: client.ImportStatusSkipped, Reason: "email already exists"},
+		{Line: 5, Email: "ee@ff.com", Status: client.ImportStatusFailed, Reason: "name not set"},
+		{Line: 6, Email: "gg@hh.com", Status: client.ImportStatusFailed, Reason: "age invalid: x"},
+	}
+
+	report, err := c.UserImport(ctx, strings.NewReader(input), client.ImportFormatCSV, true)
+	require.NoError(t, err)
+	assert.Equal(t, &client.ImportReport{DryRun: true, Created: 1, Skipped: 2, Failed: 2, Rows: rows}, report)
+	_, total, err := c.UserListContext(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+
+	report, err = c.UserImport(ctx, strings.NewReader(input), client.ImportFormatCSV, false)
+	require.NoError(t, err)
+	require.Len(t, report.Rows, len(rows))
+	created := report.Rows[0].ID
+	assert.NotEmpty(t, created)
+	report.Rows[0].ID = ""
+	assert.Equal(t, &client.ImportReport{Created: 1, Skipped: 2, Failed: 2, Rows: rows}, report)
+	user, err := c.UserGetContext(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, "liuliu", user.Name)
+	assert.Equal(t, 18, user.Age)
+
+	report, err = c.UserImport(ctx, strings.NewReader("{\"name\":\"lisi\",\"email\":\"ii@jj.com\"}\n{bad\n"), client.ImportFormatNDJSON, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+
+	report, err = c.UserImport(ctx, strings.NewReader("name,email\n\"a\"b,x@y.com\nlisi,kk@ll.com\n"), client.ImportFormatCSV, true)
+	require.NoError(t, err)
+	assert.Equal(t, &client.ImportReport{DryRun: true, Created: 1, Failed: 1, Rows: []client.ImportRow{
+		{Line: 2, Status: client.ImportStatusFailed, Reason: "invalid csv: extraneous or missing \" in quoted-field"},
+		{Line: 3, Email: "kk@ll.com", Status: client.ImportStatusCreated},
+	}}, report)
+
+	report, err = c.UserImport(ctx, strings.NewReader("name,email\n\xfe,kk@ll.com\nlisi,\xffk@ll.com\n"), client.ImportFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, &client.ImportReport{Failed: 2, Rows: []client.ImportRow{
+		{Line: 2, Email: "kk@ll.com", Status: client.ImportStatusFailed, Reason: "name or email not utf-8"},
+		{Line: 3, Email: "\uFFFDk@ll.com", Status: client.ImportStatusFailed, Reason: "name or email not utf-8"},
+	}}, report)
+
+	_, err = c.UserImport(ctx, strings.NewReader("name,age\nlisi,1\n"), client.ImportFormatCSV, false)
+	AssertAPIError(t, err, http.StatusBadRequest, "csv header must contain name and email")
+	_, err = c.UserImport(ctx, strings.NewReader(input), "xml", false)
+	assert.EqualError(t, err, "unsupported import format: xml")
+}
+
+func testExport(t *testing.T, c client.Client) {
+	ctx := context.Background()
+	users := createUsers(t, c,
+		client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18},
+		client.User{Name: "zhangsan", Email: "cc@dd.com", Age: 20},
+		client.User{Name: "lisi", Email: "ee@ff.com"},
+	)
+	// the users are exported in id order
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	fields := []string{"id", "name", "age"}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.UserExport(ctx, &buf, client.ExportOptions{Format: client.ExportFormatNDJSON, Fields: fields}))
+	var lines []string
+	var objects []string
+	for _, u := range users {
+		object := fmt.Sprintf(`{"id":%q,"name":%q,"age":%d}`, u.ID, u.Name, u.Age)
+		lines = append(lines, object)
+		objects = append(objects, object)
+	}
+	var got []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+	}
+	assert.Equal(t, lines, got)
+
+	buf.Reset()
+	require.NoError(t, c.UserExport(ctx, &buf, client.ExportOptions{Format: client.ExportFormatJSON, Fields: fields}))
+	assert.Equal(t, "["+strings.Join(objects, ",")+"]", buf.String())
+
+	buf.Reset()
+	filter := client.UserFilter{Name: "liu"}
+	require.NoError(t, c.UserExport(ctx, &buf, client.ExportOptions{UserFilter: filter, Fields: []string{"email", "name"}}))
+	assert.Equal(t, "email,name\naa@bb.com,liuliu\n", buf.String())
+
+	err := c.UserExport(ctx, &buf, client.ExportOptions{Fields: []string{"bogus"}})
+	AssertAPIError(t, err, http.StatusBadRequest, "param fields invalid: unknown field bogus")
+}
+
+func testWatch(t *testing.T, c client.Client) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := c.WatchUsers(ctx, "user.renamed")
+	AssertAPIError(t, err, http.StatusBadRequest, "param types invalid: unknown event user.renamed")
+
+	createUsers(t, c, client.User{Name: "before", Email: "xx@yy.com"})
+	events, err := c.WatchUsers(ctx, client.EventUserCreated, client.EventUserDeleted)
+	require.NoError(t, err)
+	users := createUsers(t, c, client.User{Name: "liuliu", Email: "aa@bb.com"})
+	require.NoError(t, c.UserUpdateContext(ctx, client.User{ID: users[0].ID, Age: 18}))
+	require.NoError(t, c.UserDeleteContext(ctx, users[0].ID))
+
+	var got []client.UserEvent
+	timeout := time.After(10 * time.Second)
+	for len(got) < 2 {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("got %d events, want 2", len(got))
+		}
+	}
+	assert.Equal(t, client.EventUserCreated, got[0].Type)
+	assert.Equal(t, users[0].ID, got[0].UserID)
+	assert.Equal(t, "liuliu", got[0].User.Name)
+	assert.Equal(t, client.EventUserDeleted, got[1].Type)
+	assert.Equal(t, users[0].ID, got[1].UserID)
+	assert.Equal(t, 18, got[1].User.Age)
+	assert.Less(t, got[0].ID, got[1].ID)
+}
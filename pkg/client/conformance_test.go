@@ -0,0 +1,28 @@
+package client_test
+
+import (
+	"testing"
+
+	"go-unittest-best-practice/pkg/client"
+	"go-unittest-best-practice/pkg/client/clienttest"
+	"go-unittest-best-practice/pkg/client/fake"
+	"go-unittest-best-practice/pkg/client/testserver"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("fake", func(t *testing.T) {
+		clienttest.Run(t, func(t *testing.T) client.Client {
+			return fake.NewClient()
+		})
+	})
+	t.Run("service", func(t *testing.T) {
+		clienttest.Run(t, func(t *testing.T) client.Client {
+			return testserver.NewClient(t)
+		})
+	})
+	t.Run("service memory", func(t *testing.T) {
+		clienttest.Run(t, func(t *testing.T) client.Client {
+			return testserver.NewClient(t)
+		})
+	})
+}
@@ -1,96 +1,820 @@
 package fake
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go-unittest-best-practice/pkg/client"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
-type fakeClient struct {
-	mu           sync.Mutex
-	users        map[string]*client.User
+// Client is an in memory client.Client for the tests of its consumers, it
+// keeps the users in maps and records the calls made to it.
+type Client struct {
+	mu    sync.Mutex
+	users map[string]*client.User
+	// usersByEmail are the ids of the emails, of deleted users too
 	usersByEmail map[string]string
+
+	newID  func() string
+	now    func() time.Time
+	calls  []Call
+	faults []*fault
+
+	// events are all the user events, watchers are notified of new events.
+	// restores counts the restores, which replace the events, and restoredLen
+	// is the number of events right after the last restore.
+	events      []client.UserEvent
+	watchers    map[chan struct{}]struct{}
+	restores    int
+	restoredLen int
+}
+
+var _ client.Client = &Client{}
+
+type Option func(c *Client)
+
+// WithUsers seeds the client with users, a user without id or creation time
+// gets one from the id generator and the clock. Seeding emits no events.
+func WithUsers(users ...client.User) Option {
+	return func(c *Client) {
+		for _, u := range users {
+			if u.ID == "" {
+				u.ID = c.newID()
+			}
+			if u.CreatedAt.IsZero() {
+				u.CreatedAt = c.now()
+			}
+			if u.UpdatedAt.IsZero() {
+				u.UpdatedAt = u.CreatedAt
+			}
+			c.put(u)
+		}
+	}
+}
+
+// WithIDGenerator sets the generator of the ids of created users, random
+// uuids by default.
+func WithIDGenerator(newID func() string) Option {
+	return func(c *Client) {
+		c.newID = newID
+	}
+}
+
+// WithClock sets the clock of the creation, update and event times, time.Now
+// by default.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+// SequentialIDs returns an id generator of prefix-1, prefix-2 and so on.
+func SequentialIDs(prefix string) func() string {
+	var mu sync.Mutex
+	n := 0
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return fmt.Sprintf("%s-%d", prefix, n)
+	}
+}
+
+// NewClient returns an empty client, the options are applied in order so
+// WithUsers uses the generators set before it.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		users:        make(map[string]*client.User),
+		usersByEmail: make(map[string]string),
+		newID:        uuid.NewString,
+		now:          time.Now,
+		watchers:     make(map[chan struct{}]struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// The errors are the ones of the server, an *client.APIError with the status
+// code and message of the response.
+var (
+	errNotFound = &client.APIError{StatusCode: http.StatusInternalServerError, Message: "record not found"}
+	// errExists stands for the unique constraint error of the database.
+	errExists = &client.APIError{StatusCode: http.StatusInternalServerError, Message: "user already exists"}
+)
+
+func paramErrorf(format string, args ...interface{}) error {
+	return &client.APIError{StatusCode: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func (c *Client) UserCreate(u client.User) (*client.User, error) {
+	return c.UserCreateContext(context.Background(), u)
+}
+
+func (c *Client) UserGet(id string) (*client.User, error) {
+	return c.UserGetContext(context.Background(), id)
+}
+
+func (c *Client) UserUpdate(u client.User) error {
+	return c.UserUpdateContext(context.Background(), u)
+}
+
+func (c *Client) UserDelete(id string) error {
+	return c.UserDeleteContext(context.Background(), id)
+}
+
+func (c *Client) UserList() ([]client.User, int64, error) {
+	return c.UserListContext(context.Background())
 }
 
-var _ client.Client = &fakeClient{}
+func (c *Client) UserCreateContext(ctx context.Context, u client.User) (*client.User, error) {
+	if err := c.call(ctx, "UserCreate", u); err != nil {
+		return nil, err
+	}
+	if u.Name == "" {
+		return nil, paramErrorf("param name not set")
+	}
+	if u.Email == "" {
+		return nil, paramErrorf("param email not set")
+	}
+	if err := checkUTF8(u.Name, u.Email); err != nil {
+		return nil, err
+	}
 
-func (c *fakeClient) UserCreate(u client.User) (*client.User, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	_, ok := c.usersByEmail[u.Email]
 	if ok {
-		return nil, fmt.Errorf("user already exists")
+		return nil, errExists
 	}
 
-	id := uuid.NewString()
-	now := time.Now()
-	c.users[id] = &client.User{
-		ID:        id,
+	now := c.now()
+	user := c.put(client.User{
+		ID:        c.newID(),
 		Name:      u.Name,
 		Email:     u.Email,
 		Age:       u.Age,
 		CreatedAt: now,
 		UpdatedAt: now,
+	})
+	c.emit(client.EventUserCreated, user)
+	created := *user
+	return &created, nil
+}
+
+// checkUTF8 rejects a name or email which is not utf-8 like the service.
+func checkUTF8(name, email string) error {
+	if !utf8.ValidString(name) {
+		return paramErrorf("param name invalid: not utf-8")
+	}
+	if !utf8.ValidString(email) {
+		return paramErrorf("param email invalid: not utf-8")
 	}
-	c.usersByEmail[u.Email] = id
-	return c.users[id], nil
+	return nil
 }
 
-func (c *fakeClient) UserGet(id string) (*client.User, error) {
+func (c *Client) UserGetContext(ctx context.Context, id string) (*client.User, error) {
+	if err := c.call(ctx, "UserGet", id); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, paramErrorf("param id or email not set")
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	user, ok := c.users[id]
 	if !ok {
-		return nil, fmt.Errorf("user already exists")
+		return nil, errNotFound
 	}
-	return user, nil
+	got := *user
+	return &got, nil
 }
 
-func (c *fakeClient) UserUpdate(u client.User) error {
+// UserUpdateContext updates the name, email and age of the user u.ID, zero value
+// fields are left unchanged.
+func (c *Client) UserUpdateContext(ctx context.Context, u client.User) error {
+	if err := c.call(ctx, "UserUpdate", u); err != nil {
+		return err
+	}
+	if u.ID == "" {
+		return paramErrorf("param id not set")
+	}
+	if u.Name == "" && u.Email == "" && u.Age == 0 {
+		return paramErrorf("param name, email or age not set")
+	}
+	if err := checkUTF8(u.Name, u.Email); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	user, ok := c.users[u.ID]
 	if !ok {
-		return fmt.Errorf("user already exists")
+		return errNotFound
 	}
-	user.Name = u.Name
+	if id, ok := c.usersByEmail[u.Email]; ok && id != u.ID {
+		return errExists
+	}
+	updated := *user
+	if u.Name != "" {
+		updated.Name = u.Name
+	}
+	if u.Email != "" {
+		updated.Email = u.Email
+	}
+	if u.Age != 0 {
+		updated.Age = u.Age
+	}
+	updated.UpdatedAt = c.now()
+	c.emit(client.EventUserUpdated, c.put(updated))
 	return nil
 }
 
-func (c *fakeClient) UserDelete(id string) error {
+func (c *Client) UserDeleteContext(ctx context.Context, id string) error {
+	if err := c.call(ctx, "UserDelete", id); err != nil {
+		return err
+	}
+	if id == "" {
+		return paramErrorf("param id not set")
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	user, ok := c.users[id]
 	if !ok {
-		return fmt.Errorf("user already exists")
+		// deleting a missing user is a no-op, like the server
+		return nil
 	}
-	delete(c.usersByEmail, user.Email)
+	// the users are soft deleted by the server, their emails stay taken
 	delete(c.users, id)
+	c.emit(client.EventUserDeleted, user)
 	return nil
 }
 
-func (c *fakeClient) UserList() ([]client.User, int64, error) {
+// listPageSize is the page size of the server, UserList gets the first page.
+const listPageSize = 100
+
+func (c *Client) UserListContext(ctx context.Context) ([]client.User, int64, error) {
+	if err := c.call(ctx, "UserList"); err != nil {
+		return nil, 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	users := c.sortedUsers()
+	total := int64(len(users))
+	if len(users) > listPageSize {
+		users = users[:listPageSize]
+	}
+	return users, total, nil
+}
+
+func (c *Client) UserBatchGet(ctx context.Context, ids, emails []string) ([]client.User, []string, error) {
+	if err := c.call(ctx, "UserBatchGet", ids, emails); err != nil {
+		return nil, nil, err
+	}
+	ids, emails = cleanList(ids), cleanList(emails)
+	if len(ids) == 0 && len(emails) == 0 {
+		return nil, nil, paramErrorf("param ids or emails not set")
+	}
+	if len(ids) > 0 && len(emails) > 0 {
+		return nil, nil, paramErrorf("param ids and emails can not be set together")
+	}
+	keys := append(ids, emails...)
+	if len(keys) > maxBatchSize {
+		return nil, nil, paramErrorf("too many keys, at most %d are allowed", maxBatchSize)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	users := []client.User{}
+	missing := []string{}
+	for _, key := range keys {
+		id := key
+		if len(emails) > 0 {
+			id = c.usersByEmail[key]
+		}
+		if u, ok := c.users[id]; ok {
+			users = append(users, *u)
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return users, missing, nil
+}
+
+// cleanList drops the empty and duplicated values of list like the server
+// does with list params.
+func cleanList(list []string) []string {
+	var cleaned []string
+	seen := make(map[string]struct{})
+	for _, v := range list {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		cleaned = append(cleaned, v)
+	}
+	return cleaned
+}
+
+// maxBatchSize is the default batch limit of the server.
+const maxBatchSize = 1000
+
+func (c *Client) UserBatchDelete(ctx context.Context, filter client.UserFilter, dryRun bool) (int64, error) {
+	if err := c.call(ctx, "UserBatchDelete", filter, dryRun); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matched, err := c.matchBatch(filter)
+	if err != nil || dryRun {
+		return int64(len(matched)), err
+	}
+	for _, u := range matched {
+		delete(c.users, u.ID)
+		c.emit(client.EventUserDeleted, u)
+	}
+	return int64(len(matched)), nil
+}
+
+func (c *Client) UserBatchUpdate(ctx context.Context, filter client.UserFilter, fields client.UserFields, dryRun bool) (int64, error) {
+	if err := c.call(ctx, "UserBatchUpdate", filter, fields, dryRun); err != nil {
+		return 0, err
+	}
+	if filterIsZero(filter) {
+		return 0, errZeroFilter
+	}
+	if fields.Name == nil && fields.Age == nil {
+		return 0, paramErrorf("param set_name or set_age not set")
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	total := len(c.users)
-	users := make([]client.User, 0, total)
+	matched, err := c.matchBatch(filter)
+	if err != nil || dryRun {
+		return int64(len(matched)), err
+	}
+	now := c.now()
+	for _, u := range matched {
+		if fields.Name != nil {
+			u.Name = *fields.Name
+		}
+		if fields.Age != nil {
+			u.Age = *fields.Age
+		}
+		u.UpdatedAt = now
+		c.emit(client.EventUserUpdated, u)
+	}
+	return int64(len(matched)), nil
+}
+
+var errZeroFilter = paramErrorf("param ids, name, email, created_after or created_before not set")
+
+func filterIsZero(f client.UserFilter) bool {
+	return len(cleanList(f.IDs)) == 0 && f.Name == "" && f.Email == "" && f.CreatedAfter.IsZero() && f.CreatedBefore.IsZero()
+}
+
+// matchBatch returns the users matching filter in id order, it must be called
+// with c.mu held.
+func (c *Client) matchBatch(filter client.UserFilter) ([]*client.User, error) {
+	if filterIsZero(filter) {
+		return nil, errZeroFilter
+	}
+	matched := c.match(filter)
+	if len(matched) > maxBatchSize {
+		return nil, paramErrorf("%d users matched, at most %d are allowed", len(matched), maxBatchSize)
+	}
+	return matched, nil
+}
+
+// match returns the users matching filter in id order, it must be called with
+// c.mu held.
+func (c *Client) match(filter client.UserFilter) []*client.User {
+	var matched []*client.User
 	for _, u := range c.users {
-		users = append(users, client.User{
-			ID:        u.ID,
-			Name:      u.Name,
-			Email:     u.Email,
-			Age:       u.Age,
-			CreatedAt: u.CreatedAt,
-			UpdatedAt: u.UpdatedAt,
-		})
-	}
-	return users, int64(total), nil
-}
\ No newline at end of file
+		if matchFilter(u, filter) {
+			matched = append(matched, u)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ID < matched[j].ID
+	})
+	return matched
+}
+
+// matchFilter matches like the server on sqlite, the name matches
+// case-insensitively.
+func matchFilter(u *client.User, f client.UserFilter) bool {
+	if ids := cleanList(f.IDs); len(ids) > 0 && !contains(ids, u.ID) {
+		return false
+	}
+	return (f.Name == "" || strings.Contains(strings.ToLower(u.Name), strings.ToLower(f.Name))) &&
+		(f.Email == "" || u.Email == f.Email) &&
+		(f.CreatedAfter.IsZero() || !u.CreatedAt.Before(f.CreatedAfter)) &&
+		(f.CreatedBefore.IsZero() || u.CreatedAt.Before(f.CreatedBefore))
+}
+
+func (c *Client) UserImport(ctx context.Context, r io.Reader, format string, dryRun bool) (*client.ImportReport, error) {
+	if err := c.call(ctx, "UserImport", r, format, dryRun); err != nil {
+		return nil, err
+	}
+	var records []importRecord
+	var err error
+	switch format {
+	case client.ImportFormatCSV:
+		records, err = readCSV(r)
+	case client.ImportFormatNDJSON:
+		records, err = readNDJSON(r)
+	default:
+		// the client does not send a request of an unknown format
+		err = fmt.Errorf("unsupported import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := &client.ImportReport{DryRun: dryRun, Rows: []client.ImportRow{}}
+	seen := make(map[string]struct{})
+	for _, rec := range records {
+		// the service reports the email as its json response encodes it
+		row := client.ImportRow{Line: rec.line, Email: strings.ToValidUTF8(rec.Email, "\uFFFD")}
+		id, taken := c.usersByEmail[rec.Email]
+		_, exists := c.users[id]
+		_, duplicate := seen[rec.Email]
+		switch {
+		case rec.invalid != "":
+			row.Status, row.Reason = client.ImportStatusFailed, rec.invalid
+		case rec.Name == "":
+			row.Status, row.Reason = client.ImportStatusFailed, "name not set"
+		case rec.Email == "":
+			row.Status, row.Reason = client.ImportStatusFailed, "email not set"
+		case rec.Age < 0:
+			row.Status, row.Reason = client.ImportStatusFailed, fmt.Sprintf("age invalid: %d", rec.Age)
+		case !utf8.ValidString(rec.Name) || !utf8.ValidString(rec.Email):
+			row.Status, row.Reason = client.ImportStatusFailed, "name or email not utf-8"
+		case duplicate:
+			row.Status, row.Reason = client.ImportStatusSkipped, "duplicate email in input"
+		case exists:
+			row.Status, row.Reason = client.ImportStatusSkipped, "email already exists"
+		case taken:
+			row.Status, row.Reason = client.ImportStatusFailed, errExists.Message
+		default:
+			row.Status = client.ImportStatusCreated
+		}
+		if row.Status != client.ImportStatusFailed {
+			seen[rec.Email] = struct{}{}
+		}
+
+		switch row.Status {
+		case client.ImportStatusFailed:
+			report.Failed++
+		case client.ImportStatusSkipped:
+			report.Skipped++
+		case client.ImportStatusCreated:
+			report.Created++
+			if !dryRun {
+				now := c.now()
+				user := c.put(client.User{
+					ID:        c.newID(),
+					Name:      rec.Name,
+					Email:     rec.Email,
+					Age:       rec.Age,
+					CreatedAt: now,
+					UpdatedAt: now,
+				})
+				c.emit(client.EventUserCreated, user)
+				row.ID = user.ID
+			}
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+type importRecord struct {
+	line int
+	// invalid is why the row can not be read
+	invalid string
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Age     int    `json:"age"`
+}
+
+func readCSV(r io.Reader) ([]importRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, paramErrorf("read csv header failed: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasName := columns["name"]
+	_, hasEmail := columns["email"]
+	if !hasName || !hasEmail {
+		return nil, paramErrorf("csv header must contain name and email")
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []importRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, paramErrorf("read csv failed: %v", err)
+			}
+			records = append(records, importRecord{line: parseErr.Line, invalid: fmt.Sprintf("invalid csv: %v", parseErr.Err)})
+			continue
+		}
+		// the position is only known for a record which is read
+		line, _ := reader.FieldPos(0)
+		rec := importRecord{
+			line:  line,
+			Name:  field(row, "name"),
+			Email: field(row, "email"),
+		}
+		if age := field(row, "age"); age != "" {
+			if rec.Age, err = strconv.Atoi(age); err != nil {
+				rec.invalid = fmt.Sprintf("age invalid: %s", age)
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+func readNDJSON(r io.Reader) ([]importRecord, error) {
+	var records []importRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+		rec := importRecord{line: line}
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			rec = importRecord{line: line, invalid: fmt.Sprintf("invalid json: %v", err)}
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, paramErrorf("read ndjson failed: %v", err)
+	}
+	return records, nil
+}
+
+// exportFields are the fields of an exported user in the default order.
+var exportFields = []string{"id", "name", "email", "age", "createdAt", "updatedAt"}
+
+// UserExport writes the users in id order the same way as the server.
+func (c *Client) UserExport(ctx context.Context, w io.Writer, opts client.ExportOptions) error {
+	if err := c.call(ctx, "UserExport", w, opts); err != nil {
+		return err
+	}
+	format := opts.Format
+	if format == "" {
+		format = client.ExportFormatCSV
+	}
+	if format != client.ExportFormatCSV && format != client.ExportFormatNDJSON && format != client.ExportFormatJSON {
+		return paramErrorf("param format must be csv, ndjson or json")
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = exportFields
+	}
+	for _, f := range fields {
+		if !contains(exportFields, f) {
+			return paramErrorf("param fields invalid: unknown field %s", f)
+		}
+	}
+
+	c.mu.Lock()
+	var users []client.User
+	for _, u := range c.match(opts.UserFilter) {
+		users = append(users, *u)
+	}
+	c.mu.Unlock()
+
+	if opts.Gzip {
+		gw := gzip.NewWriter(w)
+		defer gw.Close()
+		w = gw
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	values := func(u *client.User) []interface{} {
+		all := map[string]interface{}{
+			"id":        u.ID,
+			"name":      u.Name,
+			"email":     u.Email,
+			"age":       u.Age,
+			"createdAt": u.CreatedAt,
+			"updatedAt": u.UpdatedAt,
+		}
+		values := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			values = append(values, all[f])
+		}
+		return values
+	}
+	if format == client.ExportFormatCSV {
+		cw := csv.NewWriter(bw)
+		cw.Write(fields)
+		for i := range users {
+			row := make([]string, 0, len(fields))
+			for _, v := range values(&users[i]) {
+				if t, ok := v.(time.Time); ok {
+					row = append(row, t.Format(time.RFC3339Nano))
+					continue
+				}
+				row = append(row, fmt.Sprint(v))
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	if format == client.ExportFormatJSON {
+		bw.WriteByte('[')
+	}
+	for i := range users {
+		if format == client.ExportFormatJSON && i > 0 {
+			bw.WriteByte(',')
+		}
+		// write the object by hand to keep the order of the fields
+		bw.WriteByte('{')
+		for j, v := range values(&users[i]) {
+			if j > 0 {
+				bw.WriteByte(',')
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(bw, "%q:", fields[j])
+			bw.Write(data)
+		}
+		bw.WriteByte('}')
+		if format == client.ExportFormatNDJSON {
+			bw.WriteByte('\n')
+		}
+	}
+	if format == client.ExportFormatJSON {
+		bw.WriteByte(']')
+	}
+	return nil
+}
+
+func (c *Client) WatchUsers(ctx context.Context, types ...string) (<-chan client.UserEvent, error) {
+	if err := c.call(ctx, "WatchUsers", types); err != nil {
+		return nil, err
+	}
+	for _, t := range types {
+		if t != client.EventUserCreated && t != client.EventUserUpdated && t != client.EventUserDeleted {
+			return nil, paramErrorf("param types invalid: unknown event %s", t)
+		}
+	}
+
+	c.mu.Lock()
+	next, restores := len(c.events), c.restores
+	notify := make(chan struct{}, 1)
+	c.watchers[notify] = struct{}{}
+	c.mu.Unlock()
+
+	events := make(chan client.UserEvent)
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.watchers, notify)
+			c.mu.Unlock()
+			close(events)
+		}()
+		for {
+			c.mu.Lock()
+			// the events are replaced by a restore, stream the events after it
+			if restores != c.restores {
+				next, restores = c.restoredLen, c.restores
+			}
+			pending := c.events[next:]
+			next = len(c.events)
+			c.mu.Unlock()
+			for _, e := range pending {
+				if len(types) > 0 && !contains(types, e.Type) {
+					continue
+				}
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// emit records an event of u, it must be called with c.mu held.
+func (c *Client) emit(eventType string, u *client.User) {
+	c.events = append(c.events, client.UserEvent{
+		ID:         int64(len(c.events) + 1),
+		Type:       eventType,
+		UserID:     u.ID,
+		OccurredAt: c.now(),
+		User:       *u,
+	})
+	for notify := range c.watchers {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// put adds or replaces the user u, it must be called with c.mu held.
+func (c *Client) put(u client.User) *client.User {
+	if old, ok := c.users[u.ID]; ok {
+		delete(c.usersByEmail, old.Email)
+	}
+	user := &u
+	c.users[u.ID] = user
+	c.usersByEmail[u.Email] = u.ID
+	return user
+}
+
+// sortedUsers returns copies of the users in creation order, it must be called
+// with c.mu held.
+func (c *Client) sortedUsers() []client.User {
+	users := make([]client.User, 0, len(c.users))
+	for _, u := range c.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package fake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/pkg/client"
+)
+
+func TestClient(t *testing.T) {
+	now := time.Unix(1752999201, 0)
+	c := NewClient(
+		WithIDGenerator(SequentialIDs("user")),
+		WithClock(func() time.Time { return now }),
+		WithUsers(client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18}),
+	)
+	ctx := context.Background()
+
+	t.Run("seed", func(t *testing.T) {
+		assert.Equal(t, []client.User{
+			{ID: "user-1", Name: "liuliu", Email: "aa@bb.com", Age: 18, CreatedAt: now, UpdatedAt: now},
+		}, c.Users())
+		assert.Empty(t, c.Events())
+	})
+	t.Run("create", func(t *testing.T) {
+		user, err := c.UserCreateContext(ctx, client.User{Name: "zhangsan", Email: "cc@dd.com"})
+		require.NoError(t, err)
+		assert.Equal(t, &client.User{ID: "user-2", Name: "zhangsan", Email: "cc@dd.com", CreatedAt: now, UpdatedAt: now}, user)
+
+		_, err = c.UserCreateContext(ctx, client.User{Name: "lisi", Email: "aa@bb.com"})
+		assert.EqualError(t, err, "user already exists")
+		_, err = c.UserCreateContext(ctx, client.User{Email: "ee@ff.com"})
+		assert.EqualError(t, err, "param name not set")
+	})
+	t.Run("get", func(t *testing.T) {
+		user, err := c.UserGetContext(ctx, "user-1")
+		require.NoError(t, err)
+		user.Name = "changed"
+		user, err = c.UserGetContext(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "liuliu", user.Name)
+
+		_, err = c.UserGetContext(ctx, "user-9")
+		assert.EqualError(t, err, "record not found")
+	})
+	t.Run("update", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		require.NoError(t, c.UserUpdateContext(ctx, client.User{ID: "user-2", Email: "gg@hh.com", Age: 20}))
+		user, err := c.UserGetContext(ctx, "user-2")
+		require.NoError(t, err)
+		assert.Equal(t, &client.User{ID: "user-2", Name: "zhangsan", Email: "gg@hh.com", Age: 20, CreatedAt: now.Add(-time.Minute), UpdatedAt: now}, user)
+
+		users, _, err := c.UserBatchGet(ctx, nil, []string{"gg@hh.com", "cc@dd.com"})
+		require.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.EqualError(t, c.UserUpdateContext(ctx, client.User{ID: "user-2"}), "param name, email or age not set")
+	})
+	t.Run("calls", func(t *testing.T) {
+		assert.Len(t, c.Calls(), 10)
+		assert.Equal(t, []Call{
+			{Method: "UserUpdate", Args: []interface{}{client.User{ID: "user-2", Email: "gg@hh.com", Age: 20}}},
+			{Method: "UserUpdate", Args: []interface{}{client.User{ID: "user-2"}}},
+		}, c.CallsOf("UserUpdate"))
+		c.ResetCalls()
+		assert.Empty(t, c.Calls())
+	})
+	t.Run("snapshot", func(t *testing.T) {
+		snapshot := c.Snapshot()
+		users := c.Users()
+		events := c.Events()
+		require.Len(t, events, 2)
+
+		require.NoError(t, c.UserDeleteContext(ctx, "user-1"))
+		_, err := c.UserCreateContext(ctx, client.User{Name: "wangwu", Email: "ii@jj.com"})
+		require.NoError(t, err)
+		assert.Len(t, c.Events(), 4)
+
+		c.Restore(snapshot)
+		assert.Equal(t, users, c.Users())
+		assert.Equal(t, events, c.Events())
+		_, err = c.UserCreateContext(ctx, client.User{Name: "wangwu", Email: "aa@bb.com"})
+		assert.EqualError(t, err, "user already exists")
+		_, err = c.UserCreateContext(ctx, client.User{Name: "wangwu", Email: "ii@jj.com"})
+		assert.NoError(t, err)
+	})
+	t.Run("watch after restore", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		snapshot := c.Snapshot()
+		require.NoError(t, c.UserDeleteContext(ctx, "user-1"))
+
+		watch, err := c.WatchUsers(ctx)
+		require.NoError(t, err)
+		c.Restore(snapshot)
+		require.NoError(t, c.UserDeleteContext(ctx, "user-2"))
+
+		select {
+		case e := <-watch:
+			assert.Equal(t, client.EventUserDeleted, e.Type)
+			assert.Equal(t, "user-2", e.UserID)
+			assert.EqualValues(t, 4, e.ID)
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	})
+}
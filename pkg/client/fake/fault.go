@@ -0,0 +1,125 @@
+package fake
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go-unittest-best-practice/pkg/client"
+)
+
+// Fault is a programmed failure of the calls of a client method.
+type Fault struct {
+	// Method is the name of the method, e.g. UserGet, empty matches all the
+	// methods.
+	Method string
+	// Nth matches only the n-th call of the method after the fault is added,
+	// counted from 1. 0 matches every call.
+	Nth int
+	// Times is the number of calls the fault fails at most, 0 is unlimited.
+	Times int
+	// Delay is added to the call before it runs or fails, the call returns
+	// the error of its context if the context is done first.
+	Delay time.Duration
+	// Err is returned by the call, the call runs after Delay if Err is nil.
+	Err error
+}
+
+type fault struct {
+	Fault
+	// calls is the number of calls of the method, fired is the number of calls
+	// the fault matched.
+	calls int
+	fired int
+}
+
+// match counts a call of method and reports whether the fault matches it, it
+// must be called with c.mu held.
+func (f *fault) match(method string) bool {
+	if f.Method != "" && f.Method != method {
+		return false
+	}
+	f.calls++
+	if f.Nth > 0 && f.calls != f.Nth {
+		return false
+	}
+	if f.Times > 0 && f.fired >= f.Times {
+		return false
+	}
+	f.fired++
+	return true
+}
+
+// WithFaults adds faults to the client.
+func WithFaults(faults ...Fault) Option {
+	return func(c *Client) {
+		for _, f := range faults {
+			c.faults = append(c.faults, &fault{Fault: f})
+		}
+	}
+}
+
+// AddFault adds a fault, the faults matching a call add up their delays and
+// the first matching error is returned.
+func (c *Client) AddFault(f Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, &fault{Fault: f})
+}
+
+// ClearFaults removes the faults, the calls succeed again.
+func (c *Client) ClearFaults() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = nil
+}
+
+// FailWith returns a fault failing every call of method with err.
+func FailWith(method string, err error) Fault {
+	return Fault{Method: method, Err: err}
+}
+
+// FailNth returns a fault failing the n-th call of method with err.
+func FailNth(method string, n int, err error) Fault {
+	return Fault{Method: method, Nth: n, Err: err}
+}
+
+// Latency returns a fault delaying every call of method by d.
+func Latency(method string, d time.Duration) Fault {
+	return Fault{Method: method, Delay: d}
+}
+
+// StatusError returns the error of a response of the server with the status
+// code, its message is the status text.
+func StatusError(statusCode int) error {
+	return &client.APIError{StatusCode: statusCode, Message: http.StatusText(statusCode)}
+}
+
+// call records a call of method and applies the faults matching it.
+func (c *Client) call(ctx context.Context, method string, args ...interface{}) error {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+	var delay time.Duration
+	var err error
+	for _, f := range c.faults {
+		if !f.match(method) {
+			continue
+		}
+		delay += f.Delay
+		if err == nil {
+			err = f.Err
+		}
+	}
+	c.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/pkg/client"
+)
+
+func TestFault(t *testing.T) {
+	ctx := context.Background()
+	user := client.User{ID: "user-1", Name: "liuliu", Email: "aa@bb.com"}
+
+	t.Run("fail with", func(t *testing.T) {
+		errDown := errors.New("connection refused")
+		c := NewClient(WithUsers(user), WithFaults(FailWith("UserGet", errDown)))
+		_, err := c.UserGetContext(ctx, "user-1")
+		assert.ErrorIs(t, err, errDown)
+		_, _, err = c.UserListContext(ctx)
+		assert.NoError(t, err)
+
+		c.ClearFaults()
+		_, err = c.UserGetContext(ctx, "user-1")
+		assert.NoError(t, err)
+		assert.Len(t, c.CallsOf("UserGet"), 2)
+	})
+	t.Run("fail nth", func(t *testing.T) {
+		c := NewClient(WithUsers(user))
+		c.AddFault(FailNth("UserGet", 2, StatusError(http.StatusServiceUnavailable)))
+		for i, status := range []int{0, http.StatusServiceUnavailable, 0} {
+			_, err := c.UserGetContext(ctx, "user-1")
+			if status == 0 {
+				assert.NoError(t, err, "call %d", i+1)
+				continue
+			}
+			var apiErr *client.APIError
+			require.ErrorAs(t, err, &apiErr, "call %d", i+1)
+			assert.Equal(t, status, apiErr.StatusCode)
+			assert.EqualError(t, err, "Service Unavailable")
+		}
+	})
+	t.Run("times", func(t *testing.T) {
+		c := NewClient()
+		c.AddFault(Fault{Err: StatusError(http.StatusInternalServerError), Times: 2})
+		_, _, err := c.UserListContext(ctx)
+		assert.Error(t, err)
+		err = c.UserDeleteContext(ctx, "user-1")
+		assert.Error(t, err)
+		_, _, err = c.UserListContext(ctx)
+		assert.NoError(t, err)
+	})
+	t.Run("latency", func(t *testing.T) {
+		c := NewClient(WithUsers(user), WithFaults(Latency("UserGet", 20*time.Millisecond)))
+		start := time.Now()
+		_, err := c.UserGetContext(ctx, "user-1")
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		c.AddFault(Latency("UserGet", time.Hour))
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err = c.UserGetContext(timeoutCtx, "user-1")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+	t.Run("latency then error", func(t *testing.T) {
+		c := NewClient(WithFaults(Fault{Method: "UserCreate", Delay: time.Millisecond, Err: StatusError(http.StatusBadGateway)}))
+		_, err := c.UserCreateContext(ctx, user)
+		assert.Equal(t, StatusError(http.StatusBadGateway), err)
+		assert.Empty(t, c.Users())
+	})
+}
@@ -0,0 +1,94 @@
+package fake
+
+import (
+	"go-unittest-best-practice/pkg/client"
+)
+
+// Call is a call made to the client, Args are its arguments but the context.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Calls returns the calls made to the client in order.
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// CallsOf returns the calls made to the method of the client in order.
+func (c *Client) CallsOf(method string) []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var calls []Call
+	for _, call := range c.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the recorded calls.
+func (c *Client) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+// Users returns copies of the users in creation order.
+func (c *Client) Users() []client.User {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sortedUsers()
+}
+
+// Events returns the user events emitted so far.
+func (c *Client) Events() []client.UserEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]client.UserEvent(nil), c.events...)
+}
+
+// Snapshot is a copy of the users and events of a client.
+type Snapshot struct {
+	users        []client.User
+	usersByEmail map[string]string
+	events       []client.UserEvent
+}
+
+// Snapshot copies the users and events, later changes of the client do not
+// change the snapshot.
+func (c *Client) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	usersByEmail := make(map[string]string, len(c.usersByEmail))
+	for email, id := range c.usersByEmail {
+		usersByEmail[email] = id
+	}
+	return &Snapshot{
+		users:        c.sortedUsers(),
+		usersByEmail: usersByEmail,
+		events:       append([]client.UserEvent(nil), c.events...),
+	}
+}
+
+// Restore replaces the users and events with the ones of the snapshot, the
+// recorded calls are kept. A running watcher streams the events emitted after
+// the restore, but not the restored events.
+func (c *Client) Restore(s *Snapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users = make(map[string]*client.User, len(s.users))
+	c.usersByEmail = make(map[string]string, len(s.usersByEmail))
+	for email, id := range s.usersByEmail {
+		c.usersByEmail[email] = id
+	}
+	for _, u := range s.users {
+		c.put(u)
+	}
+	c.events = append([]client.UserEvent(nil), s.events...)
+	c.restores++
+	c.restoredLen = len(c.events)
+}
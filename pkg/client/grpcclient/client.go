@@ -0,0 +1,185 @@
+// Package grpcclient is the client.Client of the gRPC API. The gRPC API has
+// the create, get, update, delete and list operations, the batch, import,
+// export and watch operations return ErrUnsupported.
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go-unittest-best-practice/pkg/client"
+	"go-unittest-best-practice/pkg/userpb"
+)
+
+// ErrUnsupported is returned by the operations the gRPC API does not have.
+var ErrUnsupported = errors.New("not supported by the gRPC API")
+
+// metadataActor is the metadata of the actor of the changes.
+const metadataActor = "x-actor"
+
+type Option func(c *grpcClient)
+
+// WithActor sets the actor sent with every call, it is recorded in the audit
+// log of the changes made by the calls.
+func WithActor(actor string) Option {
+	return func(c *grpcClient) {
+		c.actor = actor
+	}
+}
+
+// New returns a client calling the user service on conn.
+func New(conn grpc.ClientConnInterface, opts ...Option) client.Client {
+	c := &grpcClient{users: userpb.NewUserServiceClient(conn)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+var _ client.Client = &grpcClient{}
+
+type grpcClient struct {
+	users userpb.UserServiceClient
+	actor string
+}
+
+func (c *grpcClient) UserCreate(u client.User) (*client.User, error) {
+	return c.UserCreateContext(context.Background(), u)
+}
+
+func (c *grpcClient) UserGet(id string) (*client.User, error) {
+	return c.UserGetContext(context.Background(), id)
+}
+
+func (c *grpcClient) UserUpdate(u client.User) error {
+	return c.UserUpdateContext(context.Background(), u)
+}
+
+func (c *grpcClient) UserDelete(id string) error {
+	return c.UserDeleteContext(context.Background(), id)
+}
+
+func (c *grpcClient) UserList() ([]client.User, int64, error) {
+	return c.UserListContext(context.Background())
+}
+
+func (c *grpcClient) UserCreateContext(ctx context.Context, u client.User) (*client.User, error) {
+	user, err := c.users.CreateUser(c.context(ctx), &userpb.CreateUserRequest{
+		Name:  u.Name,
+		Email: u.Email,
+		Age:   int32(u.Age),
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return convertUser(user), nil
+}
+
+func (c *grpcClient) UserGetContext(ctx context.Context, id string) (*client.User, error) {
+	user, err := c.users.GetUser(c.context(ctx), &userpb.GetUserRequest{Id: id})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return convertUser(user), nil
+}
+
+// UserUpdateContext updates the name, email and age of the user u.ID, zero value
+// fields are left unchanged.
+func (c *grpcClient) UserUpdateContext(ctx context.Context, u client.User) error {
+	req := &userpb.UpdateUserRequest{Id: u.ID, Name: u.Name, Email: u.Email}
+	if u.Age != 0 {
+		age := int32(u.Age)
+		req.Age = &age
+	}
+	_, err := c.users.UpdateUser(c.context(ctx), req)
+	return statusError(err)
+}
+
+func (c *grpcClient) UserDeleteContext(ctx context.Context, id string) error {
+	_, err := c.users.DeleteUser(c.context(ctx), &userpb.DeleteUserRequest{Id: id})
+	return statusError(err)
+}
+
+// UserListContext lists the first page of the users, the same as the HTTP client.
+func (c *grpcClient) UserListContext(ctx context.Context) ([]client.User, int64, error) {
+	resp, err := c.users.ListUsers(c.context(ctx), &userpb.ListUsersRequest{})
+	if err != nil {
+		return nil, 0, statusError(err)
+	}
+	users := make([]client.User, 0, len(resp.GetUsers()))
+	for _, u := range resp.GetUsers() {
+		users = append(users, *convertUser(u))
+	}
+	return users, resp.GetTotal(), nil
+}
+
+func (c *grpcClient) UserBatchGet(ctx context.Context, ids, emails []string) ([]client.User, []string, error) {
+	return nil, nil, ErrUnsupported
+}
+
+func (c *grpcClient) UserBatchDelete(ctx context.Context, filter client.UserFilter, dryRun bool) (int64, error) {
+	return 0, ErrUnsupported
+}
+
+func (c *grpcClient) UserBatchUpdate(ctx context.Context, filter client.UserFilter, fields client.UserFields, dryRun bool) (int64, error) {
+	return 0, ErrUnsupported
+}
+
+func (c *grpcClient) UserImport(ctx context.Context, r io.Reader, format string, dryRun bool) (*client.ImportReport, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *grpcClient) UserExport(ctx context.Context, w io.Writer, opts client.ExportOptions) error {
+	return ErrUnsupported
+}
+
+func (c *grpcClient) WatchUsers(ctx context.Context, types ...string) (<-chan client.UserEvent, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *grpcClient) context(ctx context.Context) context.Context {
+	if c.actor == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, metadataActor, c.actor)
+}
+
+// statusError returns an error of the status message, the same error as the
+// HTTP client returns, status.FromError still returns the status of it.
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if s, ok := status.FromError(err); ok {
+		return &callError{status: s}
+	}
+	return err
+}
+
+type callError struct {
+	status *status.Status
+}
+
+func (e *callError) Error() string {
+	return e.status.Message()
+}
+
+func (e *callError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+func convertUser(u *userpb.User) *client.User {
+	return &client.User{
+		ID:        u.GetId(),
+		Name:      u.GetName(),
+		Email:     u.GetEmail(),
+		Age:       int(u.GetAge()),
+		CreatedAt: u.GetCreatedAt().AsTime().Local(),
+		UpdatedAt: u.GetUpdatedAt().AsTime().Local(),
+	}
+}
@@ -0,0 +1,238 @@
+// Package httprecord records the http requests of a test and their responses
+// to a golden file, and replays them in later runs without the server:
+//
+//	rec := httprecord.New(t, "testdata/users.json", httprecord.Replay)
+//	c := client.New(server, client.WithHTTPClient(rec.Client()))
+//
+// The golden file is recorded against a real server with mode Record. The
+// requests are matched by method, path, query and body in recorded order,
+// the host is ignored so the server of a replay can be any url. Streams which
+// do not end, like the user events, can not be recorded.
+package httprecord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Mode selects whether a Recorder records or replays.
+type Mode int
+
+const (
+	// Replay serves the responses of the golden file, a request which is not
+	// in the file fails the test.
+	Replay Mode = iota
+	// Record sends the requests to the server and writes them with their
+	// responses to the golden file when the test finishes.
+	Record
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string `json:"method"`
+	// URL is the path and query of the request.
+	URL  string `json:"url"`
+	Body string `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// ignoredHeaders are the response headers which change on every request and
+// are not recorded.
+var ignoredHeaders = []string{"Date", "Content-Length", "X-Request-Id"}
+
+type Option func(r *Recorder)
+
+// WithTransport sets the transport of the recorded requests,
+// http.DefaultTransport by default.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// Recorder is an http.RoundTripper recording or replaying the interactions of
+// a golden file.
+type Recorder struct {
+	t         testing.TB
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	// replayed marks the interactions served by a replay
+	replayed []bool
+}
+
+var _ http.RoundTripper = &Recorder{}
+
+// New returns a Recorder of the golden file at path. In Replay mode the file
+// is read now and the test fails if it can not be, in Record mode it is
+// written when the test finishes.
+func New(t testing.TB, path string, mode Mode, opts ...Option) *Recorder {
+	t.Helper()
+	r := &Recorder{
+		t:         t,
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if mode == Record {
+		t.Cleanup(func() {
+			if err := r.save(); err != nil {
+				t.Errorf("save %s failed: %v", path, err)
+			}
+		})
+		return r
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s failed: %v, record it with mode Record", path, err)
+	}
+	if err := json.Unmarshal(data, &r.interactions); err != nil {
+		t.Fatalf("parse %s failed: %v", path, err)
+	}
+	r.replayed = make([]bool, len(r.interactions))
+	return r
+}
+
+// Client returns an http client sending its requests through r.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Interactions returns the interactions recorded so far, or the ones of the
+// golden file in Replay mode.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == Record {
+		return r.record(req, recorded)
+	}
+	return r.replay(req, recorded)
+}
+
+func newRequest(req *http.Request) (Request, error) {
+	recorded := Request{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, fmt.Errorf("read request body failed: %v", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = string(body)
+	return recorded, nil
+}
+
+func (r *Recorder) record(req *http.Request, recorded Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %v", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	for _, key := range ignoredHeaders {
+		header.Del(key)
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       string(body),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// replay serves the first interaction of the request which is not served yet,
+// so the same request may get different responses.
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.replayed[i] || interaction.Request != recorded {
+			continue
+		}
+		r.replayed[i] = true
+		resp := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+			StatusCode:    resp.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        resp.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewBufferString(resp.Body)),
+			ContentLength: int64(len(resp.Body)),
+			Request:       req,
+		}, nil
+	}
+	r.t.Errorf("request %s %s not recorded in %s", recorded.Method, recorded.URL, r.path)
+	return nil, errors.New("httprecord: request not recorded")
+}
+
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interactions := r.interactions
+	if interactions == nil {
+		interactions = []Interaction{}
+	}
+	// keep the & of the queries and forms readable
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(interactions); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,102 @@
+package httprecord
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorT records the errors of a Recorder instead of failing the test.
+type errorT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *errorT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func get(t *testing.T, c *http.Client, url string) (int, string) {
+	resp, err := c.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdata", "golden.json")
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "%s %s %d %s", r.Method, r.URL.RequestURI(), calls, body)
+	}))
+	defer server.Close()
+
+	t.Run("record", func(t *testing.T) {
+		rec := New(t, path, Record)
+		c := rec.Client()
+		code, body := get(t, c, server.URL+"/a?x=1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "GET /a?x=1 1 ", body)
+		get(t, c, server.URL+"/a?x=1")
+		code, _ = get(t, c, server.URL+"/missing")
+		assert.Equal(t, http.StatusNotFound, code)
+		resp, err := c.Post(server.URL+"/b", "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Len(t, rec.Interactions(), 4)
+	})
+	t.Run("replay", func(t *testing.T) {
+		rec := New(t, path, Replay)
+		c := rec.Client()
+		// the host is ignored and the same requests are served in order
+		code, body := get(t, c, "http://replay.invalid/a?x=1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "GET /a?x=1 1 ", body)
+		_, body = get(t, c, "http://replay.invalid/a?x=1")
+		assert.Equal(t, "GET /a?x=1 2 ", body)
+		code, _ = get(t, c, "http://replay.invalid/missing")
+		assert.Equal(t, http.StatusNotFound, code)
+
+		resp, err := c.Post("http://replay.invalid/b", "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+		assert.Empty(t, resp.Header.Get("Date"))
+		body2, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "POST /b 4 hello", string(body2))
+		assert.Equal(t, 4, calls)
+	})
+	t.Run("unmatched", func(t *testing.T) {
+		et := &errorT{TB: t}
+		c := New(et, path, Replay).Client()
+		for _, url := range []string{"/a?x=2", "/a?x=1", "/a?x=1", "/a?x=1"} {
+			resp, err := c.Get("http://replay.invalid" + url)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}
+		_, err := c.Post("http://replay.invalid/b", "text/plain", strings.NewReader("bye"))
+		assert.Error(t, err)
+		assert.Equal(t, []string{
+			"request GET /a?x=2 not recorded in " + path,
+			"request GET /a?x=1 not recorded in " + path,
+			"request POST /b not recorded in " + path,
+		}, et.errors)
+	})
+}
@@ -0,0 +1,68 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/pkg/client"
+	"go-unittest-best-practice/pkg/client/clienttest"
+	"go-unittest-best-practice/pkg/client/httprecord"
+	"go-unittest-best-practice/pkg/client/testserver"
+)
+
+var record = flag.Bool("record", false, "record the golden files of the replay tests against a test server")
+
+// replayClient returns a client replaying testdata/<name>.json, or recording it
+// against a test server with -record.
+func replayClient(t *testing.T, name string) client.Client {
+	path := filepath.Join("testdata", name+".json")
+	if *record {
+		srv := testserver.Start(t)
+		rec := httprecord.New(t, path, httprecord.Record)
+		return client.New(srv.URL, client.WithHTTPClient(rec.Client()))
+	}
+	rec := httprecord.New(t, path, httprecord.Replay)
+	return client.New("http://replay.invalid", client.WithHTTPClient(rec.Client()))
+}
+
+func TestReplay(t *testing.T) {
+	c := replayClient(t, "replay_users")
+	ctx := context.Background()
+
+	user, err := c.UserCreateContext(ctx, client.User{Name: "liuliu", Email: "aa@bb.com", Age: 18})
+	require.NoError(t, err)
+	assert.Equal(t, "liuliu", user.Name)
+	assert.NotEmpty(t, user.ID)
+	_, err = c.UserCreateContext(ctx, client.User{Name: "zhangsan"})
+	clienttest.AssertAPIError(t, err, 400, "param email not set")
+
+	require.NoError(t, c.UserUpdateContext(ctx, client.User{ID: user.ID, Name: "liuliu2"}))
+	got, err := c.UserGetContext(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "liuliu2", got.Name)
+	assert.Equal(t, 18, got.Age)
+
+	users, total, err := c.UserListContext(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, []string{user.ID}, []string{users[0].ID})
+
+	users, missing, err := c.UserBatchGet(ctx, nil, []string{"aa@bb.com", "cc@dd.com"})
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, []string{"cc@dd.com"}, missing)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.UserExport(ctx, &buf, client.ExportOptions{Format: "csv", Fields: []string{"name", "email", "age"}}))
+	assert.Equal(t, "name,email,age\nliuliu2,aa@bb.com,18\n", buf.String())
+
+	require.NoError(t, c.UserDeleteContext(ctx, user.ID))
+	_, err = c.UserGetContext(ctx, user.ID)
+	clienttest.AssertAPIError(t, err, 500, "record not found")
+}
@@ -0,0 +1,276 @@
+// Package testserver runs a real api.Service on a random local port for the
+// tests of code using pkg/client, instead of hand written httptest handlers
+// or patched http clients.
+//
+//	srv := testserver.Start(t, testserver.WithUsers(client.User{Name: "liuliu", Email: "aa@bb.com"}))
+//	users, total, err := srv.Client.UserList()
+package testserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"go-unittest-best-practice/internal/api"
+	"go-unittest-best-practice/internal/clock"
+	"go-unittest-best-practice/internal/config"
+	"go-unittest-best-practice/internal/idgen"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/pkg/client"
+)
+
+// Backend is the storage of the users of the server.
+type Backend int
+
+const (
+	// SQLite keeps everything in an in memory SQLite database, like the
+	// service in production.
+	SQLite Backend = iota
+	// Memory keeps the users in memory, the audit logs, events and webhooks
+	// are kept in an in memory SQLite database. The users are not rolled
+	// back with the transactions of the service, so a failed request may
+	// leave its user changes behind.
+	Memory
+)
+
+type Option func(s *Server)
+
+// WithBackend sets the storage of the server, SQLite by default.
+func WithBackend(backend Backend) Option {
+	return func(s *Server) {
+		s.backend = backend
+	}
+}
+
+// WithUsers seeds the server with users when it starts and after every
+// Reset, see Seed.
+func WithUsers(users ...client.User) Option {
+	return func(s *Server) {
+		s.users = append(s.users, users...)
+	}
+}
+
+// WithMaxBatchSize sets the maximum number of users a batch request may get,
+// update or delete, 1000 by default.
+func WithMaxBatchSize(n int) Option {
+	return func(s *Server) {
+		s.maxBatchSize = n
+	}
+}
+
+// WithClock sets the current time of the service and the storage, time.Now by
+// default.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.clock = clock.Func(now)
+	}
+}
+
+// WithIDGenerator sets the generator of the ids of the service and of the
+// seeded users, random uuids by default.
+func WithIDGenerator(newID func() string) Option {
+	return func(s *Server) {
+		s.ids = idgen.Func(newID)
+	}
+}
+
+// WithClientOptions sets the options of Server.Client.
+func WithClientOptions(opts ...client.Option) Option {
+	return func(s *Server) {
+		s.clientOpts = append(s.clientOpts, opts...)
+	}
+}
+
+// Server is an api.Service served on a random local port.
+type Server struct {
+	// URL is the base url of the server, like http://127.0.0.1:39251.
+	URL string
+	// Client is a client of the server.
+	Client client.Client
+
+	t          testing.TB
+	backend    Backend
+	users      []client.User
+	clock      clock.Clock
+	ids        idgen.IDGenerator
+	clientOpts []client.Option
+
+	mu           sync.RWMutex
+	maxBatchSize int
+	store        store.Store
+	svc          *api.Service
+}
+
+// Start starts a server with empty storage and the seed users of the options,
+// the server is closed when the test finishes.
+func Start(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	s := &Server{
+		t:     t,
+		clock: clock.System,
+		ids:   idgen.UUIDv4,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.reset()
+
+	server := httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(func() {
+		// the user event streams only end with their connections
+		server.CloseClientConnections()
+		server.Close()
+	})
+	s.URL = server.URL
+	s.Client = client.New(server.URL, s.clientOpts...)
+	return s
+}
+
+// NewClient starts a server like Start and returns its client.
+func NewClient(t testing.TB, opts ...Option) client.Client {
+	t.Helper()
+	return Start(t, opts...).Client
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	svc := s.svc
+	s.mu.RUnlock()
+	svc.ServeHTTP(w, r)
+}
+
+// SetMaxBatchSize changes the maximum batch size of the running server like
+// WithMaxBatchSize, it is kept by Reset.
+func (s *Server) SetMaxBatchSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxBatchSize = n
+	s.svc.SetConfig(&config.Config{MaxBatchSize: n})
+}
+
+// currentStore returns the storage of the server.
+func (s *Server) currentStore() store.Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+// Seed creates users directly in the storage and returns them as the server
+// returns them, a user without id gets one from the id generator. Seeding is neither
+// audited nor sent to the watchers of the user events, and the test fails if
+// a user can not be created.
+func (s *Server) Seed(users ...client.User) []client.User {
+	s.t.Helper()
+	return s.seed(s.currentStore(), users)
+}
+
+func (s *Server) seed(st store.Store, users []client.User) []client.User {
+	s.t.Helper()
+	if len(users) == 0 {
+		return nil
+	}
+	models := make([]store.User, 0, len(users))
+	for _, u := range users {
+		if u.ID == "" {
+			u.ID = s.ids.NewID()
+		}
+		models = append(models, store.User{
+			ID:        u.ID,
+			Name:      u.Name,
+			Email:     u.Email,
+			Age:       u.Age,
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+		})
+	}
+	if err := st.Users().CreateBatch(models); err != nil {
+		s.t.Fatalf("seed users failed: %v", err)
+	}
+	seeded := make([]client.User, 0, len(models))
+	for _, u := range models {
+		seeded = append(seeded, client.User{
+			ID:        u.ID,
+			Name:      u.Name,
+			Email:     u.Email,
+			Age:       u.Age,
+			CreatedAt: u.CreatedAt,
+			UpdatedAt: u.UpdatedAt,
+		})
+	}
+	return seeded
+}
+
+// Reset replaces the storage of the server with an empty one seeded with the
+// users of WithUsers, the maximum batch size is kept. Requests in flight
+// finish against the old storage.
+func (s *Server) Reset() {
+	s.t.Helper()
+	s.reset()
+}
+
+func (s *Server) reset() {
+	s.t.Helper()
+	db := openSQLite(s.t)
+	st := store.NewStore(db, store.WithClock(s.clock))
+	if s.backend == Memory {
+		st = &memoryStore{Store: st, users: store.NewMemoryUserRepository(store.WithClock(s.clock))}
+	}
+	s.seed(st, s.users)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = st
+	s.svc = api.NewService(st, &config.Config{MaxBatchSize: s.maxBatchSize}, api.WithClock(s.clock), api.WithIDGenerator(s.ids))
+}
+
+var sqliteSeq atomic.Int64
+
+// openSQLite opens a migrated in memory SQLite database, it is closed when
+// the test t finishes.
+func openSQLite(t testing.TB) *gorm.DB {
+	t.Helper()
+	conf := &config.Config{
+		DBDriver: store.DriverSQLite,
+		DSN:      fmt.Sprintf("file:testserver_%d_%d?mode=memory&cache=shared&_pragma=busy_timeout(5000)", time.Now().UnixNano(), sqliteSeq.Add(1)),
+	}
+	db, err := store.Open(conf, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database failed: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get database failed: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := store.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("new migrator failed: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	return db
+}
+
+// memoryStore is a Store keeping the users in memory and the rest in Store.
+type memoryStore struct {
+	store.Store
+	users store.UserRepository
+}
+
+func (s *memoryStore) Users() store.UserRepository {
+	return s.users
+}
+
+func (s *memoryStore) Transaction(fn func(tx store.Store) error) error {
+	return s.Store.Transaction(func(tx store.Store) error {
+		return fn(&memoryStore{Store: tx, users: s.users})
+	})
+}
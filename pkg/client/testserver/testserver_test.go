@@ -0,0 +1,120 @@
+package testserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-unittest-best-practice/internal/clock"
+	"go-unittest-best-practice/internal/idgen"
+	"go-unittest-best-practice/internal/store"
+	"go-unittest-best-practice/pkg/client"
+)
+
+func TestServer(t *testing.T) {
+	for name, backend := range map[string]Backend{"memory": Memory, "sqlite": SQLite} {
+		t.Run(name, func(t *testing.T) {
+			testServer(t, backend)
+		})
+	}
+}
+
+func TestServerRollback(t *testing.T) {
+	// the default backend rolls the users back with the transactions
+	srv := Start(t)
+	errRollback := errors.New("rollback")
+	err := srv.currentStore().Transaction(func(tx store.Store) error {
+		require.NoError(t, tx.Users().Create(&store.User{ID: "user-1", Name: "liuliu", Email: "aa@bb.com"}))
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	_, total, err := srv.Client.UserList()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, total)
+}
+
+func testServer(t *testing.T, backend Backend) {
+	created := time.Unix(1752999201, 0)
+	srv := Start(t,
+		WithBackend(backend),
+		WithUsers(client.User{ID: "user-1", Name: "liuliu", Email: "aa@bb.com", Age: 18, CreatedAt: created}),
+		WithMaxBatchSize(1),
+		WithClientOptions(client.WithActor("tester")),
+	)
+	ctx := context.Background()
+	audits := func() int64 {
+		_, total, err := srv.currentStore().Audits().List(store.AuditFilter{}, 1, 10)
+		require.NoError(t, err)
+		return total
+	}
+
+	t.Run("seed", func(t *testing.T) {
+		user, err := srv.Client.UserGetContext(ctx, "user-1")
+		require.NoError(t, err)
+		assert.Equal(t, "liuliu", user.Name)
+		assert.True(t, user.CreatedAt.Equal(created))
+
+		seeded := srv.Seed(client.User{Name: "zhangsan", Email: "cc@dd.com"})
+		require.Len(t, seeded, 1)
+		assert.NotEmpty(t, seeded[0].ID)
+		assert.False(t, seeded[0].CreatedAt.IsZero())
+		user, err = srv.Client.UserGetContext(ctx, seeded[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "zhangsan", user.Name)
+		assert.EqualValues(t, 0, audits())
+	})
+	t.Run("client", func(t *testing.T) {
+		_, err := srv.Client.UserCreateContext(ctx, client.User{Name: "lisi", Email: "ee@ff.com"})
+		require.NoError(t, err)
+		logs, _, err := srv.currentStore().Audits().List(store.AuditFilter{}, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, logs, 1)
+		assert.Equal(t, "tester", logs[0].Actor)
+
+		// the maximum batch size of the options limits the batches
+		_, err = srv.Client.UserBatchDelete(ctx, client.UserFilter{Name: "i"}, true)
+		assert.Error(t, err)
+	})
+	t.Run("reset", func(t *testing.T) {
+		srv.SetMaxBatchSize(10)
+		srv.Reset()
+
+		users, total, err := srv.Client.UserListContext(ctx)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, total)
+		assert.Equal(t, "user-1", users[0].ID)
+		assert.EqualValues(t, 0, audits())
+		assert.Equal(t, 10, srv.svc.Config().MaxBatchSize)
+
+		_, err = srv.Client.UserCreateContext(ctx, client.User{Name: "lisi", Email: "ee@ff.com"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestServerClockAndIDs(t *testing.T) {
+	now := time.Unix(1752999201, 0)
+	for name, backend := range map[string]Backend{"memory": Memory, "sqlite": SQLite} {
+		t.Run(name, func(t *testing.T) {
+			srv := Start(t,
+				WithBackend(backend),
+				WithClock(clock.NewFake(now).Now),
+				WithIDGenerator(idgen.NewSequence("id").NewID),
+				WithUsers(client.User{Name: "liuliu", Email: "aa@bb.com"}),
+			)
+			// the seeded user takes the first id, the request id the second
+			user, err := srv.Client.UserCreate(client.User{Name: "zhangsan", Email: "cc@dd.com"})
+			require.NoError(t, err)
+			assert.Equal(t, "id-3", user.ID)
+			assert.True(t, user.CreatedAt.Equal(now), "created at %v", user.CreatedAt)
+			assert.True(t, user.UpdatedAt.Equal(now), "updated at %v", user.UpdatedAt)
+
+			seeded, err := srv.Client.UserGet("id-1")
+			require.NoError(t, err)
+			assert.True(t, seeded.CreatedAt.Equal(now), "created at %v", seeded.CreatedAt)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+	// EventReset is sent to a resumed watch instead of the events which were
+	// pruned on the server, the users may have changed in between. It is sent
+	// whatever the watched types are.
+	EventReset = "reset"
+
+	// HeaderLastEventID is the request header of the event a watch resumes
+	// after.
+	HeaderLastEventID = "Last-Event-ID"
+
+	maxWatchRetry = 30 * time.Second
+)
+
+// UserEvent is a change of a user, the ids increase with the order of the
+// changes, but an event committed late may come after events with greater ids
+// and an event may come again after a reconnect. An event of type EventReset
+// has only the ID set.
+type UserEvent struct {
+	ID         int64     `json:"id" yaml:"id"`
+	Type       string    `json:"type" yaml:"type"`
+	UserID     string    `json:"userId" yaml:"userId"`
+	RequestID  string    `json:"requestId" yaml:"requestId"`
+	OccurredAt time.Time `json:"occurredAt" yaml:"occurredAt"`
+	// User is the user after the change, or before it for a delete.
+	User User `json:"data" yaml:"user"`
+}
+
+func (c *client) WatchUsers(ctx context.Context, types ...string) (<-chan UserEvent, error) {
+	w := &watcher{client: c, types: types, retry: time.Second}
+	body, err := w.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan UserEvent)
+	go w.run(ctx, body, events)
+	return events, nil
+}
+
+// watcher reads the event stream of /user/watch, reconnecting from the last
+// event id after the stream breaks.
+type watcher struct {
+	client *client
+	types  []string
+	lastID string
+	retry  time.Duration
+}
+
+func (w *watcher) connect(ctx context.Context) (io.ReadCloser, error) {
+	u := w.client.server + "/user/watch"
+	if len(w.types) > 0 {
+		params := url.Values{}
+		params.Set("types", strings.Join(w.types, ","))
+		u += "?" + params.Encode()
+	}
+	req, err := w.client.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if w.lastID != "" {
+		req.Header.Set(HeaderLastEventID, w.lastID)
+	}
+	resp, err := w.client.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// run reads the events until ctx is done, the failed reconnects are retried
+// with exponential backoff.
+func (w *watcher) run(ctx context.Context, body io.ReadCloser, events chan<- UserEvent) {
+	defer close(events)
+	failures := 0
+	for {
+		if body != nil {
+			w.read(ctx, body, events)
+			body.Close()
+		}
+		delay := w.retry
+		for i := 0; i < failures && delay < maxWatchRetry; i++ {
+			delay *= 2
+		}
+		if delay > maxWatchRetry {
+			delay = maxWatchRetry
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		var err error
+		body, err = w.connect(ctx)
+		if err != nil {
+			body = nil
+			failures++
+			continue
+		}
+		failures = 0
+	}
+}
+
+// read sends the events of the stream body to events until the stream ends.
+func (w *watcher) read(ctx context.Context, body io.Reader, events chan<- UserEvent) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data []string
+	// id is the id of the event being read, it becomes the last event id once
+	// the event is dispatched, so an event cut off by the end of the stream is
+	// sent again after the reconnect
+	var id *string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				var event UserEvent
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &event); err == nil {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			if id != nil {
+				w.lastID = *id
+			}
+			data, id = data[:0], nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "id":
+			id = &value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				w.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package userpb is the protobuf and gRPC code of the user service.
+package userpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative user.proto
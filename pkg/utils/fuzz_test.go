@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkRoundTrip checks ParseSize(FormatSize(size)) is size within the
+// rounding of FormatSize to a tenth of its unit.
+func checkRoundTrip(t *testing.T, size int64) {
+	formatted := FormatSize(size)
+	parsed, err := ParseSize(formatted)
+	if err != nil {
+		// the largest sizes round up to 8.0 EB, which is 2^63
+		if formatted == "8.0 EB" {
+			return
+		}
+		t.Fatalf("parse %q of %d failed: %v", formatted, size, err)
+	}
+	unit := formatted[strings.LastIndexByte(formatted, ' ')+1:]
+	tolerance := int64(0)
+	for exp, u := range sizeUnits {
+		if u == unit && exp > 0 {
+			tolerance = int64(1)<<(10*exp)/20 + 1
+		}
+	}
+	diff := parsed - size
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > tolerance {
+		t.Errorf("size %d formatted as %q parsed as %d, off by %d more than %d", size, formatted, parsed, diff, tolerance)
+	}
+}
+
+func FuzzFormatSize(f *testing.F) {
+	for _, size := range []int64{0, 1, -1, 1023, 1024, 1934, 1048575, 1 << 40, 1000000000000000000, 9223372036854775807, -9223372036854775808} {
+		f.Add(size)
+	}
+	f.Fuzz(func(t *testing.T, size int64) {
+		checkRoundTrip(t, size)
+	})
+}
+
+func FuzzParseSize(f *testing.F) {
+	for _, s := range []string{"0 B", "64", "1.5 KB", "2gb", "7.9 EB", "8.0 EB", "-100 B", " 3 tb ", "1.2.3 KB", "inf KB", "1e3 KB"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		size, err := ParseSize(s)
+		if err != nil {
+			return
+		}
+		checkRoundTrip(t, size)
+	})
+}
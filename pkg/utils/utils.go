@@ -1,6 +1,11 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
 
 func FormatSize(size int64) string {
 	const unit = 1024
@@ -13,4 +18,56 @@ func FormatSize(size int64) string {
 		exp++
 	}
 	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
-}
\ No newline at end of file
+}
+
+// sizeUnits are the units of FormatSize and ParseSize, each 1024 times the
+// one before.
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+
+// ParseSize parses a size formatted by FormatSize, like "1.5 KB" or "64 B",
+// back to bytes. The unit is case-insensitive, the space before it is
+// optional and a number without unit is bytes. The sizes of FormatSize are
+// rounded, so ParseSize(FormatSize(size)) is only close to size.
+func ParseSize(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	i := len(str)
+	for i > 0 && (str[i-1] < '0' || str[i-1] > '9') && str[i-1] != '.' {
+		i--
+	}
+	number, unit := strings.TrimSpace(str[:i]), strings.ToUpper(strings.TrimSpace(str[i:]))
+	exp := 0
+	if unit != "" {
+		exp = -1
+		for e, u := range sizeUnits {
+			if u == unit {
+				exp = e
+				break
+			}
+		}
+		if exp < 0 {
+			return 0, fmt.Errorf("invalid size unit: %q", s)
+		}
+	}
+	mul := int64(1) << (10 * exp)
+
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		if n > math.MaxInt64/mul || n < math.MinInt64/mul {
+			return 0, fmt.Errorf("size out of range: %q", s)
+		}
+		return n * mul, nil
+	}
+	// only plain decimal numbers, not the inf, nan and hex of ParseFloat
+	if number == "" || strings.Trim(number, "+-.0123456789") != "" {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	size := math.Round(f * float64(mul))
+	// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+	if size >= math.MaxInt64 || size < math.MinInt64 {
+		return 0, fmt.Errorf("size out of range: %q", s)
+	}
+	return int64(size), nil
+}
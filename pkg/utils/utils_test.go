@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -112,4 +113,49 @@ func BenchmarkFormatSize(b *testing.B) {
 			FormatSize(100000000000)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		name     string
+		size     string
+		expected int64
+		errMsg   string
+	}{
+		{name: "bytes", size: "64 B", expected: 64},
+		{name: "no unit", size: "64", expected: 64},
+		{name: "negative", size: "-100 B", expected: -100},
+		{name: "kilobytes", size: "1.0 KB", expected: 1024},
+		{name: "fraction", size: "1.9 KB", expected: 1946},
+		{name: "no space", size: "1.5MB", expected: 1572864},
+		{name: "lower case", size: "2 gb", expected: 2147483648},
+		{name: "exabytes", size: "7.9 EB", expected: 9108079886394091520},
+		{name: "max", size: "9223372036854775807 B", expected: 9223372036854775807},
+		{name: "empty", size: "", errMsg: "invalid size"},
+		{name: "unit only", size: "KB", errMsg: "invalid size"},
+		{name: "invalid unit", size: "1 KiB", errMsg: "invalid size unit"},
+		{name: "invalid number", size: "1.2.3 KB", errMsg: "invalid size"},
+		{name: "infinity", size: "inf KB", errMsg: "invalid size unit"},
+		{name: "exponent", size: "1e3 KB", errMsg: "invalid size"},
+		{name: "out of range", size: "8.0 EB", errMsg: "size out of range"},
+		{name: "integer out of range", size: "8 EB", errMsg: "size out of range"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := ParseSize(tc.size)
+			if tc.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+					t.Errorf("expected error %q, got %v", tc.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, size)
+			}
+		})
+	}
+}